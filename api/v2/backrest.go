@@ -39,8 +39,30 @@ type BackRestStatus struct {
 	// from caller's perspective, an id is sent back as a response to his request so he can further query state of that operation,
 	// referencing id, by operations/{id} endpoint
 	ID string `json:"id,omitempty"`
+	// Snapshot tags kept by the retention of a scheduled backup, the most recent last
+	RetainedSnapshots []string `json:"retainedSnapshots,omitempty"`
+	// Ids of the commitlog-restore operations replaying the commitlogs of a restore, by pod name
+	CommitlogRestores map[string]string `json:"commitlogRestores,omitempty"`
+	// Progress of the replay of the commitlogs of a restore, by pod name
+	CommitlogReplays map[string]CommitlogReplay `json:"commitlogReplays,omitempty"`
+	// Verification of the last snapshot of a backup with a verification
+	Verification *BackupVerificationStatus `json:"verification,omitempty"`
 }
 
+// CommitlogReplay is the progress of the replay of the commitlogs downloaded by a node
+type CommitlogReplay struct {
+	// Restart count of the cassandra container when Cassandra was stopped to replay the commitlogs
+	RestartCount int32 `json:"restartCount"`
+	// Replayed is true once Cassandra is back in NORMAL mode after replaying the commitlogs
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// Directories of the data volume the commitlog segments are archived to and downloaded to for a replay
+const (
+	CommitlogArchiveDirectory = "/var/lib/cassandra/commitlog_archive"
+	CommitlogRestoreDirectory = "/var/lib/cassandra/commitlog_restore"
+)
+
 type FailureCause struct {
 	// hostame of a node where this error has occurred
 	Source string `json:"source,omitempty"`
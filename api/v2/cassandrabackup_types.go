@@ -2,9 +2,11 @@ package v2
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/Orange-OpenSource/casskop/pkg/util"
 	icarus "github.com/instaclustr/instaclustr-icarus-go-client/pkg/instaclustr_icarus"
 	"strings"
+	"time"
 
 	cron "github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
@@ -16,6 +18,12 @@ func (backupSpec CassandraBackupSpec) ValidateScheduleFormat() error {
 		return err
 	}
 
+	if backupSpec.CommitlogSchedule != "" {
+		if _, err := cron.ParseStandard(backupSpec.CommitlogSchedule); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -46,14 +54,129 @@ type CassandraBackupSpec struct {
 	Entities string `json:"entities,omitempty"`
 	// Name of Secret to use when accessing cloud storage providers
 	Secret string `json:"secret,omitempty"`
+	// Retention of the snapshots of a scheduled backup. When it is set, the snapshots not retained anymore are deleted
+	// from the storage location and from the nodes after each successful run
+	Retention *BackupRetention `json:"retention,omitempty"`
+	// Schedule shipping the commitlog segments archived by the nodes to the storage location, e.g. '@every 5m'. It
+	// bounds how far back a restore can go with restoreTimestamp. Only used by scheduled backups of a CassandraCluster
+	// with commitlogArchiving
+	CommitlogSchedule string `json:"commitlogSchedule,omitempty"`
+	// Verification restores each snapshot uploaded in a throwaway CassandraCluster and checks the data restored
+	Verification *BackupVerification `json:"verification,omitempty"`
+	// Maximum duration of the backup operation, of each run for a scheduled backup. The operation is aborted once
+	// exceeded. See https://golang.org/pkg/time/#ParseDuration for the supported units
+	Timeout string `json:"timeout,omitempty"`
+	// When set the running backup operation is aborted and no other one is started until it is unset
+	Cancel bool `json:"cancel,omitempty"`
+}
+
+// OperationTimeout returns the maximum duration of a backup operation, 0 when it has no timeout
+func (cb *CassandraBackup) OperationTimeout() (time.Duration, error) {
+	return parseTimeout(cb.Spec.Timeout)
+}
+
+func parseTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(timeout)
+}
+
+// BackupVerification defines the checks run on the data of a snapshot restored in a throwaway cluster. The cluster
+// restores the first rack of each datacenter backed up, and all its nodes must agree on the schema restored
+type BackupVerification struct {
+	// Tables whose rows are counted with a consistency level ALL once restored
+	Tables []TableVerification `json:"tables,omitempty"`
+	// Maximum duration to restore and verify a snapshot, 1h by default. See https://golang.org/pkg/time/#ParseDuration
+	// for the supported units
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// TableVerification defines the rows expected in a table restored
+type TableVerification struct {
+	// Name of the table prefixed by its keyspace, e.g. ks1.t1
+	Name string `json:"name"`
+	// Minimum number of rows the table must have once restored
+	// +kubebuilder:validation:Minimum=0
+	MinRows int64 `json:"minRows,omitempty"`
+}
+
+// DefaultVerificationTimeout is the maximum duration of the verification of a snapshot when none is set
+const DefaultVerificationTimeout = time.Hour
+
+// VerificationTimeout returns the maximum duration to restore and verify a snapshot
+func (verification *BackupVerification) VerificationTimeout() (time.Duration, error) {
+	if verification.Timeout == "" {
+		return DefaultVerificationTimeout, nil
+	}
+	return time.ParseDuration(verification.Timeout)
+}
+
+// Validate checks the timeout and the names of the tables of a verification
+func (verification *BackupVerification) Validate() error {
+	if _, err := verification.VerificationTimeout(); err != nil {
+		return err
+	}
+	for _, table := range verification.Tables {
+		if parts := strings.Split(table.Name, "."); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("table %s is not prefixed by its keyspace", table.Name)
+		}
+	}
+	return nil
+}
+
+type VerificationState string
+
+const (
+	Verifying          VerificationState = "Verifying"
+	Verified           VerificationState = "Verified"
+	VerificationFailed VerificationState = "VerificationFailed"
+)
+
+// BackupVerificationStatus is the result of the verification of a snapshot
+type BackupVerificationStatus struct {
+	// Snapshot verified
+	SnapshotTag string `json:"snapshotTag"`
+	// State is Verifying, Verified or VerificationFailed
+	State VerificationState `json:"state"`
+	// Name of the throwaway CassandraCluster the snapshot is restored in
+	Cluster string `json:"cluster,omitempty"`
+	// Rows counted once verified, or why the verification failed
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// BackupRetention defines which snapshots of a scheduled backup are kept. A snapshot is kept if any of keepLast,
+// keepDaily or keepWeekly selects it, or if none of them is set. The most recent snapshot is always kept
+type BackupRetention struct {
+	// Number of most recent snapshots to keep
+	// +kubebuilder:validation:Minimum=0
+	KeepLast int32 `json:"keepLast,omitempty"`
+	// Number of days for which the most recent snapshot of the day is kept
+	// +kubebuilder:validation:Minimum=0
+	KeepDaily int32 `json:"keepDaily,omitempty"`
+	// Number of weeks for which the most recent snapshot of the week is kept
+	// +kubebuilder:validation:Minimum=0
+	KeepWeekly int32 `json:"keepWeekly,omitempty"`
+	// Snapshots older than this duration are deleted even if selected by another rule. See
+	// https://golang.org/pkg/time/#ParseDuration for the supported units, e.g. 720h
+	MaxAge string `json:"maxAge,omitempty"`
 }
 
+// SnapshotTimeLayout is the layout of the time suffix of the snapshots of a backup with retention and of the runs of a
+// scheduled backup
+const SnapshotTimeLayout = "20060102-150405"
+
 type BackupConditionType string
 
 const (
+	BackupPending   BackupConditionType = "PENDING"
 	BackupRunning   BackupConditionType = "RUNNING"
 	BackupCompleted BackupConditionType = "COMPLETED"
 	BackupFailed    BackupConditionType = "FAILED"
+	// BackupCancelled means the Backup operation was aborted while being run, as it was cancelled, deleted or timed
+	// out
+	BackupCancelled BackupConditionType = "CANCELLED"
 )
 
 func (b BackupConditionType) IsRunning() bool {
@@ -68,6 +191,10 @@ func (b BackupConditionType) HasFailed() bool {
 	return b == BackupFailed
 }
 
+func (b BackupConditionType) IsCancelled() bool {
+	return b == BackupCancelled
+}
+
 // +kubebuilder:object:root=true
 
 // CassandraBackup is the Schema for the cassandrabackups API
@@ -91,6 +218,44 @@ func (cb *CassandraBackup) PreventBackupDeletion(value bool) {
 func (cb *CassandraBackup) IsScheduled() bool {
 	return cb.Spec.Schedule != ""
 }
+
+// HasRetention returns true when the snapshots of a scheduled backup are pruned
+func (cb *CassandraBackup) HasRetention() bool {
+	return cb.IsScheduled() && cb.Spec.Retention != nil
+}
+
+// RunSnapshotTag returns the snapshot tag of a run started at the given time, suffixed with that time when the
+// snapshots of the backup are pruned so that each run keeps its own snapshot
+func (cb *CassandraBackup) RunSnapshotTag(runTime time.Time) string {
+	if !cb.HasRetention() {
+		return cb.Spec.SnapshotTag
+	}
+	return cb.Spec.SnapshotTag + "-" + runTime.UTC().Format(SnapshotTimeLayout)
+}
+
+// SnapshotTime returns the time of the run which made a snapshot, false if the tag has no time suffix
+func (cb *CassandraBackup) SnapshotTime(snapshotTag string) (time.Time, bool) {
+	prefix := cb.Spec.SnapshotTag + "-"
+	if !strings.HasPrefix(snapshotTag, prefix) {
+		return time.Time{}, false
+	}
+	runTime, err := time.Parse(SnapshotTimeLayout, strings.TrimPrefix(snapshotTag, prefix))
+	return runTime, err == nil
+}
+
+// ShipsCommitlogs returns true when the commitlogs archived by the nodes are shipped to the storage location
+func (cb *CassandraBackup) ShipsCommitlogs() bool {
+	return cb.IsScheduled() && cb.Spec.CommitlogSchedule != ""
+}
+
+// LatestSnapshotTag returns the snapshot tag to restore: the most recent retained snapshot of a backup with
+// retention, the snapshot tag of the spec otherwise
+func (cb *CassandraBackup) LatestSnapshotTag() string {
+	if retained := cb.Status.RetainedSnapshots; len(retained) > 0 {
+		return retained[len(retained)-1]
+	}
+	return cb.Spec.SnapshotTag
+}
 func (cb *CassandraBackup) Ran() bool {
 	return cb.Status.Condition != nil
 }
@@ -16,6 +16,7 @@ package v2
 
 import (
 	"testing"
+	"time"
 
 	"github.com/nsf/jsondiff"
 	"github.com/stretchr/testify/assert"
@@ -35,6 +36,12 @@ func TestValidateSchedule(t *testing.T) {
 
 	backupSpec.Schedule = "@noon" // Unknown descriptor
 	assert.NotNilf(backupSpec.ValidateScheduleFormat(), "Schedule %s should not be parseable", backupSpec.Schedule)
+
+	backupSpec.Schedule = "@daily"
+	backupSpec.CommitlogSchedule = "@every 5m"
+	assert.Nil(backupSpec.ValidateScheduleFormat())
+	backupSpec.CommitlogSchedule = "@every 5 minutes"
+	assert.NotNil(backupSpec.ValidateScheduleFormat())
 }
 
 func TestCassandraBackupComputeLastAppliedConfiguration(t *testing.T) {
@@ -63,3 +70,49 @@ func TestCassandraBackupComputeLastAppliedConfiguration(t *testing.T) {
 
 	assert.Equal(jsondiff.FullMatch, comparison)
 }
+
+func TestCassandraBackupSnapshotTags(t *testing.T) {
+	assert := assert.New(t)
+
+	backup := &CassandraBackup{Spec: CassandraBackupSpec{SnapshotTag: "weekly"}}
+	runTime := time.Date(2021, 3, 15, 1, 2, 3, 0, time.UTC)
+
+	assert.Equal("weekly", backup.RunSnapshotTag(runTime))
+	backup.Spec.Retention = &BackupRetention{KeepLast: 2}
+	assert.Equal("weekly", backup.RunSnapshotTag(runTime), "Retention only applies to scheduled backups")
+
+	backup.Spec.Schedule = "@weekly"
+	assert.Equal("weekly-20210315-010203", backup.RunSnapshotTag(runTime))
+	backup.Spec.Retention = nil
+	assert.Equal("weekly", backup.RunSnapshotTag(runTime), "Only the snapshots of a backup with retention have a time")
+	backup.Spec.Retention = &BackupRetention{KeepLast: 2}
+	snapshotTime, ok := backup.SnapshotTime("weekly-20210315-010203")
+	assert.True(ok)
+	assert.Equal(runTime, snapshotTime)
+	_, ok = backup.SnapshotTime("weekly")
+	assert.False(ok)
+
+	assert.Equal("weekly", backup.LatestSnapshotTag())
+	backup.Status.RetainedSnapshots = []string{"weekly-20210308-010203", "weekly-20210315-010203"}
+	assert.Equal("weekly-20210315-010203", backup.LatestSnapshotTag())
+}
+
+func TestBackupVerificationValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	verification := &BackupVerification{Tables: []TableVerification{{Name: "ks1.t1", MinRows: 10}}}
+	assert.Nil(verification.Validate())
+	timeout, _ := verification.VerificationTimeout()
+	assert.Equal(DefaultVerificationTimeout, timeout)
+
+	verification.Timeout = "30m"
+	timeout, _ = verification.VerificationTimeout()
+	assert.Equal(30*time.Minute, timeout)
+
+	verification.Timeout = "30"
+	assert.NotNil(verification.Validate())
+
+	verification.Timeout = ""
+	verification.Tables = append(verification.Tables, TableVerification{Name: "t2"})
+	assert.EqualError(verification.Validate(), "table t2 is not prefixed by its keyspace")
+}
@@ -0,0 +1,78 @@
+package v2
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LabelCassandraBackup is the label of a CassandraBackupRun holding the name of its CassandraBackup
+const LabelCassandraBackup = "cassandrabackup"
+
+// CassandraBackupRunSpec defines an execution of a scheduled CassandraBackup
+type CassandraBackupRunSpec struct {
+	// Name of the CassandraBackup the run belongs to
+	CassandraBackup string `json:"cassandraBackup"`
+	// Name of the snapshot uploaded by the run, the snapshotTag of the CassandraBackup suffixed with the time of the run
+	// when the CassandraBackup has a retention
+	SnapshotTag string `json:"snapshotTag"`
+}
+
+// CassandraBackupRunStatus defines the observed state of a CassandraBackupRun
+type CassandraBackupRunStatus struct {
+	BackRestStatus `json:",inline"`
+	// Progress of the backup on each node, by pod name
+	NodesProgress map[string]string `json:"nodesProgress,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CassandraBackupRun is an execution of a scheduled CassandraBackup, owned by it
+// +k8s:openapi-gen=true
+type CassandraBackupRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CassandraBackupRunSpec   `json:"spec"`
+	Status CassandraBackupRunStatus `json:"status,omitempty"`
+}
+
+// Completed returns true when the snapshot of the run has been uploaded
+func (run *CassandraBackupRun) Completed() bool {
+	return run.Status.Condition != nil && BackupConditionType(run.Status.Condition.Type).IsCompleted()
+}
+
+// +kubebuilder:object:root=true
+
+// CassandraBackupRunList contains a list of CassandraBackupRun
+type CassandraBackupRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraBackupRun `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CassandraBackupRun{}, &CassandraBackupRunList{})
+}
+
+// NewCassandraBackupRun returns the run of a scheduled CassandraBackup started at the given time and uploading the
+// given snapshot
+func NewCassandraBackupRun(cb *CassandraBackup, snapshotTag string, runTime time.Time) *CassandraBackupRun {
+	controller := true
+	return &CassandraBackupRun{
+		TypeMeta: metav1.TypeMeta{Kind: "CassandraBackupRun", APIVersion: GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cb.Name + "-" + runTime.UTC().Format(SnapshotTimeLayout),
+			Namespace: cb.Namespace,
+			Labels:    map[string]string{LabelCassandraBackup: cb.Name},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: GroupVersion.String(),
+				Kind:       "CassandraBackup",
+				Name:       cb.Name,
+				UID:        cb.UID,
+				Controller: &controller,
+			}},
+		},
+		Spec: CassandraBackupRunSpec{CassandraBackup: cb.Name, SnapshotTag: snapshotTag},
+	}
+}
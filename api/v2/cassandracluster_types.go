@@ -20,14 +20,28 @@ const (
 	DefaultReadinessHealthCheckPeriod   int32 = 10
 
 	defaultCassandraImage     = "cassandra:3.11.10"
-	defaultBootstrapImage     = "orangeopensource/cassandra-bootstrap:0.1.9"
+	defaultBootstrapImage     = "orangeopensource/cassandra-bootstrap:0.1.10"
 	defaultConfigBuilderImage = "datastax/cass-config-builder:1.0.4"
 
-	DefaultBackRestImage      = "gcr.io/cassandra-operator/instaclustr-icarus:1.1.0"
+	DefaultBackRestImage = "gcr.io/cassandra-operator/instaclustr-icarus:1.1.0"
+	//BackupProviderIcarus is the provider of DefaultBackRestImage
+	BackupProviderIcarus = "icarus"
+	//BackupProviderSnapshot takes snapshots with nodetool and uploads them with rclone from the sidecar
+	BackupProviderSnapshot    = "snapshot"
 	defaultServiceAccountName = "cassandra-cluster-node"
 	defaultMaxPodUnavailable  = 1
 	defaultImagePullPolicy    = v1.PullAlways
 
+	DefaultInternodeEncryption = "all"
+	DefaultRepairParallelism   = "parallel"
+
+	//MaxOperationHistory is the number of pod operations kept in status.operationHistory
+	MaxOperationHistory = 20
+	//MaxRemediations is the number of remediations kept in status.remediations
+	MaxRemediations = 20
+
+	DefaultNodeDownSeconds = 600
+
 	DefaultCassandraDC   = "dc1"
 	DefaultCassandraRack = "rack1"
 
@@ -69,11 +83,18 @@ var (
 
 	ActionCorrectCRDConfig = ClusterStateInfo{11, "CorrectCRDConfig"} //The Operator has correct a bad CRD configuration
 
+	ActionResizeStorage = ClusterStateInfo{12, "ResizeStorage"} //The PVCs of the rack are expanded
+
+	ActionUpgradeCassandra = ClusterStateInfo{13, "UpgradeCassandra"} //The nodes of the rack are drained and upgraded one at a time
+
 	regexDCRackName = regexp.MustCompile("^[a-z]([-a-z0-9]*[a-z0-9])?$")
 )
 
 const (
+	//AnnotationLastApplied was the last spec applied by the operator, now kept in the spec history
 	AnnotationLastApplied string = "cassandraclusters.db.orange.com/last-applied-configuration"
+	//AnnotationRollbackTo asks the operator to restore the spec applied at a revision of the spec history
+	AnnotationRollbackTo string = "cassandraclusters.db.orange.com/rollback-to"
 
 	StatusOngoing     string = "Ongoing"    // The Action is Ongoing
 	StatusDone        string = "Done"       // The Action id Done
@@ -90,6 +111,19 @@ const (
 	OperationDecommission    string = "decommission"
 	OperationRebuild         string = "rebuild"
 	OperationRemove          string = "remove"
+	OperationRepair          string = "repair"
+	OperationReplaceNode     string = "replacenode"
+
+	//List of Conditions of the CassandraCluster status
+	ConditionReady            string = "Ready"            // All racks are running and no action is ongoing
+	ConditionProgressing      string = "Progressing"      // An action is ongoing on a rack
+	ConditionDegraded         string = "Degraded"         // A rack is missing ready nodes outside of any action
+	ConditionScalingUp        string = "ScalingUp"        // Nodes are being added
+	ConditionScalingDown      string = "ScalingDown"      // Nodes are being decommissioned
+	ConditionRollingUpdate    string = "RollingUpdate"    // The statefulsets are being rolled out
+	ConditionOperationFailed  string = "OperationFailed"  // The last pod operation of a rack has failed on some pods
+	ConditionRestored         string = "Restored"         // The data of restoreFrom has been restored in all the nodes
+	ConditionScaleDownRefused string = "ScaleDownRefused" // The last scale down of a dc to 0 has been refused
 
 	BreakResyncLoop    = true
 	ContinueResyncLoop = false
@@ -145,23 +179,49 @@ func (cc *CassandraCluster) CheckDefaults() {
 		ccs.ReadinessHealthCheckPeriod = func(i int32) *int32 { return &i }(DefaultReadinessHealthCheckPeriod)
 	}
 
+	if ccs.TLS != nil && len(ccs.TLS.InternodeEncryption) == 0 {
+		ccs.TLS.InternodeEncryption = DefaultInternodeEncryption
+	}
+
+	if ccs.Remediation != nil {
+		if len(ccs.Remediation.ImagePullBackOff) == 0 {
+			ccs.Remediation.ImagePullBackOff = RemediationDeletePod
+		}
+		if len(ccs.Remediation.PendingOnDeadNode) == 0 {
+			ccs.Remediation.PendingOnDeadNode = RemediationAlert
+		}
+		if len(ccs.Remediation.NodeDown) == 0 {
+			ccs.Remediation.NodeDown = RemediationAlert
+		}
+		if ccs.Remediation.NodeDownSeconds == 0 {
+			ccs.Remediation.NodeDownSeconds = DefaultNodeDownSeconds
+		}
+	}
+
+	if ccs.Repair != nil {
+		if ccs.Repair.PrimaryRange == nil {
+			ccs.Repair.PrimaryRange = func(b bool) *bool { return &b }(true)
+		}
+		if len(ccs.Repair.Parallelism) == 0 {
+			ccs.Repair.Parallelism = DefaultRepairParallelism
+		}
+	}
+
 	// BackupRestore default config
 	if ccs.BackRestSidecar == nil {
 		ccs.BackRestSidecar = &BackRestSidecar{Image: DefaultBackRestImage}
 	} else if ccs.BackRestSidecar.Image == "" {
 		ccs.BackRestSidecar.Image = DefaultBackRestImage
 	}
+	if ccs.BackRestSidecar.Provider == "" {
+		ccs.BackRestSidecar.Provider = BackupProviderIcarus
+	}
 }
 
 // SetDefaults sets the default values for the cassandra spec and returns true if the spec was changed
 // SetDefault mus be done only once at startup
 func (cc *CassandraCluster) SetDefaults() bool {
-	changed := false
-	ccs := &cc.Spec
-	if ccs.NodesPerRacks == 0 {
-		ccs.NodesPerRacks = 1
-		changed = true
-	}
+	changed := cc.setSpecDefaults()
 	if len(cc.Status.Phase) == 0 {
 		cc.Status.Phase = ClusterPhaseInitial.Name
 		if cc.InitCassandraRackList() < 1 {
@@ -172,15 +232,27 @@ func (cc *CassandraCluster) SetDefaults() bool {
 		}
 		changed = true
 	}
+
+	return changed
+}
+
+// setSpecDefaults sets the default values SetDefaults applies to the spec of a new cluster and returns true
+// if the spec was changed
+func (cc *CassandraCluster) setSpecDefaults() bool {
+	changed := false
+	ccs := &cc.Spec
+	if ccs.NodesPerRacks == 0 {
+		ccs.NodesPerRacks = 1
+		changed = true
+	}
 	if ccs.MaxPodUnavailable == 0 {
 		ccs.MaxPodUnavailable = defaultMaxPodUnavailable
 		changed = true
 	}
-	if cc.Spec.Resources.Limits == nil {
-		cc.Spec.Resources.Limits = cc.Spec.Resources.Requests
+	if ccs.Resources.Limits == nil {
+		ccs.Resources.Limits = ccs.Resources.Requests
 		changed = true
 	}
-
 	return changed
 }
 
@@ -731,6 +803,9 @@ type CassandraClusterSpec struct {
 	// no action will be performed based on restart count.
 	RestartCountBeforePodDeletion int32 `json:"restartCountBeforePodDeletion,omitempty"`
 
+	// Remediation defines the actions taken on unhealthy pods and Cassandra nodes
+	Remediation *Remediation `json:"remediation,omitempty"`
+
 	// Very special Flag to hack CassKop reconcile loop - use with really good care
 	UnlockNextOperation bool `json:"unlockNextOperation,omitempty"`
 
@@ -776,6 +851,27 @@ type CassandraClusterSpec struct {
 	// JMX Secret if Set is used to set JMX_USER and JMX_PASSWORD
 	ImageJolokiaSecret v1.LocalObjectReference `json:"imageJolokiaSecret,omitempty"`
 
+	// TLS enables the encryption of the internode and client connections
+	TLS *TLS `json:"tls,omitempty"`
+
+	// Auth enables the authentication and authorization of CQL clients and manages their roles
+	Auth *Auth `json:"auth,omitempty"`
+
+	// CommitlogArchiving archives the commitlog segments of the nodes so that a CassandraBackup can ship them to its
+	// storage location and a CassandraRestore can replay them up to a restoreTimestamp
+	CommitlogArchiving bool `json:"commitlogArchiving,omitempty"`
+
+	// RestoreFrom restores a snapshot in each node of a new cluster before it joins the cluster. It can only be set
+	// when the cluster is created
+	RestoreFrom *RestoreFrom `json:"restoreFrom,omitempty"`
+
+	// Keyspaces whose replication is managed by the operator when dcs are added or removed
+	Keyspaces []KeyspaceReplication `json:"keyspaces,omitempty"`
+
+	// Repair schedules anti-entropy repairs, run one rack at a time. Before Cassandra 4.0, the nodes don't keep the
+	// status of the repairs: a failed repair can't be told from a successful one and is only recorded as ended
+	Repair *Repair `json:"repair,omitempty"`
+
 	//Topology to create Cassandra DC and Racks and to target appropriate Kubernetes Nodes
 	Topology Topology `json:"topology,omitempty"`
 
@@ -810,6 +906,11 @@ type CassandraClusterSpec struct {
 	// ReadinessSuccessThreshold defines success threshold for the readiness probe of the main
 	// cassandra container : https://kubernetes.io/docs/tasks/configure-pod-container/configure-liveness-readiness-startup-probes/#configure-probes
 	ReadinessSuccessThreshold *int32 `json:"readinessSuccessThreshold,omitempty"`
+	// TerminationGracePeriodSeconds is the time given to a cassandra pod to stop, including its drain by the PreStop
+	// hook. The drain durations are recorded in the status of the racks
+	// Default: 1800
+	// +kubebuilder:validation:Minimum=0
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
 	// When process namespace sharing is enabled, processes in a container are visible to all other containers in that pod.
 	// https://kubernetes.io/docs/tasks/configure-pod-container/share-process-namespace/
 	// Optional: Default to false.
@@ -821,6 +922,100 @@ type CassandraClusterSpec struct {
 	ServiceAccountName string           `json:"serviceAccountName,omitempty"`
 }
 
+// TLS defines the encryption of the Cassandra connections. Certificates are read from a Secret with the keys
+// tls.crt, tls.key and ca.crt, as created by cert-manager
+type TLS struct {
+	// Name of the Secret holding the certificate of the Cassandra nodes and the CA that signed it
+	SecretName string `json:"secretName"`
+	// Name of the Secret holding the password of the keystore and the truststore in the key password
+	KeystorePasswordSecret string `json:"keystorePasswordSecret"`
+	// Connections between nodes to encrypt: none, all, dc or rack
+	// Default: all
+	// +kubebuilder:validation:Enum=none;all;dc;rack
+	InternodeEncryption string `json:"internodeEncryption,omitempty"`
+	// Encrypt the connections of CQL clients
+	ClientEncryption bool `json:"clientEncryption,omitempty"`
+	// Require CQL clients to present a certificate signed by the CA
+	RequireClientAuth bool `json:"requireClientAuth,omitempty"`
+}
+
+// Auth enables PasswordAuthenticator and CassandraAuthorizer. Once the cluster is running, the operator replaces
+// the default cassandra superuser and creates the declared roles
+type Auth struct {
+	// Name of the Secret holding the username and password of the superuser replacing cassandra/cassandra
+	SuperuserSecret string `json:"superuserSecret"`
+	// Roles to create once the cluster is running
+	Roles []Role `json:"roles,omitempty"`
+}
+
+// Role is a CQL role created by the operator
+type Role struct {
+	// Name of the role
+	Name string `json:"name"`
+	// Name of the Secret holding the password of the role in the key password
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+	// Allow the role to log in
+	Login bool `json:"login,omitempty"`
+	// Make the role a superuser
+	Superuser bool `json:"superuser,omitempty"`
+	// Permissions granted to the role, as written in a GRANT statement. Ex: SELECT ON KEYSPACE demo
+	Grants []string `json:"grants,omitempty"`
+}
+
+// KeyspaceReplication is the replication the operator applies to a keyspace with NetworkTopologyStrategy
+type KeyspaceReplication struct {
+	// Name of the keyspace
+	Name string `json:"name"`
+	// Replication factor for each dc. A dc missing from the map has no replica
+	Replication map[string]int32 `json:"replication"`
+}
+
+// Repair defines when and how the operator repairs the cluster
+type Repair struct {
+	// Schedule of the repairs in cron format. Ex: "0 2 * * 6"
+	Schedule string `json:"schedule"`
+	// Keyspaces to repair, all the non local keyspaces by default
+	Keyspaces []string `json:"keyspaces,omitempty"`
+	// Only repair the primary ranges of each node, each range is then repaired once when all the nodes are done.
+	// Default: true
+	PrimaryRange *bool `json:"primaryRange,omitempty"`
+	// Split the primary ranges of each node in subranges repaired one after another, to limit the amount of data
+	// compared in each repair session
+	// +kubebuilder:validation:Minimum=0
+	Subranges int32 `json:"subranges,omitempty"`
+	// Parallelism of the repair sessions: sequential, parallel or dc_parallel. Default: parallel
+	// +kubebuilder:validation:Enum=sequential;parallel;dc_parallel
+	Parallelism string `json:"parallelism,omitempty"`
+}
+
+// RemediationAction is an action taken on an unhealthy pod or Cassandra node
+// +kubebuilder:validation:Enum=DeletePod;ReplaceNode;Alert
+type RemediationAction string
+
+const (
+	// RemediationDeletePod deletes the pod so that its statefulset recreates it
+	RemediationDeletePod RemediationAction = "DeletePod"
+	// RemediationReplaceNode deletes the pod and its data, the new pod replaces the Cassandra node of the old one
+	RemediationReplaceNode RemediationAction = "ReplaceNode"
+	// RemediationAlert only records the issue as an event and in status.remediations
+	RemediationAlert RemediationAction = "Alert"
+)
+
+// Remediation defines the actions taken on unhealthy pods and Cassandra nodes
+type Remediation struct {
+	// Action taken on a pod stuck in ImagePullBackOff while the image has been changed since it was created.
+	// Default: DeletePod
+	ImagePullBackOff RemediationAction `json:"imagePullBackOff,omitempty"`
+	// Action taken on a pod Pending because its local persistent volume is on a kubernetes node which is gone or
+	// not ready. Default: Alert
+	PendingOnDeadNode RemediationAction `json:"pendingOnDeadNode,omitempty"`
+	// Action taken on a Cassandra node seen down by the other nodes for more than NodeDownSeconds. Default: Alert
+	NodeDown RemediationAction `json:"nodeDown,omitempty"`
+	// Time a Cassandra node must be down before the NodeDown action is taken. Default: 600
+	// +kubebuilder:validation:Minimum=0
+	NodeDownSeconds int32 `json:"nodeDownSeconds,omitempty"`
+}
+
 // StorageConfig defines additional storage configurations
 type StorageConfig struct {
 	// Mount path into cassandra container
@@ -914,6 +1109,24 @@ type BackRestSidecar struct {
 	// Kubernetes object : https://godoc.org/k8s.io/api/core/v1#ResourceRequirements
 	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 	VolumeMounts []v1.VolumeMount `json:"volumeMount,omitempty"`
+	// Provider is the API of the sidecar used by the backups and restores of the cluster, icarus by default
+	// +kubebuilder:validation:Enum=icarus;snapshot
+	Provider string `json:"provider,omitempty"`
+}
+
+// RestoreFrom defines the snapshot restored in the nodes of a new cluster. The snapshot must have been taken from a
+// cluster with the same topology, each node restoring the data and the tokens of the node with the same dc, rack and
+// ordinal
+type RestoreFrom struct {
+	// URI of the location the snapshot was uploaded to, e.g. the storageLocation of a CassandraBackup
+	StorageLocation string `json:"storageLocation"`
+	// Snapshot to restore, e.g. the snapshotTag of a CassandraBackup or of one of its CassandraBackupRuns
+	SnapshotTag string `json:"snapshotTag"`
+	// Name of Secret to use when accessing cloud storage providers
+	Secret string `json:"secret,omitempty"`
+	// Name of the cluster backed up, the name of the cluster by default. Each node restores the node of that cluster
+	// with the same dc, rack and ordinal
+	ClusterName string `json:"clusterName,omitempty"`
 }
 
 //CassandraRackStatus defines states of Cassandra for 1 rack (1 statefulset)
@@ -928,6 +1141,36 @@ type CassandraRackStatus struct {
 
 	// PodLastOperation manage status for Pod Operation (nodetool cleanup, upgradesstables..)
 	PodLastOperation PodLastOperation `json:"podLastOperation,omitempty"`
+
+	// UpgradePartition is the ordinal from which the pods of the rack run the new major version of Cassandra
+	// while the rack is upgraded
+	UpgradePartition *int32 `json:"upgradePartition,omitempty"`
+
+	// PodDrains is the last drain of each pod of the rack, by pod name
+	PodDrains map[string]PodDrain `json:"podDrains,omitempty"`
+
+	// NodeReplacement is the replacement of a Cassandra node of the rack in progress
+	NodeReplacement *NodeReplacement `json:"nodeReplacement,omitempty"`
+}
+
+// NodeReplacement is the replacement of the Cassandra node of a pod by a new one bootstrapped on an empty volume
+type NodeReplacement struct {
+	// Pod whose Cassandra node is replaced
+	Pod string `json:"pod"`
+	// Address of the Cassandra node replaced
+	Address string `json:"address"`
+	// Host ID of the Cassandra node replaced, which the new node takes over
+	HostID string `json:"hostID,omitempty"`
+	// Time the replacement started
+	StartTime metav1.Time `json:"startTime"`
+}
+
+// PodDrain is the drain of a pod by its PreStop hook, before the pod stops
+type PodDrain struct {
+	// Time the pod was asked to stop
+	StartTime metav1.Time `json:"startTime"`
+	// Time it took to drain the pod, unset while it is draining
+	Duration *metav1.Duration `json:"duration,omitempty"`
 }
 
 //CassandraClusterStatus defines Global state of CassandraCluster
@@ -952,6 +1195,78 @@ type CassandraClusterStatus struct {
 
 	//CassandraRackStatusList list status for each Rack
 	CassandraRackStatus map[string]*CassandraRackStatus `json:"cassandraRackStatus,omitempty"`
+
+	//Hash of the certificates referenced in spec.tls, a change triggers a rolling restart of the racks
+	TLSSecretHash string `json:"tlsSecretHash,omitempty"`
+
+	//Auth is what the operator has applied from spec.auth
+	Auth *AuthStatus `json:"auth,omitempty"`
+
+	//Replication applied by the operator to each keyspace of spec.keyspaces
+	Keyspaces map[string]map[string]int32 `json:"keyspaces,omitempty"`
+
+	//Repair tracks the repairs scheduled with spec.repair
+	Repair *RepairStatus `json:"repair,omitempty"`
+
+	//OperationHistory keeps the outcome of the last pod operations, the most recent last
+	OperationHistory []PodOperationRecord `json:"operationHistory,omitempty"`
+
+	//Remediations keeps the last actions taken on unhealthy pods and Cassandra nodes, the most recent last
+	Remediations []RemediationRecord `json:"remediations,omitempty"`
+
+	//NodesDownSince is when each pod was first seen down by the other Cassandra nodes, by pod name
+	NodesDownSince map[string]metav1.Time `json:"nodesDownSince,omitempty"`
+
+	//Conditions are the standard conditions computed from the phases and actions of the racks
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	//ObservedGeneration is the generation of the CassandraCluster the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//AppliedRevision is the revision of the last spec applied by the operator, its key in the spec history
+	AppliedRevision int64 `json:"appliedRevision,omitempty"`
+
+	//UpgradeVersion is the version of Cassandra the cluster is upgraded to. Once all the nodes run its major version,
+	//the sstables of the racks are upgraded
+	UpgradeVersion string `json:"upgradeVersion,omitempty"`
+}
+
+// RepairStatus tracks the progress of the scheduled repairs
+type RepairStatus struct {
+	// Start of the current or last scheduled repair
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Keyspaces of the current or last repair when the operator started it outside the schedule, like system_auth
+	// once its replication has changed
+	Keyspaces []string `json:"keyspaces,omitempty"`
+	// Start of the current or last repair started outside the schedule
+	UnscheduledStartTime *metav1.Time `json:"unscheduledStartTime,omitempty"`
+	// Rack being repaired, empty when no repair is running
+	DCRack string `json:"dcRack,omitempty"`
+	// A rack of the current repair has failed
+	Failed bool `json:"failed,omitempty"`
+	// Whether the current repair fails is unknown. Cassandra keeps the status of the repairs since 4.0, with older
+	// versions the repairs are only known to have ended
+	OutcomeUnknown bool `json:"outcomeUnknown,omitempty"`
+	// Start of the last repair which succeeded on all the racks, for each keyspace
+	LastSuccessfulRepairs map[string]metav1.Time `json:"lastSuccessfulRepairs,omitempty"`
+	// Start of the last repair which ended on all the racks, for each keyspace, whatever its outcome
+	LastEndedRepairs map[string]metav1.Time `json:"lastEndedRepairs,omitempty"`
+}
+
+// AuthStatus tracks the roles and replication applied by the operator
+type AuthStatus struct {
+	// The default cassandra superuser has been replaced by the one of spec.auth.superuserSecret
+	SuperuserReplaced bool `json:"superuserReplaced,omitempty"`
+	// Name of the superuser which replaced the default one
+	Superuser string `json:"superuser,omitempty"`
+	// Replication of the system_auth keyspace
+	SystemAuthReplication string `json:"systemAuthReplication,omitempty"`
+	// Replication of the system_auth keyspace altered by the operator, applied once system_auth is repaired
+	SystemAuthReplicationPending string `json:"systemAuthReplicationPending,omitempty"`
+	// Hash of the configuration applied for each role and the version of its password Secret
+	Roles map[string]string `json:"roles,omitempty"`
 }
 
 // CassandraLastAction defines status of the CassandraStatefulset
@@ -991,6 +1306,36 @@ type PodLastOperation struct {
 	OperatorName string `json:"operatorName,omitempty"`
 }
 
+// PodOperationRecord is the outcome of a pod operation kept in the operation history
+type PodOperationRecord struct {
+	Name   string `json:"name"`
+	Pod    string `json:"pod"`
+	DCRack string `json:"dcRack,omitempty"`
+	// Done or Error
+	Status string `json:"status,omitempty"`
+
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	EndTime   *metav1.Time `json:"endTime,omitempty"`
+
+	// Error returned by the operation
+	Error string `json:"error,omitempty"`
+
+	// Name and version of the operator which ran the operation
+	OperatorName    string `json:"operatorName,omitempty"`
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+}
+
+// RemediationRecord is an action taken on an unhealthy pod or Cassandra node
+type RemediationRecord struct {
+	Pod string `json:"pod"`
+	// ImagePullBackOff, PendingOnDeadNode or NodeDown
+	Reason string            `json:"reason"`
+	Action RemediationAction `json:"action"`
+	Time   metav1.Time       `json:"time"`
+	// Details on the issue or the error returned by the action
+	Message string `json:"message,omitempty"`
+}
+
 type CassandraNodeStatus struct {
 	HostId string `json:"hostId,omitempty"`
 	NodeIp string `json:"nodeIp,omitempty"`
@@ -1001,6 +1346,7 @@ type CassandraNodeStatus struct {
 // CassandraCluster is the Schema for the cassandraclusters API
 // +k8s:openapi-gen=true
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
 // +kubebuilder:resource:path=cassandraclusters,scope=Namespaced,shortName=cassc;casscs
 type CassandraCluster struct {
 	metav1.TypeMeta   `json:",inline"`
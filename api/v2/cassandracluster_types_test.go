@@ -476,3 +476,44 @@ func TestSetDefaults(t *testing.T) {
 	assert.Equal(int32(defaultMaxPodUnavailable), cluster.Spec.MaxPodUnavailable)
 	assert.Equal([]string{"defaults-test-dc1-rack1-0.defaults-test.default"}, cluster.Status.SeedList)
 }
+
+func TestDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	cluster := CassandraCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "defaults-test",
+			Namespace: "default",
+		},
+		Spec: CassandraClusterSpec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					"cpu":    resource.MustParse("500m"),
+					"memory": resource.MustParse("1Gi"),
+				},
+			},
+		},
+	}
+
+	cluster.Default()
+
+	assert.Equal(int32(1), cluster.Spec.NodesPerRacks)
+	assert.Equal(int32(defaultMaxPodUnavailable), cluster.Spec.MaxPodUnavailable)
+	assert.Equal(resource.MustParse("500m"), *cluster.Spec.Resources.Limits.Cpu())
+	assert.Equal(defaultCassandraImage, cluster.Spec.CassandraImage)
+	assert.Equal(DefaultBackRestImage, cluster.Spec.BackRestSidecar.Image)
+	assert.Equal(BackupProviderIcarus, cluster.Spec.BackRestSidecar.Provider)
+	assert.Equal(DefaultLivenessInitialDelaySeconds, *cluster.Spec.LivenessInitialDelaySeconds)
+	// The status is left to the operator
+	assert.Equal("", cluster.Status.Phase)
+	assert.Nil(cluster.Status.SeedList)
+
+	// Once the cluster is initialized, nodesPerRacks=0 must reach the validating webhook
+	cluster.Status.Phase = ClusterPhaseRunning.Name
+	cluster.Spec.NodesPerRacks = 0
+	cluster.Spec.CassandraImage = ""
+	cluster.Default()
+
+	assert.Equal(int32(0), cluster.Spec.NodesPerRacks)
+	assert.Equal(defaultCassandraImage, cluster.Spec.CassandraImage)
+}
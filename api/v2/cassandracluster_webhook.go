@@ -0,0 +1,41 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the defaulting webhook of CassandraCluster
+func (cc *CassandraCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(cc).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-db-orange-com-v2-cassandracluster,mutating=true,failurePolicy=fail,groups=db.orange.com,resources=cassandraclusters,verbs=create;update,versions=v2,name=mcassandracluster.kb.io
+
+var _ webhook.Defaulter = &CassandraCluster{}
+
+// Default applies the defaults the operator would otherwise set in the reconcile loop, so that the stored object
+// is what the operator uses. As SetDefaults, the spec defaults are only set on a new cluster, which means a
+// nodesPerRacks set to 0 later on is still refused by the validating webhook
+func (cc *CassandraCluster) Default() {
+	if len(cc.Status.Phase) == 0 {
+		cc.setSpecDefaults()
+	}
+	cc.CheckDefaults()
+}
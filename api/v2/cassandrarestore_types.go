@@ -4,6 +4,7 @@ import (
 	"github.com/Orange-OpenSource/casskop/pkg/util"
 	icarus "github.com/instaclustr/instaclustr-icarus-go-client/pkg/instaclustr_icarus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 )
 
 // RestoreConditionType represents a valid condition of a Restore
@@ -17,16 +18,30 @@ const (
 	// RestoreComplete means the Restore has successfully been executed and resulting artifact stored in object storage
 	RestoreCompleted RestoreConditionType = "COMPLETED"
 	RestoreFailed    RestoreConditionType = "FAILED"
-	// RestoreCanceled means the Restore operation was interrupted while being run
+	// RestoreCancelled means the Restore operation was aborted while being run, as it was cancelled, deleted or timed
+	// out. It is also the state the providers report for an aborted operation
+	RestoreCancelled RestoreConditionType = "CANCELLED"
+	// RestoreCanceled is the condition of the restores cancelled by previous versions of the operator. It is only read,
+	// as RestoreCancelled
 	RestoreCanceled RestoreConditionType = "CANCELED"
+	// RestoreReplayingCommitlogs means the snapshot is restored and the commitlogs are replayed up to restoreTimestamp
+	RestoreReplayingCommitlogs RestoreConditionType = "REPLAYING_COMMITLOGS"
 )
 
 func (r RestoreConditionType) IsInProgress() bool {
 	return r == RestorePending || r == RestoreRunning
 }
 
+func (r RestoreConditionType) IsReplayingCommitlogs() bool {
+	return r == RestoreReplayingCommitlogs
+}
+
 func (r RestoreConditionType) IsInError() bool {
-	return r == RestoreFailed || r == RestoreCanceled
+	return r == RestoreFailed || r.IsCancelled()
+}
+
+func (r RestoreConditionType) IsCancelled() bool {
+	return r == RestoreCancelled || r == RestoreCanceled
 }
 
 func (r RestoreConditionType) IsRequired() bool {
@@ -53,6 +68,9 @@ type CassandraRestoreSpec struct {
 	Datacenter string `json:"datacenter,omitempty"`
 	// Name of the CassandraBackup to restore
 	CassandraBackup string `json:"cassandraBackup"`
+	// Name of the CassandraBackupRun to restore when the CassandraBackup is scheduled. Defaults to its most recent
+	// completed run
+	CassandraBackupRun string `json:"cassandraBackupRun,omitempty"`
 	// Maximum number of threads used to download files from the cloud. Defaults to 10
 	ConcurrentConnection *int32 `json:"concurrentConnection,omitempty"`
 	// Directory of Cassandra where data folder resides. Defaults to /var/lib/cassandra
@@ -70,7 +88,7 @@ type CassandraRestoreSpec struct {
 	// When set a running node's schema version must match the snapshot's schema version. There might be cases when we
 	// want to restore a table for which its CQL schema has not changed but it has changed for other table / keyspace
 	// but a schema for that node has changed by doing that. Defaults to False
-	ExactSchemaVersion bool `json:"exactSchemaVersion,omitempty""`
+	ExactSchemaVersion bool `json:"exactSchemaVersion,omitempty"`
 	// Database entities to restore, it might be either only keyspaces or only tables prefixed by their respective
 	// keyspace, e.g. 'k1,k2' if one wants to backup whole keyspaces or 'ks1.t1,ks2.t2' if one wants to restore specific
 	// tables. These formats are mutually exclusive so 'k1,k2.t2' is invalid. An empty field will restore all keyspaces
@@ -78,6 +96,37 @@ type CassandraRestoreSpec struct {
 	Rename   map[string]string `json:"rename,omitempty"`
 	// Name of Secret to use when accessing cloud storage providers
 	Secret string `json:"secret,omitempty"`
+	// Instant up to which the commitlogs shipped by the CassandraBackup are replayed once the snapshot is restored,
+	// in RFC3339 format, e.g. 2021-03-15T10:04:05Z. Requires commitlogArchiving on the CassandraCluster
+	RestoreTimestamp string `json:"restoreTimestamp,omitempty"`
+	// Maximum duration of the restore since its creation. Its operations are aborted once exceeded. See
+	// https://golang.org/pkg/time/#ParseDuration for the supported units
+	Timeout string `json:"timeout,omitempty"`
+	// When set the running restore operations are aborted
+	Cancel bool `json:"cancel,omitempty"`
+}
+
+// OperationTimeout returns the maximum duration of a restore, 0 when it has no timeout
+func (cr *CassandraRestore) OperationTimeout() (time.Duration, error) {
+	return parseTimeout(cr.Spec.Timeout)
+}
+
+// PreventRestoreDeletion keeps a restore until its running operations are aborted
+func (cr *CassandraRestore) PreventRestoreDeletion(value bool) {
+	if value {
+		cr.SetFinalizers([]string{"kubernetes.io/abort-needed"})
+		return
+	}
+	cr.SetFinalizers([]string{})
+}
+
+// RestoreTime returns the instant up to which commitlogs are replayed, false when they are not replayed
+func (cr *CassandraRestore) RestoreTime() (time.Time, bool, error) {
+	if cr.Spec.RestoreTimestamp == "" {
+		return time.Time{}, false, nil
+	}
+	restoreTime, err := time.Parse(time.RFC3339, cr.Spec.RestoreTimestamp)
+	return restoreTime, err == nil, err
 }
 
 // +genclient0
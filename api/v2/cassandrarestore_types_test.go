@@ -2,6 +2,7 @@ package v2
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Orange-OpenSource/casskop/api/v2/common"
 	icarus "github.com/instaclustr/instaclustr-icarus-go-client/pkg/instaclustr_icarus"
@@ -49,3 +50,54 @@ func TestComputeStatusFromRestoreOperation(t *testing.T) {
 		ID:       operationID,
 	}, cs)
 }
+
+func TestCassandraRestoreRestoreTime(t *testing.T) {
+	assert := assert.New(t)
+
+	restore := CassandraRestore{}
+	_, replay, err := restore.RestoreTime()
+	assert.Nil(err)
+	assert.False(replay)
+
+	restore.Spec.RestoreTimestamp = "2021-03-15T10:04:05Z"
+	restoreTime, replay, err := restore.RestoreTime()
+	assert.Nil(err)
+	assert.True(replay)
+	assert.Equal(time.Date(2021, 3, 15, 10, 4, 5, 0, time.UTC), restoreTime)
+
+	restore.Spec.RestoreTimestamp = "2021-03-15 10:04:05"
+	_, replay, err = restore.RestoreTime()
+	assert.NotNil(err)
+	assert.False(replay)
+}
+
+func TestCassandraRestoreOperationTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	restore := CassandraRestore{}
+	timeout, err := restore.OperationTimeout()
+	assert.Nil(err)
+	assert.Equal(time.Duration(0), timeout)
+
+	restore.Spec.Timeout = "90m"
+	timeout, err = restore.OperationTimeout()
+	assert.Nil(err)
+	assert.Equal(90*time.Minute, timeout)
+
+	restore.Spec.Timeout = "90"
+	_, err = restore.OperationTimeout()
+	assert.NotNil(err)
+}
+
+func TestRestoreConditionTypeIsCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	// Restores and backups are cancelled with the same condition, the one of the restores cancelled by previous
+	// versions is still read
+	assert.Equal(string(BackupCancelled), string(RestoreCancelled))
+	assert.True(RestoreCancelled.IsCancelled())
+	assert.True(RestoreCancelled.IsInError())
+	assert.True(RestoreCanceled.IsCancelled())
+	assert.True(RestoreCanceled.IsInError())
+	assert.False(RestoreFailed.IsCancelled())
+}
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	api "github.com/Orange-OpenSource/casskop/api/v2"
 	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/util"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"time"
@@ -16,15 +20,25 @@ import (
 type backupClient struct {
 	backup *api.CassandraBackup
 	client client.Client
+	// snapshot tag and start of the run
+	snapshotTag string
+	runTime     time.Time
+	// pods of the datacenter backed up
+	pods []corev1.Pod
+	// cluster backed up
+	cluster *api.CassandraCluster
+	// run of a scheduled backup and clients to the sidecars of its nodes
+	run         *api.CassandraBackupRun
+	nodeClients map[string]backrest.BackupProvider
 }
 
 func backup(
-	backrestClient *backrest.Client,
+	backrestClient backrest.BackupProvider,
 	backupClient *backupClient,
 	logging *logrus.Entry,
 	recorder record.EventRecorder) {
 
-	operationID, err := backrestClient.PerformBackup(backupClient.backup)
+	operationID, err := backrestClient.PerformBackup(backupClient.backup, backupClient.snapshotTag)
 
 	if err != nil {
 		logging.Error(err, fmt.Sprintf("Error while starting backup operation"))
@@ -33,7 +47,8 @@ func backup(
 			"BackupNotInitiated",
 			fmt.Sprintf("Backup of datacenter %s of cluster %s to %s under snapshot %s failed.",
 				backupClient.backup.Spec.Datacenter, backupClient.backup.Spec.CassandraCluster,
-				backupClient.backup.Spec.StorageLocation, backupClient.backup.Spec.SnapshotTag))
+				backupClient.backup.Spec.StorageLocation, backupClient.snapshotTag))
+		backupClient.failRun(err, logging)
 		return
 	}
 
@@ -42,42 +57,115 @@ func backup(
 		"BackupInitiated",
 		fmt.Sprintf("Task initiated to backup datacenter %s of cluster %s to %s under snapshot %s",
 			backupClient.backup.Spec.Datacenter, backupClient.backup.Spec.CassandraCluster,
-			backupClient.backup.Spec.StorageLocation, backupClient.backup.Spec.SnapshotTag))
+			backupClient.backup.Spec.StorageLocation, backupClient.snapshotTag))
+
+	timeout, _ := backupClient.backup.OperationTimeout()
+	deadline := time.Now().Add(timeout)
 
 	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
 	for range ticker.C {
-		if status, err := backrestClient.BackupStatusByID(operationID); err != nil {
+		if reason, deleted := backupClient.cancellation(timeout, deadline); reason != "" {
+			backupClient.cancel(backrestClient, operationID, reason, deleted, logging, recorder)
+			return
+		}
+
+		status, err := backrestClient.BackupStatus(operationID)
+		if err != nil {
 			logging.Error(err, fmt.Sprintf("Error while finding submitted backup operation %v", operationID))
-			ticker.Stop()
-			break
-		} else {
-			if !backupClient.updateStatus(status, logging){
-				continue
+			return
+		}
+		if !backupClient.updateStatus(status, logging) {
+			continue
+		}
+		switch api.BackupConditionType(status.Condition.Type) {
+		case api.BackupFailed:
+			recorder.Event(backupClient.backup,
+				corev1.EventTypeWarning,
+				"BackupFailed",
+				fmt.Sprintf("Backup operation %v on node %s has failed",
+					operationID, status.CoordinatorMember))
+			return
+		case api.BackupCancelled:
+			recorder.Event(backupClient.backup,
+				corev1.EventTypeWarning,
+				"BackupCancelled",
+				fmt.Sprintf("Backup operation %v on node %s was cancelled", operationID, status.CoordinatorMember))
+			return
+		case api.BackupCompleted:
+			recorder.Event(backupClient.backup,
+				corev1.EventTypeNormal,
+				"BackupCompleted",
+				fmt.Sprintf("Backup operation %v on node %s was completed.", operationID, status.CoordinatorMember))
+			if backupClient.backup.HasRetention() {
+				backupClient.pruneSnapshots(backrestClient, logging, recorder)
 			}
-			switch api.BackupConditionType(status.Condition.Type) {
-			case api.BackupFailed:
-				recorder.Event(backupClient.backup,
-					corev1.EventTypeWarning,
-					"BackupFailed",
-					fmt.Sprintf("Backup operation %v on node %s has failed",
-						operationID, status.CoordinatorMember))
-				ticker.Stop()
-				break
-			case api.BackupCompleted:
-				recorder.Event(backupClient.backup,
-					corev1.EventTypeNormal,
-					"BackupCompleted",
-					fmt.Sprintf("Backup operation %v on node %s was completed.", operationID, status.CoordinatorMember))
-				ticker.Stop()
-				break
+			if backupClient.backup.Spec.Verification != nil {
+				backupClient.verifySnapshot(logging, recorder)
 			}
+			return
 		}
 	}
 }
 
+// cancellation returns why the backup operation must be aborted, empty while it can go on, and whether the backup
+// was deleted
+func (backupClient *backupClient) cancellation(timeout time.Duration, deadline time.Time) (string, bool) {
+	backup := &api.CassandraBackup{}
+	err := backupClient.client.Get(context.TODO(), types.NamespacedName{Name: backupClient.backup.Name,
+		Namespace: backupClient.backup.Namespace}, backup)
+	switch {
+	case k8sErrors.IsNotFound(err) || err == nil && backup.DeletionTimestamp != nil:
+		return "backup was deleted", true
+	case err == nil && backup.Spec.Cancel:
+		return "backup was cancelled", false
+	case timeout > 0 && time.Now().After(deadline):
+		return fmt.Sprintf("backup timed out after %s", timeout), false
+	}
+	return "", false
+}
+
+// cancel aborts the backup operation through the sidecar and records it as cancelled unless the backup is gone
+func (backupClient *backupClient) cancel(backrestClient backrest.BackupProvider, operationID, reason string,
+	deleted bool, logging *logrus.Entry, recorder record.EventRecorder) {
+	logging = logging.WithFields(logrus.Fields{"operationId": operationID, "reason": reason})
+	if err := backrestClient.Abort(operationID); err != nil {
+		logging.Error(err, "Unable to abort backup operation")
+	}
+	logging.Info("Backup operation aborted")
+	if deleted {
+		return
+	}
+
+	coordinatorMember := backupClient.backup.Status.CoordinatorMember
+	backupClient.updateStatus(api.BackRestStatus{
+		CoordinatorMember: coordinatorMember,
+		ID:                operationID,
+		Condition: &api.BackRestCondition{
+			Type:               string(api.BackupCancelled),
+			LastTransitionTime: metav1.Now().Format(util.TimeStampLayout),
+			FailureCause:       []api.FailureCause{{Source: coordinatorMember, Message: reason}},
+		},
+	}, logging)
+	recorder.Event(backupClient.backup,
+		corev1.EventTypeWarning,
+		"BackupCancelled",
+		fmt.Sprintf("Backup operation %v on node %s was aborted as the %s", operationID, coordinatorMember, reason))
+}
+
 func (backupClient *backupClient) updateStatus(status api.BackRestStatus, logging *logrus.Entry) bool {
 
+	backupClient.updateRunStatus(status, logging)
+
 	patch := client.MergeFrom(backupClient.backup.DeepCopy())
+	// The snapshots retained are kept from one run to the next
+	if status.RetainedSnapshots == nil {
+		status.RetainedSnapshots = backupClient.backup.Status.RetainedSnapshots
+	}
+	// The verification of a snapshot is kept until the next one starts
+	if status.Verification == nil {
+		status.Verification = backupClient.backup.Status.Verification
+	}
 	backupClient.backup.Status = status
 
 	if err := backupClient.client.Patch(context.Background(), backupClient.backup, patch); err != nil {
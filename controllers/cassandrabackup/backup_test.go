@@ -0,0 +1,70 @@
+package cassandrabackup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/controllers/common"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// abortProvider records the operations aborted
+type abortProvider struct {
+	backrest.BackupProvider
+	aborted []string
+}
+
+func (p *abortProvider) Abort(id string) error {
+	p.aborted = append(p.aborted, id)
+	return nil
+}
+
+func TestBackupCancellation(t *testing.T) {
+	assert := assert.New(t)
+	reconcileCassandraBackup, cassandraBackup, _ := HelperInitCassandraBackupController(cbyaml)
+	backupClient := &backupClient{backup: cassandraBackup, client: reconcileCassandraBackup.Client}
+
+	reason, deleted := backupClient.cancellation(0, time.Now().Add(-time.Hour))
+	assert.Empty(reason)
+	assert.False(deleted)
+
+	reason, deleted = backupClient.cancellation(time.Hour, time.Now().Add(-time.Second))
+	assert.Equal("backup timed out after 1h0m0s", reason)
+	assert.False(deleted)
+
+	cassandraBackup.Spec.Cancel = true
+	assert.Nil(reconcileCassandraBackup.Client.Update(context.TODO(), cassandraBackup))
+	reason, deleted = backupClient.cancellation(time.Hour, time.Now().Add(time.Hour))
+	assert.Equal("backup was cancelled", reason)
+	assert.False(deleted)
+
+	assert.Nil(reconcileCassandraBackup.Client.Delete(context.TODO(), cassandraBackup))
+	reason, deleted = backupClient.cancellation(time.Hour, time.Now().Add(time.Hour))
+	assert.Equal("backup was deleted", reason)
+	assert.True(deleted)
+}
+
+func TestBackupCancel(t *testing.T) {
+	assert := assert.New(t)
+	reconcileCassandraBackup, cassandraBackup, recorder := HelperInitCassandraBackupController(cbyaml)
+	cassandraBackup.Status.CoordinatorMember = "cassandra-demo-dc1-rack1-0"
+	backupClient := &backupClient{backup: cassandraBackup, client: reconcileCassandraBackup.Client}
+	provider := &abortProvider{}
+	logging := logrus.WithFields(logrus.Fields{"backup": cassandraBackup.Name})
+
+	backupClient.cancel(provider, "op1", "backup was cancelled", false, logging, recorder)
+
+	assert.Equal([]string{"op1"}, provider.aborted)
+	assert.Equal(string(api.BackupCancelled), cassandraBackup.Status.Condition.Type)
+	assert.Equal("backup was cancelled", cassandraBackup.Status.Condition.FailureCause[0].Message)
+	common.AssertEvent(t, recorder.Events,
+		"Backup operation op1 on node cassandra-demo-dc1-rack1-0 was aborted as the backup was cancelled")
+
+	backupClient.cancel(provider, "op2", "backup was deleted", true, logging, recorder)
+	assert.Equal([]string{"op1", "op2"}, provider.aborted)
+	assert.Empty(recorder.Events)
+}
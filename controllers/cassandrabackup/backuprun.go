@@ -0,0 +1,93 @@
+package cassandrabackup
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/util"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// createRun creates the CassandraBackupRun of an execution of a scheduled backup, with a client to the sidecar of
+// each node to follow the progress of the backup on it
+func (backupClient *backupClient) createRun(cc *api.CassandraCluster) error {
+	run := api.NewCassandraBackupRun(backupClient.backup, backupClient.snapshotTag, backupClient.runTime)
+	if err := backupClient.client.Create(context.TODO(), run); err != nil {
+		return err
+	}
+	backupClient.run = run
+
+	backupClient.nodeClients = map[string]backrest.BackupProvider{}
+	for i := range backupClient.pods {
+		nodeClient, err := backrest.NewProvider(backupClient.client, cc, &backupClient.pods[i])
+		if err != nil {
+			continue
+		}
+		backupClient.nodeClients[backupClient.pods[i].Name] = nodeClient
+	}
+	return nil
+}
+
+// updateRunStatus updates the status of the run with the status of the backup and its progress on each node
+func (backupClient *backupClient) updateRunStatus(status api.BackRestStatus, logging *logrus.Entry) {
+	run := backupClient.run
+	if run == nil {
+		return
+	}
+
+	patch := client.MergeFrom(run.DeepCopy())
+	status.RetainedSnapshots = nil
+	run.Status.BackRestStatus = status
+	if status.Condition != nil {
+		for podName, nodeClient := range backupClient.nodeClients {
+			progress, err := backrest.NodeBackupProgress(nodeClient, backupClient.snapshotTag)
+			if err != nil || progress == "" {
+				continue
+			}
+			if run.Status.NodesProgress == nil {
+				run.Status.NodesProgress = map[string]string{}
+			}
+			run.Status.NodesProgress[podName] = progress
+		}
+	}
+
+	if err := backupClient.client.Patch(context.Background(), run, patch); err != nil {
+		logging.Error(err, errors.WrapIfWithDetails(err, "could not update status for backup run",
+			"run", run.Name))
+	}
+}
+
+// failRun sets the run as failed when the backup could not be started
+func (backupClient *backupClient) failRun(cause error, logging *logrus.Entry) {
+	backupClient.updateRunStatus(api.BackRestStatus{
+		CoordinatorMember: backupClient.backup.Status.CoordinatorMember,
+		Condition: &api.BackRestCondition{
+			Type:               string(api.BackupFailed),
+			LastTransitionTime: metav1.Now().Format(util.TimeStampLayout),
+			FailureCause: []api.FailureCause{{Source: backupClient.backup.Status.CoordinatorMember,
+				Message: cause.Error()}},
+		},
+	}, logging)
+}
+
+// deleteRuns deletes the runs which uploaded a snapshot
+func (backupClient *backupClient) deleteRuns(snapshotTag string) error {
+	runs := &api.CassandraBackupRunList{}
+	if err := backupClient.client.List(context.TODO(), runs, client.InNamespace(backupClient.backup.Namespace),
+		client.MatchingLabels{api.LabelCassandraBackup: backupClient.backup.Name}); err != nil {
+		return err
+	}
+	for i := range runs.Items {
+		if runs.Items[i].Spec.SnapshotTag != snapshotTag {
+			continue
+		}
+		if err := backupClient.client.Delete(context.TODO(), &runs.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
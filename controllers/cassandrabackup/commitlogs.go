@@ -0,0 +1,123 @@
+package cassandrabackup
+
+import (
+	"fmt"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// execOnPod runs a shell script in the cassandra container of a pod. It is a variable so that tests can replace it
+var execOnPod = execScriptOnPod
+
+func execScriptOnPod(pod *corev1.Pod, script string) error {
+	k8s.InitClient()
+	_, stderr, err := k8s.ExecPod(pod.Namespace, pod, []string{"sh", "-c", script})
+	if err != nil {
+		return fmt.Errorf("script failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	return nil
+}
+
+// shippingDirectory is the directory the commitlog segments archived by a node are moved to while they are shipped.
+// Each shipping has its own directory so that a slow one does not ship the segments of the next one
+func shippingDirectory(shippingTime time.Time) string {
+	return fmt.Sprintf("%s/shipping-%s", api.CommitlogArchiveDirectory, shippingTime.UTC().Format(api.SnapshotTimeLayout))
+}
+
+// shipCommitlogs uploads the commitlog segments archived by each node of the datacenter to the storage location of
+// the backup. The segments are deleted from the node once uploaded, and moved back to the archive directory to be
+// shipped next time otherwise
+func (r *CassandraBackupReconciler) shipCommitlogs(cassandraBackup *api.CassandraBackup, cc *api.CassandraCluster,
+	reqLogger *logrus.Entry) {
+
+	pods, err := r.listPods(cassandraBackup.Namespace, k8s.LabelsForCassandraDC(cc, cassandraBackup.Spec.Datacenter))
+	if err != nil {
+		reqLogger.Error(err, "Unable to list pods to ship commitlogs")
+		return
+	}
+
+	directory := shippingDirectory(time.Now())
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		backrestClient, err := backrest.NewCommitlogProvider(r.Client, cc, pod)
+		if err != nil {
+			reqLogger.WithFields(logrus.Fields{"pod": pod.Name}).Error(err, "Unable to reach backrest sidecar")
+			continue
+		}
+		go shipNodeCommitlogs(backrestClient, cassandraBackup, pod, directory, reqLogger, r.Recorder)
+	}
+}
+
+func shipNodeCommitlogs(backrestClient backrest.CommitlogProvider, cassandraBackup *api.CassandraBackup,
+	pod *corev1.Pod, directory string, logging *logrus.Entry, recorder record.EventRecorder) {
+
+	logging = logging.WithFields(logrus.Fields{"pod": pod.Name, "directory": directory})
+
+	if err := execOnPod(pod, fmt.Sprintf("mkdir -p %[2]s && find %[1]s -maxdepth 1 -type f -exec mv {} %[2]s \\;",
+		api.CommitlogArchiveDirectory, directory)); err != nil {
+		logging.Error(err, "Unable to move the archived commitlogs to ship")
+		return
+	}
+
+	if err := shipDirectory(backrestClient, cassandraBackup, directory); err != nil {
+		logging.Error(err, "Unable to ship commitlogs")
+		recorder.Event(cassandraBackup,
+			corev1.EventTypeWarning,
+			"CommitlogShippingFailed",
+			fmt.Sprintf("Commitlogs of pod %s were not shipped to %s, they will be next time", pod.Name,
+				cassandraBackup.Spec.StorageLocation))
+		if err := execOnPod(pod, fmt.Sprintf("mv %[2]s/* %[1]s/ ; rmdir %[2]s",
+			api.CommitlogArchiveDirectory, directory)); err != nil {
+			logging.Error(err, "Unable to move back the commitlogs not shipped")
+		}
+		return
+	}
+
+	if err := execOnPod(pod, fmt.Sprintf("rm -rf %s", directory)); err != nil {
+		logging.Error(err, "Unable to delete the commitlogs shipped")
+	}
+}
+
+// shipDirectory submits the shipping of the commitlogs of a directory and waits for it to complete
+func shipDirectory(backrestClient backrest.CommitlogProvider, cassandraBackup *api.CassandraBackup,
+	directory string) error {
+	operationID, err := backrestClient.ShipCommitlogs(cassandraBackup, directory)
+	if err != nil {
+		return err
+	}
+	return waitOperation(backrestClient, "commitlog-backup", operationID)
+}
+
+// operationPollPeriod is the period the status of an operation of a sidecar is polled at
+var operationPollPeriod = 2 * time.Second
+
+// waitOperation waits for an operation of a sidecar to complete. It returns an error if the operation ends in
+// another state
+func waitOperation(backrestClient backrest.BackupProvider, operationType, operationID string) error {
+	ticker := time.NewTicker(operationPollPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		status, err := backrestClient.BackupStatus(operationID)
+		if err != nil {
+			return err
+		}
+		if status.Condition == nil {
+			continue
+		}
+		switch conditionType := api.BackupConditionType(status.Condition.Type); {
+		case conditionType.IsCompleted():
+			return nil
+		case conditionType.IsRunning() || conditionType == api.BackupPending:
+			continue
+		default:
+			return fmt.Errorf("%s operation %s ended in state %s", operationType, operationID, conditionType)
+		}
+	}
+	return nil
+}
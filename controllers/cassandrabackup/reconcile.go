@@ -85,6 +85,7 @@ func (r *CassandraBackupReconciler) Reconcile(request reconcile.Request) (reconc
 	// If deleted and a schedule was configured, we remove the cron task
 	if cassandraBackup.DeletionTimestamp != nil && cassandraBackup.IsScheduled() {
 		r.Scheduler.Remove(cassandraBackup.Name)
+		r.Scheduler.RemoveCommitlogShipping(cassandraBackup.Name)
 		r.Recorder.Event(
 			cassandraBackup,
 			corev1.EventTypeNormal,
@@ -122,7 +123,7 @@ func (r *CassandraBackupReconciler) Reconcile(request reconcile.Request) (reconc
 		return common.Reconciled()
 	}
 
-	cassandraBackup.Status = api.BackRestStatus{}
+	cassandraBackup.Status = api.BackRestStatus{RetainedSnapshots: cassandraBackup.Status.RetainedSnapshots}
 
 	if exists, err := existingNotScheduledSnapshot(r.Client, cassandraBackup); err != nil {
 		return reconcile.Result{}, err
@@ -174,6 +175,40 @@ func (r *CassandraBackupReconciler) Reconcile(request reconcile.Request) (reconc
 		}
 	}
 
+	// Validate the timeout if it's set
+	if _, err := cassandraBackup.OperationTimeout(); err != nil {
+		r.Recorder.Event(
+			cassandraBackup,
+			corev1.EventTypeWarning,
+			"BackupFailedTimeoutParseError",
+			fmt.Sprintf("Timeout %s can't be parsed", cassandraBackup.Spec.Timeout))
+		return common.Reconciled()
+	}
+
+	// Validate the max age of the retention if it's set
+	if retention := cassandraBackup.Spec.Retention; retention != nil && retention.MaxAge != "" {
+		if _, err := time.ParseDuration(retention.MaxAge); err != nil {
+			r.Recorder.Event(
+				cassandraBackup,
+				corev1.EventTypeWarning,
+				"BackupFailedMaxAgeParseError",
+				fmt.Sprintf("Max age %s of retention can't be parsed", retention.MaxAge))
+			return common.Reconciled()
+		}
+	}
+
+	// Validate the verification if it's set
+	if verification := cassandraBackup.Spec.Verification; verification != nil {
+		if err := verification.Validate(); err != nil {
+			r.Recorder.Event(
+				cassandraBackup,
+				corev1.EventTypeWarning,
+				"BackupInvalidVerification",
+				fmt.Sprintf("Verification is not valid: %s", err.Error()))
+			return common.Reconciled()
+		}
+	}
+
 	// Get CassandraCluster object
 	cc := &api.CassandraCluster{}
 	if err := r.Client.Get(context.TODO(),
@@ -213,6 +248,7 @@ func (r *CassandraBackupReconciler) Reconcile(request reconcile.Request) (reconc
 				}
 
 				cassandraBackup.Spec.Schedule = oldCassandraBackup.Spec.Schedule
+				cassandraBackup.Spec.CommitlogSchedule = oldCassandraBackup.Spec.CommitlogSchedule
 				cassandraBackup.Annotations[annotationLastApplied], _ = cassandraBackup.ComputeLastAppliedAnnotation()
 				return reconcile.Result{}, err
 			}
@@ -221,6 +257,23 @@ func (r *CassandraBackupReconciler) Reconcile(request reconcile.Request) (reconc
 			return common.Reconciled()
 		}
 
+		// The commitlogs are shipped by their own cron task
+		if cassandraBackup.ShipsCommitlogs() && !cc.Spec.CommitlogArchiving {
+			r.Recorder.Event(cassandraBackup, corev1.EventTypeWarning, "CommitlogArchivingDisabled",
+				fmt.Sprintf("Commitlogs of cluster %s are not shipped as it does not archive them",
+					cassandraBackup.Spec.CassandraCluster))
+			r.Scheduler.RemoveCommitlogShipping(cassandraBackup.Name)
+		} else if cassandraBackup.ShipsCommitlogs() {
+			if err := r.Scheduler.AddOrUpdateCommitlogShipping(cassandraBackup,
+				func() { r.shipCommitlogs(cassandraBackup, cc, reqLogger) }, &r.Recorder); err != nil {
+				r.Recorder.Event(cassandraBackup, corev1.EventTypeWarning, "BackupScheduleError",
+					fmt.Sprintf("Wasn't able to schedule the shipping of commitlogs of %s: %s", cassandraBackup.Name,
+						err.Error()))
+			}
+		} else {
+			r.Scheduler.RemoveCommitlogShipping(cassandraBackup.Name)
+		}
+
 		if skipped, err := r.Scheduler.AddOrUpdate(
 			cassandraBackup, func() { r.backupData(cassandraBackup, cc, reqLogger) }, &r.Recorder); err != nil {
 			r.Recorder.Event(cassandraBackup, corev1.EventTypeWarning, "BackupScheduleError",
@@ -243,12 +296,24 @@ func (r *CassandraBackupReconciler) Reconcile(request reconcile.Request) (reconc
 func (r *CassandraBackupReconciler) backupData(cassandraBackup *api.CassandraBackup, cc *api.CassandraCluster,
 	reqLogger *logrus.Entry) error {
 
+	// A cancelled backup is not started until it is uncancelled
+	if cassandraBackup.Spec.Cancel {
+		r.Recorder.Event(cassandraBackup,
+			corev1.EventTypeNormal,
+			"BackupSkipped",
+			fmt.Sprintf("Datacenter %s of cluster %s was not backed up as the backup is cancelled",
+				cassandraBackup.Spec.Datacenter, cassandraBackup.Spec.CassandraCluster))
+		return nil
+	}
+
 	pods, err := r.listPods(cassandraBackup.Namespace, k8s.LabelsForCassandraDC(cc, cassandraBackup.Spec.Datacenter))
 	if err != nil {
 		return fmt.Errorf("unable to list pods")
 	}
 
-	backupClient := &backupClient{backup: cassandraBackup, client: r.Client}
+	runTime := time.Now()
+	backupClient := &backupClient{backup: cassandraBackup, client: r.Client,
+		snapshotTag: cassandraBackup.RunSnapshotTag(runTime), runTime: runTime, pods: pods.Items, cluster: cc}
 	backupClient.updateStatus(api.BackRestStatus{}, reqLogger)
 
 	if len(pods.Items) == 0 {
@@ -263,9 +328,27 @@ func (r *CassandraBackupReconciler) backupData(cassandraBackup *api.CassandraBac
 	}
 
 	pod := pods.Items[random.Intn(len(pods.Items))]
-	cassandraBackup.Status = api.BackRestStatus{CoordinatorMember: pod.Name}
+	cassandraBackup.Status = api.BackRestStatus{CoordinatorMember: pod.Name,
+		RetainedSnapshots: cassandraBackup.Status.RetainedSnapshots}
 
-	backrestClient, _ := backrest.NewClient(r.Client, cc, &pod)
+	backrestClient, err := backrest.NewProvider(r.Client, cc, &pod)
+	if err != nil {
+		reqLogger.Error(err, "Error while starting backup operation")
+		r.Recorder.Event(backupClient.backup,
+			corev1.EventTypeWarning,
+			"BackupNotInitiated",
+			fmt.Sprintf("Backup of datacenter %s of cluster %s failed: %s",
+				backupClient.backup.Spec.Datacenter, backupClient.backup.Spec.CassandraCluster, err.Error()))
+		return nil
+	}
+
+	// Each execution of a scheduled backup is kept in its own CassandraBackupRun
+	if cassandraBackup.IsScheduled() {
+		if err := backupClient.createRun(cc); err != nil {
+			logrus.WithFields(logrus.Fields{"backup": cassandraBackup.Name, "snapshot": backupClient.snapshotTag,
+				"err": err}).Error("Issue when creating CassandraBackupRun")
+		}
+	}
 
 	go backup(backrestClient, backupClient, reqLogger, r.Recorder)
 
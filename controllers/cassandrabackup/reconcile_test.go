@@ -74,6 +74,8 @@ func HelperInitCassandraBackupController(cassandraBackupYaml string) (*Cassandra
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraClusterList{})
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &cassandraBackup)
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &cassandraBackupList)
+	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraBackupRun{})
+	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraBackupRunList{})
 
 	objs := []runtime.Object{
 		&cassandraBackup,
@@ -211,3 +213,21 @@ func TestCassandraBackupDatacenterNotFound(t *testing.T) {
 	common.AssertEvent(t, recorder.Events, fmt.Sprintf("Datacenter %s of cluster %s to backup not found",
 		cb.Spec.Datacenter, cb.Spec.CassandraCluster))
 }
+
+func TestCassandraBackupInvalidTimeout(t *testing.T) {
+	assert := assert.New(t)
+	reconcileCassandraBackup, cb, recorder := HelperInitCassandraBackupController(cbyamlfile + "  timeout: 2\n")
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      cb.Name,
+			Namespace: cb.Namespace,
+		},
+	}
+
+	res, err := reconcileCassandraBackup.Reconcile(req)
+
+	assert.Equal(reconcile.Result{}, res)
+	assert.Nil(err)
+	common.AssertEvent(t, recorder.Events, "Timeout 2 can't be parsed")
+}
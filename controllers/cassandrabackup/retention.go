@@ -0,0 +1,135 @@
+package cassandrabackup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// clearSnapshot deletes a snapshot from a node. It is a variable so that tests can replace it
+var clearSnapshot = clearSnapshotOnPod
+
+func clearSnapshotOnPod(pod *corev1.Pod, snapshotTag string) error {
+	k8s.InitClient()
+	_, stderr, err := k8s.ExecPod(pod.Namespace, pod, []string{"nodetool", "clearsnapshot", "-t", snapshotTag})
+	if err != nil {
+		return fmt.Errorf("nodetool clearsnapshot failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	return nil
+}
+
+type snapshot struct {
+	tag     string
+	runTime time.Time
+}
+
+// retainedSnapshots splits the snapshots of a backup in the ones kept by its retention and the expired ones, both
+// from the oldest to the most recent. Snapshots whose tag has no time suffix are always kept
+func retainedSnapshots(cassandraBackup *api.CassandraBackup, snapshotTags []string,
+	now time.Time) (retained, expired []string) {
+	var snapshots []snapshot
+	for _, tag := range snapshotTags {
+		if runTime, ok := cassandraBackup.SnapshotTime(tag); ok {
+			snapshots = append(snapshots, snapshot{tag, runTime})
+		} else {
+			retained = append(retained, tag)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].runTime.After(snapshots[j].runTime) })
+
+	retention := cassandraBackup.Spec.Retention
+	if retention == nil {
+		retention = &api.BackupRetention{}
+	}
+	maxAge, _ := time.ParseDuration(retention.MaxAge)
+	noRule := retention.KeepLast == 0 && retention.KeepDaily == 0 && retention.KeepWeekly == 0
+	days, weeks := map[string]bool{}, map[string]bool{}
+
+	kept := make([]bool, len(snapshots))
+	for i, snapshot := range snapshots {
+		kept[i] = noRule || int32(i) < retention.KeepLast
+		// The first snapshot of a day or a week is its most recent one
+		if day := snapshot.runTime.Format("2006-01-02"); !days[day] {
+			days[day] = true
+			kept[i] = kept[i] || int32(len(days)) <= retention.KeepDaily
+		}
+		year, week := snapshot.runTime.ISOWeek()
+		if week := fmt.Sprintf("%d-%d", year, week); !weeks[week] {
+			weeks[week] = true
+			kept[i] = kept[i] || int32(len(weeks)) <= retention.KeepWeekly
+		}
+		if maxAge > 0 && now.Sub(snapshot.runTime) > maxAge {
+			kept[i] = false
+		}
+	}
+	if len(kept) > 0 {
+		kept[0] = true
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if kept[i] {
+			retained = append(retained, snapshots[i].tag)
+		} else {
+			expired = append(expired, snapshots[i].tag)
+		}
+	}
+	return
+}
+
+// pruneSnapshots deletes the snapshots of a scheduled backup not kept by its retention anymore from the storage
+// location and from the nodes. A snapshot which can't be deleted stays retained to be deleted after the next run
+func (backupClient *backupClient) pruneSnapshots(backrestClient backrest.BackupProvider, logging *logrus.Entry,
+	recorder record.EventRecorder) {
+	cassandraBackup := backupClient.backup
+	retained, expired := retainedSnapshots(cassandraBackup,
+		append(cassandraBackup.Status.RetainedSnapshots, backupClient.snapshotTag), time.Now())
+
+	for _, snapshotTag := range expired {
+		err := backupClient.removeSnapshot(backrestClient, snapshotTag)
+		if err != nil {
+			logging.WithFields(logrus.Fields{"snapshot": snapshotTag}).Errorf("Can't delete expired snapshot: %v", err)
+			recorder.Event(cassandraBackup, corev1.EventTypeWarning, "BackupPruneFailed",
+				fmt.Sprintf("Expired snapshot %s could not be deleted: %s", snapshotTag, err.Error()))
+			retained = append(retained, snapshotTag)
+			continue
+		}
+		recorder.Event(cassandraBackup, corev1.EventTypeNormal, "BackupPruned",
+			fmt.Sprintf("Expired snapshot %s was deleted from %s and from the nodes of datacenter %s",
+				snapshotTag, cassandraBackup.Spec.StorageLocation, cassandraBackup.Spec.Datacenter))
+	}
+
+	// Snapshots which could not be deleted are kept in order
+	sort.SliceStable(retained, func(i, j int) bool {
+		timeI, _ := cassandraBackup.SnapshotTime(retained[i])
+		timeJ, _ := cassandraBackup.SnapshotTime(retained[j])
+		return timeI.Before(timeJ)
+	})
+	status := cassandraBackup.Status.DeepCopy()
+	status.RetainedSnapshots = retained
+	backupClient.updateStatus(*status, logging)
+}
+
+// removeSnapshot deletes a snapshot from the storage location and then from the nodes, once its removal from the
+// storage location has completed. The snapshot stays on the nodes if that removal fails
+func (backupClient *backupClient) removeSnapshot(backrestClient backrest.BackupProvider, snapshotTag string) error {
+	operationID, err := backrestClient.DeleteBackup(backupClient.backup, snapshotTag)
+	if err != nil {
+		return err
+	}
+	if err = waitOperation(backrestClient, "remove-backup", operationID); err != nil {
+		return err
+	}
+	for i := range backupClient.pods {
+		if err := clearSnapshot(&backupClient.pods[i], snapshotTag); err != nil {
+			return err
+		}
+	}
+	return backupClient.deleteRuns(snapshotTag)
+}
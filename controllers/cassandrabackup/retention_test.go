@@ -0,0 +1,101 @@
+package cassandrabackup
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// removeProvider removes the backups with the states given, one state per status polled
+type removeProvider struct {
+	backrest.BackupProvider
+	states []api.BackupConditionType
+}
+
+func (p *removeProvider) DeleteBackup(*api.CassandraBackup, string) (string, error) {
+	return "remove1", nil
+}
+
+func (p *removeProvider) BackupStatus(string) (api.BackRestStatus, error) {
+	state := p.states[0]
+	p.states = p.states[1:]
+	return api.BackRestStatus{Condition: &api.BackRestCondition{Type: string(state)}}, nil
+}
+
+func TestRetainedSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	cassandraBackup := &api.CassandraBackup{Spec: api.CassandraBackupSpec{SnapshotTag: "daily", Schedule: "@daily",
+		Retention: &api.BackupRetention{}}}
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	// A snapshot every 12 hours for 3 weeks, the oldest first
+	var snapshotTags []string
+	for hours := 21 * 24; hours > 0; hours -= 12 {
+		snapshotTags = append(snapshotTags, cassandraBackup.RunSnapshotTag(now.Add(-time.Duration(hours)*time.Hour)))
+	}
+	snapshotTags = append([]string{"manual"}, snapshotTags...)
+
+	retained, expired := retainedSnapshots(cassandraBackup, snapshotTags, now)
+	assert.Equal(snapshotTags, retained)
+	assert.Empty(expired)
+
+	cassandraBackup.Spec.Retention = &api.BackupRetention{KeepLast: 3}
+	retained, expired = retainedSnapshots(cassandraBackup, snapshotTags, now)
+	assert.Equal([]string{"manual", "daily-20210314-000000", "daily-20210314-120000", "daily-20210315-000000"},
+		retained)
+	assert.Len(expired, len(snapshotTags)-4)
+	assert.Equal("daily-20210222-120000", expired[0])
+
+	cassandraBackup.Spec.Retention = &api.BackupRetention{KeepLast: 1, KeepDaily: 3}
+	retained, _ = retainedSnapshots(cassandraBackup, snapshotTags, now)
+	assert.Equal([]string{"manual", "daily-20210313-120000", "daily-20210314-120000", "daily-20210315-000000"},
+		retained)
+
+	// 2021-03-15 is a Monday, the most recent snapshot of the previous weeks is taken on Sundays at noon
+	cassandraBackup.Spec.Retention = &api.BackupRetention{KeepWeekly: 3}
+	retained, _ = retainedSnapshots(cassandraBackup, snapshotTags, now)
+	assert.Equal([]string{"manual", "daily-20210307-120000", "daily-20210314-120000", "daily-20210315-000000"},
+		retained)
+
+	cassandraBackup.Spec.Retention = &api.BackupRetention{KeepWeekly: 3, MaxAge: "120h"}
+	retained, _ = retainedSnapshots(cassandraBackup, snapshotTags, now)
+	assert.Equal([]string{"manual", "daily-20210314-120000", "daily-20210315-000000"}, retained)
+
+	// The most recent snapshot is kept whatever its age
+	cassandraBackup.Spec.Retention = &api.BackupRetention{MaxAge: "1h"}
+	retained, _ = retainedSnapshots(cassandraBackup, snapshotTags, now)
+	assert.Equal([]string{"manual", "daily-20210315-000000"}, retained)
+}
+
+func TestRemoveSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	reconcileCassandraBackup, cassandraBackup, _ := HelperInitCassandraBackupController(cbyaml)
+	backupClient := &backupClient{backup: cassandraBackup, client: reconcileCassandraBackup.Client,
+		pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-demo-dc1-rack1-0"}}}}
+
+	defer func(period time.Duration) { operationPollPeriod = period }(operationPollPeriod)
+	operationPollPeriod = time.Millisecond
+	defer func(clear func(*corev1.Pod, string) error) { clearSnapshot = clear }(clearSnapshot)
+	var cleared []string
+	clearSnapshot = func(pod *corev1.Pod, snapshotTag string) error {
+		cleared = append(cleared, pod.Name+"/"+snapshotTag)
+		return nil
+	}
+
+	// The snapshot stays on the nodes when its removal from the storage location fails
+	provider := &removeProvider{states: []api.BackupConditionType{api.BackupRunning, api.BackupFailed}}
+	err := backupClient.removeSnapshot(provider, "daily-20210314-000000")
+	assert.EqualError(err, "remove-backup operation remove1 ended in state FAILED")
+	assert.Empty(cleared)
+
+	provider = &removeProvider{states: []api.BackupConditionType{api.BackupPending, api.BackupCompleted}}
+	assert.Nil(backupClient.removeSnapshot(provider, "daily-20210314-000000"))
+	assert.Equal([]string{"cassandra-demo-dc1-rack1-0/daily-20210314-000000"}, cleared)
+	assert.Empty(provider.states)
+}
@@ -62,3 +62,37 @@ func (schedule Scheduler) Remove(backupName string) {
 		delete(schedule.entries, backupName)
 	}
 }
+
+// commitlogsEntry is the name of the cron task shipping the commitlogs of a backup
+func commitlogsEntry(backupName string) string {
+	return backupName + "/commitlogs"
+}
+
+// AddOrUpdateCommitlogShipping adds or updates the cron task shipping the commitlogs of a backup
+func (schedule Scheduler) AddOrUpdateCommitlogShipping(cassandraBackup *api.CassandraBackup,
+	task func(), recorder *record.EventRecorder) error {
+
+	entryName := commitlogsEntry(cassandraBackup.Name)
+
+	if schedule.Contains(entryName) && schedule.entries[entryName].Schedule == cassandraBackup.Spec.CommitlogSchedule {
+		return nil
+	}
+
+	schedule.Remove(entryName)
+
+	entryID, err := schedule.cronClient.AddFunc(cassandraBackup.Spec.CommitlogSchedule, task)
+	if err == nil {
+		schedule.entries[entryName] = entryType{entryID, cassandraBackup.Spec.CommitlogSchedule}
+		(*recorder).Event(
+			cassandraBackup,
+			corev1.EventTypeNormal,
+			"CommitlogShippingScheduled",
+			fmt.Sprintf("Controller scheduled task %s to ship the commitlogs of cluster %s with schedule %s",
+				cassandraBackup.Name, cassandraBackup.Spec.CassandraCluster, cassandraBackup.Spec.CommitlogSchedule))
+	}
+	return err
+}
+
+func (schedule Scheduler) RemoveCommitlogShipping(backupName string) {
+	schedule.Remove(commitlogsEntry(backupName))
+}
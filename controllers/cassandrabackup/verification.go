@@ -0,0 +1,353 @@
+package cassandrabackup
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/Orange-OpenSource/casskop/pkg/util"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const verificationClusterSuffix = "-verify"
+
+// verificationPollPeriod is the period the throwaway cluster is checked at while the snapshot is restored
+var verificationPollPeriod = 10 * time.Second
+
+// queryPod runs a command in the cassandra container of a pod and returns its output. It is a variable so that tests
+// can replace it
+var queryPod = queryOnPod
+
+func queryOnPod(pod *corev1.Pod, cmd []string) (string, error) {
+	k8s.InitClient()
+	stdout, stderr, err := k8s.ExecPod(pod.Namespace, pod, cmd)
+	if err != nil {
+		return "", fmt.Errorf("%s failed on pod %s: %v %s", cmd[0], pod.Name, err, stderr)
+	}
+	return stdout, nil
+}
+
+// execCQL runs a CQL statement on a pod of a cluster with the spec of cc and returns the output of cqlsh. It is a
+// variable so that tests can replace it
+var execCQL = execCQLOnPod
+
+// execCQLOnPod runs a CQL statement with cqlsh in the cassandra container of the pod. The credentials are given on the
+// standard input of cqlsh, not in its arguments
+func execCQLOnPod(cc *api.CassandraCluster, pod *corev1.Pod, username, password, statement string) (string, error) {
+	k8s.InitClient()
+	cqlshArgs := []string{"--request-timeout=600"}
+	if cc.Spec.TLS != nil && cc.Spec.TLS.ClientEncryption {
+		cqlshArgs = append(cqlshArgs, "--ssl")
+	}
+	stdout, stderr, err := k8s.ExecCQL(pod, username, password, statement, append(cqlshArgs, pod.Status.PodIP)...)
+	if err != nil {
+		return "", fmt.Errorf("cqlsh failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	return stdout, nil
+}
+
+// verificationFailure is a check of the data restored which failed, as opposed to a check which can't run yet
+type verificationFailure struct {
+	message string
+}
+
+func (failure verificationFailure) Error() string {
+	return failure.message
+}
+
+// verificationCluster returns the throwaway cluster a snapshot of a backup is restored in. It has the spec of the
+// cluster backed up, restricted to the datacenters backed up with all their racks, so that each node snapshotted is
+// restored in the node with the same dc, rack and ordinal
+func verificationCluster(backup *api.CassandraBackup, cc *api.CassandraCluster,
+	snapshotTag string) *api.CassandraCluster {
+	spec := cc.Spec.DeepCopy()
+	spec.Topology.DC = nil
+	for _, dc := range cc.Spec.Topology.DC {
+		if backup.Spec.Datacenter != "" && dc.Name != backup.Spec.Datacenter {
+			continue
+		}
+		spec.Topology.DC = append(spec.Topology.DC, *dc.DeepCopy())
+	}
+	spec.RestoreFrom = &api.RestoreFrom{
+		StorageLocation: backup.Spec.StorageLocation,
+		SnapshotTag:     snapshotTag,
+		Secret:          backup.Spec.Secret,
+		ClusterName:     cc.Name,
+	}
+	spec.DeletePVC = true
+	// The roles and the keyspaces restored are left as they were backed up
+	spec.Auth = nil
+	spec.Keyspaces = nil
+	spec.Repair = nil
+	spec.CommitlogArchiving = false
+
+	controller := true
+	return &api.CassandraCluster{
+		TypeMeta: metav1.TypeMeta{Kind: "CassandraCluster", APIVersion: api.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backup.Name + verificationClusterSuffix,
+			Namespace: backup.Namespace,
+			Labels:    map[string]string{api.LabelCassandraBackup: backup.Name},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: api.GroupVersion.String(),
+				Kind:       "CassandraBackup",
+				Name:       backup.Name,
+				UID:        backup.UID,
+				Controller: &controller,
+			}},
+		},
+		Spec: *spec,
+	}
+}
+
+// verifySnapshot restores the snapshot of the run in a throwaway cluster, checks the data restored and deletes the
+// cluster. The result is recorded in the status of the backup and of its run
+func (backupClient *backupClient) verifySnapshot(logging *logrus.Entry, recorder record.EventRecorder) {
+	cassandraBackup := backupClient.backup
+	cluster := verificationCluster(cassandraBackup, backupClient.cluster, backupClient.snapshotTag)
+	logging = logging.WithFields(logrus.Fields{"snapshot": backupClient.snapshotTag,
+		"verificationCluster": cluster.Name})
+
+	backupClient.setVerification(api.Verifying, cluster.Name, "Restoring the snapshot", logging)
+	recorder.Event(cassandraBackup, corev1.EventTypeNormal, "BackupVerificationStarted",
+		fmt.Sprintf("Snapshot %s is being restored in cluster %s", backupClient.snapshotTag, cluster.Name))
+
+	message, err := backupClient.restoreAndVerify(cluster, logging)
+
+	if deleteErr := backupClient.deleteVerificationCluster(cluster); deleteErr != nil {
+		logging.Error(deleteErr, "Unable to delete the verification cluster")
+	}
+
+	if err != nil {
+		logging.Error(err, "Snapshot verification failed")
+		backupClient.setVerification(api.VerificationFailed, cluster.Name, err.Error(), logging)
+		recorder.Event(cassandraBackup, corev1.EventTypeWarning, "BackupVerificationFailed",
+			fmt.Sprintf("Snapshot %s could not be verified: %s", backupClient.snapshotTag, err.Error()))
+		return
+	}
+
+	backupClient.setVerification(api.Verified, cluster.Name, message, logging)
+	recorder.Event(cassandraBackup, corev1.EventTypeNormal, "BackupVerified",
+		fmt.Sprintf("Snapshot %s was restored and verified: %s", backupClient.snapshotTag, message))
+}
+
+// restoreAndVerify creates the throwaway cluster and checks its data once restored. The checks are run again until
+// they pass, fail or the verification times out
+func (backupClient *backupClient) restoreAndVerify(cluster *api.CassandraCluster,
+	logging *logrus.Entry) (string, error) {
+	timeout, err := backupClient.backup.Spec.Verification.VerificationTimeout()
+	if err != nil {
+		return "", err
+	}
+	deadline := time.Now().Add(timeout)
+
+	// A cluster left by a previous verification is deleted first
+	for {
+		err := backupClient.client.Create(context.TODO(), cluster.DeepCopy())
+		if err == nil {
+			break
+		}
+		if !k8sErrors.IsAlreadyExists(err) || time.Now().After(deadline) {
+			return "", err
+		}
+		if err := backupClient.deleteVerificationCluster(cluster); err != nil {
+			return "", err
+		}
+		time.Sleep(verificationPollPeriod)
+	}
+
+	lastErr := errors.New("the snapshot is not restored")
+	for ; time.Now().Before(deadline); time.Sleep(verificationPollPeriod) {
+		pod, err := backupClient.restoredPod(cluster)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		message, err := verifyData(pod, backupClient.cluster, backupClient.backup.Spec.Verification,
+			backupClient.credentials)
+		if err == nil {
+			return message, nil
+		}
+		var failure verificationFailure
+		if errors.As(err, &failure) {
+			return "", err
+		}
+		logging.WithFields(logrus.Fields{"pod": pod.Name}).Infof("Data restored can't be verified yet: %v", err)
+		lastErr = err
+	}
+	return "", fmt.Errorf("not verified after %s: %v", timeout, lastErr)
+}
+
+// restoredPod returns a ready pod of the throwaway cluster once it has restored the snapshot
+func (backupClient *backupClient) restoredPod(cluster *api.CassandraCluster) (*corev1.Pod, error) {
+	restored := &api.CassandraCluster{}
+	if err := backupClient.client.Get(context.TODO(),
+		types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, restored); err != nil {
+		return nil, err
+	}
+	if !meta.IsStatusConditionTrue(restored.Status.Conditions, api.ConditionRestored) {
+		return nil, errors.New("the snapshot is not restored")
+	}
+
+	pods := &corev1.PodList{}
+	if err := backupClient.client.List(context.TODO(), pods, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels(k8s.LabelsForCassandra(restored))); err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if podIsReady(&pods.Items[i]) {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, errors.New("no pod of the cluster restored is ready")
+}
+
+func podIsReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// credentials returns the CQL credentials of the cluster backed up, which are restored with system_auth: the username
+// and password of the Secret of its spec.auth.superuserSecret
+func (backupClient *backupClient) credentials() (string, string, error) {
+	auth := backupClient.cluster.Spec.Auth
+	if auth == nil {
+		return "", "", verificationFailure{fmt.Sprintf(
+			"tables can't be counted as cluster %s has no spec.auth.superuserSecret", backupClient.cluster.Name)}
+	}
+
+	secret := &corev1.Secret{}
+	if err := backupClient.client.Get(context.TODO(),
+		types.NamespacedName{Name: auth.SuperuserSecret, Namespace: backupClient.cluster.Namespace},
+		secret); err != nil {
+		return "", "", err
+	}
+	var credentials []string
+	for _, key := range []string{"username", "password"} {
+		value, ok := secret.Data[key]
+		if !ok {
+			return "", "", verificationFailure{fmt.Sprintf("key %s not found in secret %s", key, secret.Name)}
+		}
+		credentials = append(credentials, string(value))
+	}
+	return credentials[0], credentials[1], nil
+}
+
+// verifyData checks that the nodes restored agree on the schema and counts the rows of the tables of the
+// verification. It returns the rows counted
+func verifyData(pod *corev1.Pod, cc *api.CassandraCluster, verification *api.BackupVerification,
+	credentials func() (string, string, error)) (string, error) {
+	output, err := queryPod(pod, []string{"nodetool", "describecluster"})
+	if err != nil {
+		return "", err
+	}
+	schemaVersions, unreachable := parseSchemaVersions(output)
+	if unreachable {
+		return "", errors.New("nodes of the cluster restored are unreachable")
+	}
+	if len(schemaVersions) != 1 {
+		return "", verificationFailure{fmt.Sprintf("nodes restored have %d schema versions: %s",
+			len(schemaVersions), strings.Join(schemaVersions, ", "))}
+	}
+
+	counts := []string{"schema version " + schemaVersions[0]}
+	if len(verification.Tables) == 0 {
+		return counts[0], nil
+	}
+
+	username, password, err := credentials()
+	if err != nil {
+		return "", err
+	}
+	for _, table := range verification.Tables {
+		output, err := execCQL(cc, pod, username, password, "CONSISTENCY ALL; SELECT count(*) FROM "+table.Name+";")
+		if err != nil {
+			return "", err
+		}
+		rows, err := parseCount(output)
+		if err != nil {
+			return "", err
+		}
+		if rows < table.MinRows {
+			return "", verificationFailure{fmt.Sprintf("table %s has %d rows instead of at least %d", table.Name,
+				rows, table.MinRows)}
+		}
+		counts = append(counts, fmt.Sprintf("%s %d rows", table.Name, rows))
+	}
+	return strings.Join(counts, ", "), nil
+}
+
+// parseSchemaVersions returns the schema versions listed by nodetool describecluster and whether nodes are
+// unreachable
+func parseSchemaVersions(output string) (versions []string, unreachable bool) {
+	inVersions := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "Schema versions:" {
+			inVersions = true
+			continue
+		}
+		if !inVersions || !strings.Contains(line, ": [") {
+			continue
+		}
+		version := strings.SplitN(line, ":", 2)[0]
+		if version == "UNREACHABLE" {
+			unreachable = true
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return
+}
+
+// parseCount returns the count of a SELECT count(*) printed by cqlsh, below its header
+func parseCount(output string) (int64, error) {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "---") && i+1 < len(lines) {
+			return strconv.ParseInt(strings.TrimSpace(lines[i+1]), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no count in cqlsh output %q", output)
+}
+
+func (backupClient *backupClient) setVerification(state api.VerificationState, cluster, message string,
+	logging *logrus.Entry) {
+	status := backupClient.backup.Status.DeepCopy()
+	status.Verification = &api.BackupVerificationStatus{
+		SnapshotTag:        backupClient.snapshotTag,
+		State:              state,
+		Cluster:            cluster,
+		Message:            message,
+		LastTransitionTime: metav1.Now().Format(util.TimeStampLayout),
+	}
+	backupClient.updateStatus(*status, logging)
+}
+
+func (backupClient *backupClient) deleteVerificationCluster(cluster *api.CassandraCluster) error {
+	if err := backupClient.client.Delete(context.TODO(), cluster.DeepCopy()); err != nil &&
+		!k8sErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
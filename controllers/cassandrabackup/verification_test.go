@@ -0,0 +1,149 @@
+package cassandrabackup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const describeCluster = `Cluster Information:
+	Name: test-cluster
+	Snitch: org.apache.cassandra.locator.GossipingPropertyFileSnitch
+	DynamicEndPointSnitch: enabled
+	Partitioner: org.apache.cassandra.dht.Murmur3Partitioner
+	Schema versions:
+		59adb24e-f3cd-3e02-97f0-5b395827453f: [10.0.0.1, 10.0.0.2]
+`
+
+func TestVerificationCluster(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := &api.CassandraCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+		Spec: api.CassandraClusterSpec{
+			NodesPerRacks: 2,
+			Auth:          &api.Auth{SuperuserSecret: "superuser"},
+			Topology: api.Topology{DC: api.DCSlice{
+				{Name: "dc1", Rack: api.RackSlice{{Name: "rack1"}, {Name: "rack2"}}},
+				{Name: "dc2", Rack: api.RackSlice{{Name: "rack1"}}},
+			}},
+		},
+	}
+	cb := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", UID: "uid"},
+		Spec: api.CassandraBackupSpec{CassandraCluster: "test-cluster", Datacenter: "dc1",
+			StorageLocation: "s3://cassie", SnapshotTag: "daily", Secret: "cloud-backup-secrets"},
+	}
+
+	cluster := verificationCluster(cb, cc, "daily-20210315-000000")
+	assert.Equal("nightly-verify", cluster.Name)
+	assert.Equal("default", cluster.Namespace)
+	assert.Equal("nightly", cluster.OwnerReferences[0].Name)
+	// All the racks of the datacenter backed up are restored
+	assert.Equal(api.DCSlice{{Name: "dc1", Rack: api.RackSlice{{Name: "rack1"}, {Name: "rack2"}}}},
+		cluster.Spec.Topology.DC)
+	assert.Equal(int32(2), cluster.Spec.NodesPerRacks)
+	assert.Equal(&api.RestoreFrom{StorageLocation: "s3://cassie", SnapshotTag: "daily-20210315-000000",
+		Secret: "cloud-backup-secrets", ClusterName: "test-cluster"}, cluster.Spec.RestoreFrom)
+	assert.True(cluster.Spec.DeletePVC)
+	assert.Nil(cluster.Spec.Auth)
+	// The cluster backed up is left untouched
+	cluster.Spec.Topology.DC[0].Rack[1].Name = "rack3"
+	assert.Equal("rack2", cc.Spec.Topology.DC[0].Rack[1].Name)
+
+	cb.Spec.Datacenter = ""
+	cluster = verificationCluster(cb, cc, "daily-20210315-000000")
+	assert.Equal(2, len(cluster.Spec.Topology.DC))
+}
+
+func TestVerifyData(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		queryPod = queryOnPod
+		execCQL = execCQLOnPod
+	}()
+
+	cc := &api.CassandraCluster{}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nightly-verify-dc1-rack1-0"},
+		Status: corev1.PodStatus{PodIP: "10.0.0.1"}}
+	verification := &api.BackupVerification{Tables: []api.TableVerification{
+		{Name: "ks1.t1", MinRows: 10}, {Name: "ks2.t2"}}}
+	credentials := func() (string, string, error) { return "admin", "secret", nil }
+
+	var statements []string
+	queryPod = func(pod *corev1.Pod, cmd []string) (string, error) {
+		return describeCluster, nil
+	}
+	execCQL = func(cc *api.CassandraCluster, pod *corev1.Pod, username, password, statement string) (string,
+		error) {
+		statements = append(statements, username+"/"+password+" "+statement)
+		if strings.HasSuffix(statement, "ks1.t1;") {
+			return "Consistency level set to ALL.\n\n count\n-------\n    42\n\n(1 rows)\n", nil
+		}
+		return "Consistency level set to ALL.\n\n count\n-------\n     3\n\n(1 rows)\n", nil
+	}
+
+	message, err := verifyData(pod, cc, verification, credentials)
+	assert.Nil(err)
+	assert.Equal("schema version 59adb24e-f3cd-3e02-97f0-5b395827453f, ks1.t1 42 rows, ks2.t2 3 rows", message)
+	assert.Equal([]string{"admin/secret CONSISTENCY ALL; SELECT count(*) FROM ks1.t1;",
+		"admin/secret CONSISTENCY ALL; SELECT count(*) FROM ks2.t2;"}, statements)
+
+	// The credentials are only needed to count rows
+	noCredentials := func() (string, string, error) { return "", "", errors.New("no credentials") }
+	message, err = verifyData(pod, cc, &api.BackupVerification{}, noCredentials)
+	assert.Nil(err)
+	assert.Equal("schema version 59adb24e-f3cd-3e02-97f0-5b395827453f", message)
+
+	verification.Tables[1].MinRows = 5
+	_, err = verifyData(pod, cc, verification, credentials)
+	assert.IsType(verificationFailure{}, err)
+	assert.EqualError(err, "table ks2.t2 has 3 rows instead of at least 5")
+
+	// Nodes restarting are checked again later
+	queryPod = func(pod *corev1.Pod, cmd []string) (string, error) {
+		return describeCluster + "\t\tUNREACHABLE: [10.0.0.3]\n", nil
+	}
+	_, err = verifyData(pod, cc, verification, credentials)
+	assert.NotNil(err)
+	assert.NotEqual(verificationFailure{}, err)
+
+	queryPod = func(pod *corev1.Pod, cmd []string) (string, error) {
+		return describeCluster + "\t\t86afa796-d883-3932-aa73-6b017cef0d19: [10.0.0.3]\n", nil
+	}
+	_, err = verifyData(pod, cc, verification, credentials)
+	assert.IsType(verificationFailure{}, err)
+}
+
+func TestCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := &api.CassandraCluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "superuser", Namespace: "default"},
+		Data: map[string][]byte{"username": []byte("admin")}}
+	backupClient := &backupClient{client: fake.NewFakeClientWithScheme(scheme.Scheme, secret), cluster: cc}
+
+	// Without the superuser of spec.auth, the tables restored can't be counted
+	_, _, err := backupClient.credentials()
+	assert.IsType(verificationFailure{}, err)
+	assert.EqualError(err, "tables can't be counted as cluster test-cluster has no spec.auth.superuserSecret")
+
+	cc.Spec.Auth = &api.Auth{SuperuserSecret: "superuser"}
+	_, _, err = backupClient.credentials()
+	assert.EqualError(err, "key password not found in secret superuser")
+
+	secret.Data["password"] = []byte("secret")
+	assert.Nil(backupClient.client.Update(context.TODO(), secret))
+	username, password, err := backupClient.credentials()
+	assert.Nil(err)
+	assert.Equal("admin", username)
+	assert.Equal("secret", password)
+}
@@ -0,0 +1,344 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultSuperuser         = "cassandra"
+	defaultSuperuserPassword = "cassandra"
+	// systemAuthMaxReplicas is the replication factor of system_auth in the dcs having enough nodes
+	systemAuthMaxReplicas = 3
+	systemAuthKeyspace    = "system_auth"
+)
+
+// execCQL runs a CQL statement on a Cassandra pod. It is a variable so that tests can replace it
+var execCQL = execCQLOnPod
+
+// execCQLOnPod runs a CQL statement with cqlsh in the cassandra container of the pod. The credentials and the
+// statement, which can hold the password of a role, are given on the standard input of cqlsh
+func execCQLOnPod(cc *api.CassandraCluster, pod *v1.Pod, username, password, statement string) error {
+	k8s.InitClient()
+	var cqlshArgs []string
+	if cc.Spec.TLS != nil && cc.Spec.TLS.ClientEncryption {
+		cqlshArgs = append(cqlshArgs, "--ssl")
+	}
+	_, stderr, err := k8s.ExecCQL(pod, username, password, statement, append(cqlshArgs, pod.Status.PodIP)...)
+	if err != nil {
+		return fmt.Errorf("cqlsh failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	return nil
+}
+
+// cqlIdentifier quotes a role name for a CQL statement
+func cqlIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// cqlString quotes a string for a CQL statement
+func cqlString(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// dcNodes returns the number of nodes of a dc in the topology, 0 if it is not part of it
+func dcNodes(cc *api.CassandraCluster, dcName string) int32 {
+	dcSize := cc.GetDCSize()
+	if dcSize < 1 {
+		dcSize = 1
+	}
+	for dc := 0; dc < dcSize; dc++ {
+		if cc.GetDCName(dc) != dcName {
+			continue
+		}
+		_, nodesPerRacks := cc.GetDCNodesPerRacksFromName(dcName)
+		rackSize := int32(cc.GetRackSize(dc))
+		if rackSize < 1 {
+			rackSize = 1
+		}
+		return nodesPerRacks * rackSize
+	}
+	return 0
+}
+
+// replicationString returns the NetworkTopologyStrategy replication of a CQL statement, with dcs sorted by name
+func replicationString(replicas map[string]int32) string {
+	var dcNames []string
+	for dcName := range replicas {
+		dcNames = append(dcNames, dcName)
+	}
+	sort.Strings(dcNames)
+
+	replication := "{'class': 'NetworkTopologyStrategy'"
+	for _, dcName := range dcNames {
+		replication += fmt.Sprintf(", %s: %d", cqlString(dcName), replicas[dcName])
+	}
+	return replication + "}"
+}
+
+// systemAuthReplication returns the replication of system_auth matching the topology: up to 3 replicas per dc
+func systemAuthReplication(cc *api.CassandraCluster) string {
+	dcSize := cc.GetDCSize()
+	if dcSize < 1 {
+		dcSize = 1
+	}
+	replicas := map[string]int32{}
+	for dc := 0; dc < dcSize; dc++ {
+		dcName := cc.GetDCName(dc)
+		nodes := dcNodes(cc, dcName)
+		if nodes == 0 {
+			continue
+		}
+		if nodes > systemAuthMaxReplicas {
+			nodes = systemAuthMaxReplicas
+		}
+		replicas[dcName] = nodes
+	}
+	return replicationString(replicas)
+}
+
+// roleOptions returns the options of a CREATE or ALTER ROLE statement
+func roleOptions(role api.Role, password string) string {
+	options := fmt.Sprintf("LOGIN = %t AND SUPERUSER = %t", role.Login, role.Superuser)
+	if password != "" {
+		options = fmt.Sprintf("PASSWORD = %s AND %s", cqlString(password), options)
+	}
+	return options
+}
+
+// roleHash returns the hash of what is applied for a role, to only update the roles which have changed. The
+// password is not part of it as the status can be read by anyone reading the cluster, the version of its Secret is
+func roleHash(role api.Role, secretVersion string) string {
+	data, _ := json.Marshal(role)
+	hash := sha256.New()
+	hash.Write(data)
+	hash.Write([]byte(secretVersion))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (rcc *CassandraClusterReconciler) readSecretKey(namespace, name, key string) (string, error) {
+	value, _, err := rcc.readVersionedSecretKey(namespace, name, key)
+	return value, err
+}
+
+// readVersionedSecretKey returns the value of a key of a Secret and the resourceVersion of the Secret
+func (rcc *CassandraClusterReconciler) readVersionedSecretKey(namespace, name, key string) (string, string, error) {
+	secret := &v1.Secret{}
+	if err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace},
+		secret); err != nil {
+		return "", "", err
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", "", fmt.Errorf("key %s not found in secret %s", key, name)
+	}
+	return string(value), secret.ResourceVersion, nil
+}
+
+// superuserCredentials returns the username and password of the superuser of spec.auth
+func (rcc *CassandraClusterReconciler) superuserCredentials(cc *api.CassandraCluster) (string, string, error) {
+	username, err := rcc.readSecretKey(cc.Namespace, cc.Spec.Auth.SuperuserSecret, "username")
+	if err != nil {
+		return "", "", err
+	}
+	if username == defaultSuperuser {
+		return "", "", fmt.Errorf("the superuser of secret %s must not be %s", cc.Spec.Auth.SuperuserSecret,
+			defaultSuperuser)
+	}
+	password, err := rcc.readSecretKey(cc.Namespace, cc.Spec.Auth.SuperuserSecret, "password")
+	return username, password, err
+}
+
+// cqlCredentials returns the credentials the operator connects with: the default superuser until it is replaced.
+// When the superuser is renamed in its Secret, the previous one is used with the password of the Secret until the
+// new one is created
+func (rcc *CassandraClusterReconciler) cqlCredentials(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) (string, string, error) {
+	if cc.Spec.Auth == nil || status.Auth == nil || !status.Auth.SuperuserReplaced {
+		return defaultSuperuser, defaultSuperuserPassword, nil
+	}
+	username, password, err := rcc.superuserCredentials(cc)
+	if err == nil && status.Auth.Superuser != "" {
+		username = status.Auth.Superuser
+	}
+	return username, password, err
+}
+
+func (rcc *CassandraClusterReconciler) readyCassandraPod(cc *api.CassandraCluster) (*v1.Pod, error) {
+	podsList, err := rcc.ListPods(cc.Namespace, k8s.LabelsForCassandra(cc))
+	if err != nil {
+		return nil, err
+	}
+	for i := range podsList.Items {
+		if cassandraPodIsReady(&podsList.Items[i]) {
+			return &podsList.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no cassandra pod is ready")
+}
+
+// ReconcileAuth applies spec.auth once the cluster is running: it aligns the replication of system_auth with the
+// topology and repairs it, replaces the default cassandra superuser and creates the declared roles with their
+// grants. Roles removed from the spec are not dropped and grants removed from a role are not revoked
+func (rcc *CassandraClusterReconciler) ReconcileAuth(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	if cc.Spec.Auth == nil || status.Phase != api.ClusterPhaseRunning.Name {
+		return nil
+	}
+	if status.Auth == nil {
+		status.Auth = &api.AuthStatus{}
+	}
+
+	username, password, err := rcc.superuserCredentials(cc)
+	if err != nil {
+		return err
+	}
+	//The superuser replaced by an older operator is the one of the Secret
+	if status.Auth.SuperuserReplaced && status.Auth.Superuser == "" {
+		status.Auth.Superuser = username
+	}
+	currentUsername, currentPassword, err := rcc.cqlCredentials(cc, status)
+	if err != nil {
+		return err
+	}
+
+	//system_auth is replicated and repaired first so that the roles are found on all their replicas
+	if status.Auth.SystemAuthReplicationPending != "" {
+		if repaired := rcc.systemAuthRepaired(cc, status); !repaired {
+			return nil
+		}
+		status.Auth.SystemAuthReplication = status.Auth.SystemAuthReplicationPending
+		status.Auth.SystemAuthReplicationPending = ""
+	}
+
+	pod, err := rcc.readyCassandraPod(cc)
+	if err != nil {
+		return err
+	}
+
+	replication := systemAuthReplication(cc)
+	if replication != status.Auth.SystemAuthReplication {
+		//A repair of the cluster is left to end first
+		if status.Repair != nil && status.Repair.DCRack != "" {
+			return nil
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name,
+			"replication": replication}).Info("Update replication of keyspace system_auth")
+		if err = execCQL(cc, pod, currentUsername, currentPassword,
+			"ALTER KEYSPACE "+systemAuthKeyspace+" WITH replication = "+replication); err != nil {
+			return err
+		}
+		status.Auth.SystemAuthReplicationPending = replication
+		rcc.startUnscheduledRepair(cc, status, []string{systemAuthKeyspace})
+		return nil
+	}
+
+	if !status.Auth.SuperuserReplaced || status.Auth.Superuser != username {
+		if err = rcc.replaceSuperuser(cc, pod, currentUsername, currentPassword, username, password); err != nil {
+			return err
+		}
+		status.Auth.SuperuserReplaced = true
+		status.Auth.Superuser = username
+	}
+
+	if status.Auth.Roles == nil {
+		status.Auth.Roles = map[string]string{}
+	}
+	for _, role := range cc.Spec.Auth.Roles {
+		rolePassword, secretVersion := "", ""
+		if role.PasswordSecret != "" {
+			if rolePassword, secretVersion, err = rcc.readVersionedSecretKey(cc.Namespace, role.PasswordSecret,
+				"password"); err != nil {
+				return err
+			}
+		}
+		hash := roleHash(role, secretVersion)
+		if status.Auth.Roles[role.Name] == hash {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "role": role.Name}).Info("Apply role")
+		statements := []string{
+			fmt.Sprintf("CREATE ROLE IF NOT EXISTS %s WITH %s", cqlIdentifier(role.Name),
+				roleOptions(role, rolePassword)),
+			fmt.Sprintf("ALTER ROLE %s WITH %s", cqlIdentifier(role.Name), roleOptions(role, rolePassword)),
+		}
+		for _, grant := range role.Grants {
+			statements = append(statements, fmt.Sprintf("GRANT %s TO %s", grant, cqlIdentifier(role.Name)))
+		}
+		for _, statement := range statements {
+			if err = execCQL(cc, pod, username, password, statement); err != nil {
+				return err
+			}
+		}
+		status.Auth.Roles[role.Name] = hash
+	}
+	return nil
+}
+
+// replaceSuperuser creates the superuser of spec.auth with the current one, the default cassandra superuser or the
+// one renamed in the Secret, then drops the latter
+func (rcc *CassandraClusterReconciler) replaceSuperuser(cc *api.CassandraCluster, pod *v1.Pod,
+	currentUsername, currentPassword, username, password string) error {
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "superuser": username,
+		"previousSuperuser": currentUsername}).Info("Replace superuser")
+	createRole := fmt.Sprintf("CREATE ROLE IF NOT EXISTS %s WITH PASSWORD = %s AND SUPERUSER = true AND LOGIN = true",
+		cqlIdentifier(username), cqlString(password))
+	if err := execCQL(cc, pod, currentUsername, currentPassword, createRole); err != nil {
+		//The current superuser may already have been dropped if the status was lost, we check the new one works
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
+			"Can't create superuser with %s, trying the new one: %v", currentUsername, err)
+		if err = execCQL(cc, pod, username, password, createRole); err != nil {
+			return fmt.Errorf("can't create superuser %s of secret %s with superuser %s nor log in with it: %v",
+				username, cc.Spec.Auth.SuperuserSecret, currentUsername, err)
+		}
+	}
+	if currentUsername == username {
+		return nil
+	}
+	return execCQL(cc, pod, username, password, "DROP ROLE IF EXISTS "+cqlIdentifier(currentUsername))
+}
+
+// systemAuthRepaired returns whether the repair of system_auth started once its replication changed has ended. A
+// repair which failed is started again
+func (rcc *CassandraClusterReconciler) systemAuthRepaired(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) bool {
+	repair := status.Repair
+	if repair != nil && repair.DCRack != "" {
+		return false
+	}
+	if repair != nil && len(repair.Keyspaces) == 1 && repair.Keyspaces[0] == systemAuthKeyspace && !repair.Failed {
+		lastEnded, ended := repair.LastEndedRepairs[systemAuthKeyspace]
+		if ended && lastEnded.Equal(repair.UnscheduledStartTime) {
+			return true
+		}
+	}
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warn(
+		"Repair of keyspace system_auth has failed or is unknown, start it again")
+	rcc.startUnscheduledRepair(cc, status, []string{systemAuthKeyspace})
+	return false
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type cqlStatement struct {
+	username  string
+	statement string
+}
+
+func helperMockExecCQL(t *testing.T, failingUser string) *[]cqlStatement {
+	var statements []cqlStatement
+	previousExecCQL := execCQL
+	t.Cleanup(func() { execCQL = previousExecCQL })
+	execCQL = func(cc *api.CassandraCluster, pod *v1.Pod, username, password, statement string) error {
+		if username == failingUser {
+			return fmt.Errorf("bad credentials")
+		}
+		statements = append(statements, cqlStatement{username, statement})
+		return nil
+	}
+	return &statements
+}
+
+func helperInitAuthCluster(t *testing.T) (*CassandraClusterReconciler, *api.CassandraCluster,
+	*api.CassandraClusterStatus) {
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.Auth = &api.Auth{
+		SuperuserSecret: "cassandra-superuser",
+		Roles: []api.Role{
+			{Name: "app", PasswordSecret: "cassandra-app", Login: true,
+				Grants: []string{"SELECT ON KEYSPACE demo", "MODIFY ON KEYSPACE demo"}},
+		},
+	}
+	status := cc.Status.DeepCopy()
+	status.Phase = api.ClusterPhaseRunning.Name
+
+	rcc.Client.Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-superuser", Namespace: cc.Namespace},
+		Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+	})
+	rcc.Client.Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-app", Namespace: cc.Namespace},
+		Data:       map[string][]byte{"password": []byte("app'pwd")},
+	})
+	rcc.Client.Create(context.TODO(), &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-demo-dc1-rack1-0", Namespace: cc.Namespace,
+			Labels: k8s.LabelsForCassandraDCRack(cc, "dc1", "rack1")},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{{Name: cassandraContainerName, Ready: true}},
+		},
+	})
+	return rcc, cc, status
+}
+
+// helperEndSystemAuthRepair ends the repair of system_auth started by ReconcileAuth on all the racks
+func helperEndSystemAuthRepair(status *api.CassandraClusterStatus, failed bool) {
+	status.Repair.DCRack = ""
+	status.Repair.Failed = failed
+	status.Repair.LastEndedRepairs = recordRepair(status.Repair.LastEndedRepairs, status.Repair.Keyspaces,
+		*status.Repair.UnscheduledStartTime)
+}
+
+func TestSystemAuthReplication(t *testing.T) {
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	assert.Equal(t, "{'class': 'NetworkTopologyStrategy', 'dc1': 2, 'dc2': 1}", systemAuthReplication(cc))
+
+	cc.Spec.NodesPerRacks = 4
+	cc.Spec.Topology.DC[1].NodesPerRacks = func(i int32) *int32 { return &i }(0)
+	assert.Equal(t, "{'class': 'NetworkTopologyStrategy', 'dc1': 3}", systemAuthReplication(cc))
+}
+
+func TestReconcileAuth(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc, status := helperInitAuthCluster(t)
+	statements := helperMockExecCQL(t, "")
+
+	//system_auth is repaired once its replication has changed
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	replication := "{'class': 'NetworkTopologyStrategy', 'dc1': 2, 'dc2': 1}"
+	assert.Equal([]cqlStatement{{"cassandra", "ALTER KEYSPACE system_auth WITH replication = " + replication}},
+		*statements)
+	assert.Equal("", status.Auth.SystemAuthReplication)
+	assert.Equal(replication, status.Auth.SystemAuthReplicationPending)
+	assert.Equal("dc1-rack1", status.Repair.DCRack)
+	assert.Equal([]string{"system_auth"}, status.Repair.Keyspaces)
+	assert.Nil(status.Repair.StartTime)
+
+	//Nothing else is applied until the repair ends
+	*statements = nil
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Empty(*statements)
+
+	//A failed repair is started again
+	helperEndSystemAuthRepair(status, true)
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Empty(*statements)
+	assert.Equal("dc1-rack1", status.Repair.DCRack)
+	assert.False(status.Repair.Failed)
+
+	helperEndSystemAuthRepair(status, false)
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Equal(replication, status.Auth.SystemAuthReplication)
+	assert.Equal("", status.Auth.SystemAuthReplicationPending)
+	assert.Equal([]cqlStatement{
+		{"cassandra", `CREATE ROLE IF NOT EXISTS "admin" WITH PASSWORD = 'secret' AND SUPERUSER = true AND LOGIN = true`},
+		{"admin", `DROP ROLE IF EXISTS "cassandra"`},
+		{"admin", `CREATE ROLE IF NOT EXISTS "app" WITH PASSWORD = 'app''pwd' AND LOGIN = true AND SUPERUSER = false`},
+		{"admin", `ALTER ROLE "app" WITH PASSWORD = 'app''pwd' AND LOGIN = true AND SUPERUSER = false`},
+		{"admin", `GRANT SELECT ON KEYSPACE demo TO "app"`},
+		{"admin", `GRANT MODIFY ON KEYSPACE demo TO "app"`},
+	}, *statements)
+	assert.True(status.Auth.SuperuserReplaced)
+	assert.Equal("admin", status.Auth.Superuser)
+
+	//The hash of a role depends on the version of its password Secret, not on the password
+	secret := &v1.Secret{}
+	rcc.Client.Get(context.TODO(), types.NamespacedName{Name: "cassandra-app", Namespace: cc.Namespace}, secret)
+	assert.Equal(roleHash(cc.Spec.Auth.Roles[0], secret.ResourceVersion), status.Auth.Roles["app"])
+
+	//Nothing has changed, nothing is applied
+	*statements = nil
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Empty(*statements)
+
+	//A new grant is applied with the new superuser
+	cc.Spec.Auth.Roles[0].Grants = append(cc.Spec.Auth.Roles[0].Grants, "SELECT ON KEYSPACE other")
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Equal(5, len(*statements))
+	assert.Equal(cqlStatement{"admin", `GRANT SELECT ON KEYSPACE other TO "app"`}, (*statements)[4])
+
+	//A new password in the Secret is applied
+	*statements = nil
+	secret.Data["password"] = []byte("new")
+	rcc.Client.Update(context.TODO(), secret)
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Equal(5, len(*statements))
+	assert.Equal(cqlStatement{"admin", `ALTER ROLE "app" WITH PASSWORD = 'new' AND LOGIN = true AND SUPERUSER = false`},
+		(*statements)[1])
+}
+
+func TestReconcileAuthWhenSuperuserIsRenamed(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc, status := helperInitAuthCluster(t)
+	cc.Spec.Auth.Roles = nil
+	//The superuser was replaced by an older operator which did not record its name
+	status.Auth = &api.AuthStatus{SystemAuthReplication: systemAuthReplication(cc), SuperuserReplaced: true}
+	statements := helperMockExecCQL(t, "")
+
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Empty(*statements)
+	assert.Equal("admin", status.Auth.Superuser)
+
+	//A new superuser in the Secret is created with the previous one, which is then dropped
+	secret := &v1.Secret{}
+	rcc.Client.Get(context.TODO(), types.NamespacedName{Name: "cassandra-superuser", Namespace: cc.Namespace}, secret)
+	secret.Data["username"] = []byte("root")
+	rcc.Client.Update(context.TODO(), secret)
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Equal([]cqlStatement{
+		{"admin", `CREATE ROLE IF NOT EXISTS "root" WITH PASSWORD = 'secret' AND SUPERUSER = true AND LOGIN = true`},
+		{"root", `DROP ROLE IF EXISTS "admin"`},
+	}, *statements)
+	assert.Equal("root", status.Auth.Superuser)
+
+	//A superuser which can't be created with the previous one nor log in is an error
+	execCQL = func(cc *api.CassandraCluster, pod *v1.Pod, username, password, statement string) error {
+		return fmt.Errorf("bad credentials")
+	}
+	secret.Data["username"] = []byte("other")
+	rcc.Client.Update(context.TODO(), secret)
+	assert.NotNil(rcc.ReconcileAuth(cc, status))
+	assert.Equal("root", status.Auth.Superuser)
+}
+
+func TestReconcileAuthWhenDefaultSuperuserIsAlreadyDropped(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc, status := helperInitAuthCluster(t)
+	cc.Spec.Auth.Roles = nil
+	status.Auth = &api.AuthStatus{SystemAuthReplication: systemAuthReplication(cc)}
+	statements := helperMockExecCQL(t, "cassandra")
+
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Equal(2, len(*statements))
+	assert.Equal("admin", (*statements)[0].username)
+	assert.True(status.Auth.SuperuserReplaced)
+}
+
+func TestReconcileAuthWaitsForRunningCluster(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc, status := helperInitAuthCluster(t)
+	status.Phase = api.ClusterPhaseInitial.Name
+	statements := helperMockExecCQL(t, "")
+
+	assert.Nil(rcc.ReconcileAuth(cc, status))
+	assert.Empty(*statements)
+	assert.Nil(status.Auth)
+}
@@ -34,30 +34,49 @@ var needUpdate bool
 
 //updateCassandraStatus updates the CRD if the status has changed
 //if needUpdate is set that mean that we have updated some fields in the CRD
-//This method also stored the annotation cassandraclusters.db.orange.com/last-applied-configuration with last-applied-configuration
+//This method also stores the spec accepted in the spec history, which replaces the annotation
+//cassandraclusters.db.orange.com/last-applied-configuration of older versions. It is recorded under a new revision
+//once the statefulsets have applied it
+//The status is a subresource: the spec and the metadata are updated first, the conditions of the status are then
+//computed for the generation stored
 func (rcc *CassandraClusterReconciler) updateCassandraStatus(cc *api.CassandraCluster,
 	status *api.CassandraClusterStatus) error {
-	// don't update the status if there aren't any changes.
-	if cc.Annotations == nil {
-		cc.Annotations = map[string]string{}
+	if err := rcc.acceptSpec(cc); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "err": err}).Error("Issue when updating spec history")
+	}
+	if err := rcc.recordAppliedSpec(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "err": err}).Error("Issue when updating spec history")
+	}
+	//Once the spec history holds the last spec applied, the annotation is removed
+	_, legacyAnnotation := cc.Annotations[api.AnnotationLastApplied]
+	legacyAnnotation = legacyAnnotation && status.AppliedRevision != 0
+
+	if needUpdate || legacyAnnotation {
+		needUpdate = false
+		if legacyAnnotation {
+			delete(cc.Annotations, api.AnnotationLastApplied)
+		}
+		if err := rcc.Client.Update(context.TODO(), cc); err != nil {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "err": err}).Errorf(
+				"Issue when updating CassandraCluster")
+			return err
+		}
 	}
 
-	lastApplied, _ := cc.ComputeLastAppliedConfiguration()
-
-	if !needUpdate &&
-		reflect.DeepEqual(cc.Status, *status) && //Do We need to update Status ?
-		reflect.DeepEqual(cc.Annotations[api.AnnotationLastApplied], string(lastApplied)) && //Do We need to update Annotation ?
-		cc.Annotations[api.AnnotationLastApplied] != "" {
+	updateClusterConditions(cc, status)
+	if err := rcc.markRestored(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "err": err}).Error("Issue when marking the cluster restored")
+	}
+	// don't update the status if there aren't any changes.
+	if reflect.DeepEqual(cc.Status, *status) {
 		return nil
 	}
-	needUpdate = false
 	//make also deepcopy to avoid pointer conflict
 	cc.Status = *status.DeepCopy()
-	cc.Annotations[api.AnnotationLastApplied] = string(lastApplied)
-
-	err := rcc.Client.Update(context.TODO(), cc)
+	err := rcc.Client.Status().Update(context.TODO(), cc)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "err": err}).Errorf("Issue when updating CassandraCluster")
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "err": err}).Errorf(
+			"Issue when updating status of CassandraCluster")
 	}
 	return err
 }
@@ -208,6 +227,14 @@ func UpdateStatusIfconfigMapHasChanged(cc *api.CassandraCluster, dcRackName stri
 		}
 	}
 
+	//Renewed certificates are loaded in the keystores when the pods restart
+	if cc.Spec.TLS != nil &&
+		storedStatefulSet.Spec.Template.Annotations[tlsSecretHashAnnotation] != status.TLSSecretHash {
+		logrus.Infof("[%s][%s]: We ask to change TLS certificates of Secret %s", cc.Name, dcRackName,
+			cc.Spec.TLS.SecretName)
+		updateConfigMap = true
+	}
+
 	if updateConfigMap {
 		lastAction := &status.CassandraRackStatus[dcRackName].CassandraLastAction
 		lastAction.Status = api.StatusToDo
@@ -399,6 +426,34 @@ func (rcc *CassandraClusterReconciler) UpdateStatusIfActionEnded(cc *api.Cassand
 				logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName}).Info("ScaleDown not yet Completed: Waiting for Pod operation to be Done")
 			}
 
+		case api.ActionResizeStorage.Name:
+			expanded, podsToRestart, err := rcc.pvcsExpansionStatus(storedStatefulSet)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName}).Errorf(
+					"Can't check PVCs expansion: %v", err)
+				return false
+			}
+			if expanded {
+				logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName}).Info("ResizeStorage is Done")
+				rackLastAction.Status = api.StatusDone
+				rackLastAction.EndTime = &now
+				return true
+			}
+			//Some provisioners can only resize the file system of a volume when it is mounted again,
+			//we restart those pods one at a time
+			if len(podsToRestart) > 0 && !isStatefulSetNotReady(storedStatefulSet) {
+				pod, err := rcc.GetPod(cc.Namespace, podsToRestart[0])
+				if err == nil && pod.DeletionTimestamp == nil {
+					logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName,
+						"pod": pod.Name}).Info("Restart pod to resize the file system of its volumes")
+					rcc.DeletePod(pod)
+				}
+			}
+
+		case api.ActionUpgradeCassandra.Name:
+			//Each pod is drained before it is updated to the new major version
+			return rcc.upgradeNextPod(cc, dcRackName, storedStatefulSet, status)
+
 		case api.ClusterPhaseInitial.Name:
 			ClusterPhaseMetric.set(api.ClusterPhaseInitial, cc.Name)
 			//nothing particular here
@@ -20,6 +20,8 @@ import (
 	"github.com/Orange-OpenSource/casskop/controllers/common"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"reflect"
@@ -36,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
 	"github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
 )
@@ -112,12 +115,79 @@ func HelperInitCluster(t *testing.T, name string) (*CassandraClusterReconciler,
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &ccList)
 	cl := fake.NewFakeClientWithScheme(fakeClientScheme, objs...)
 	// Create a CassandraClusterReconciler object with the scheme and fake client.
-	rcc := CassandraClusterReconciler{Client: cl, Scheme: fakeClientScheme}
+	rcc := CassandraClusterReconciler{Client: cl, Scheme: fakeClientScheme, Recorder: record.NewFakeRecorder(100)}
 
 	cc.InitCassandraRackList()
 	return &rcc, &cc
 }
 
+// statusSubresourceClient is a fake client which, as the API server, ignores the status of a CassandraCluster on
+// Update and only saves it through Status().Update
+type statusSubresourceClient struct {
+	client.Client
+}
+
+func (c statusSubresourceClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	cc, ok := obj.(*api.CassandraCluster)
+	if !ok {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+	storedCC := &api.CassandraCluster{}
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: cc.Name, Namespace: cc.Namespace}, storedCC); err != nil {
+		return err
+	}
+	cc.Status = storedCC.Status
+	return c.Client.Update(ctx, cc, opts...)
+}
+
+func (c statusSubresourceClient) Status() client.StatusWriter {
+	return statusSubresourceWriter{c.Client}
+}
+
+type statusSubresourceWriter struct {
+	client.Client
+}
+
+func (w statusSubresourceWriter) Update(ctx context.Context, obj runtime.Object,
+	opts ...client.UpdateOption) error {
+	cc, ok := obj.(*api.CassandraCluster)
+	if !ok {
+		return w.Client.Status().Update(ctx, obj, opts...)
+	}
+	storedCC := &api.CassandraCluster{}
+	if err := w.Client.Get(ctx, types.NamespacedName{Name: cc.Name, Namespace: cc.Namespace}, storedCC); err != nil {
+		return err
+	}
+	storedCC.Status = cc.Status
+	if err := w.Client.Update(ctx, storedCC, opts...); err != nil {
+		return err
+	}
+	cc.ResourceVersion = storedCC.ResourceVersion
+	return nil
+}
+
+func (w statusSubresourceWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	return w.Client.Status().Patch(ctx, obj, patch, opts...)
+}
+
+func TestFirstReconcileSavesStatus(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	rcc.Client = statusSubresourceClient{rcc.Client}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: cc.Name, Namespace: cc.Namespace}}
+
+	res, err := rcc.Reconcile(req)
+	assert.Nil(err)
+	assert.True(res.Requeue)
+
+	storedCC := &api.CassandraCluster{}
+	assert.Nil(rcc.Client.Get(context.TODO(), req.NamespacedName, storedCC))
+	assert.Equal(api.ClusterPhaseInitial.Name, storedCC.Status.Phase)
+	assert.Equal(cc.InitSeedList(), storedCC.Status.SeedList)
+	assert.NotZero(storedCC.Spec.MaxPodUnavailable)
+}
+
 func TestUpdateStatusIfSeedListHasChanged(t *testing.T) {
 	assert := assert.New(t)
 
@@ -414,6 +484,52 @@ func TestUpdateStatusIfconfigMapHasChangedWithConfigMap(t *testing.T) {
 
 }
 
+func TestUpdateStatusIfTLSSecretHasChanged(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.TLS = &api.TLS{SecretName: "cassandra-tls", KeystorePasswordSecret: "cassandra-keystore"}
+	cc.CheckDefaults()
+	status := cc.Status.DeepCopy()
+
+	passwordSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-keystore", Namespace: cc.Namespace},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	rcc.Client.Create(context.TODO(), passwordSecret)
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-tls", Namespace: cc.Namespace},
+		Data:       map[string][]byte{"tls.crt": []byte("crt"), "tls.key": []byte("key")},
+	}
+	rcc.Client.Create(context.TODO(), secret)
+	assert.Error(rcc.UpdateTLSSecretHash(cc, status))
+
+	secret.Data["ca.crt"] = []byte("ca")
+	rcc.Client.Update(context.TODO(), secret)
+	assert.Nil(rcc.UpdateTLSSecretHash(cc, status))
+	assert.NotEmpty(status.TLSSecretHash)
+
+	dcName, dcRackName := "dc1", "dc1-rack1"
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 0, 0)
+	sts, _ := generateCassandraStatefulSet(cc, status, dcName, dcRackName, labels, nodeSelector, nil)
+	assert.False(UpdateStatusIfconfigMapHasChanged(cc, dcRackName, sts, status))
+
+	//The certificate has been renewed
+	secret.Data["tls.crt"] = []byte("renewed")
+	rcc.Client.Update(context.TODO(), secret)
+	assert.Nil(rcc.UpdateTLSSecretHash(cc, status))
+	assert.True(UpdateStatusIfconfigMapHasChanged(cc, dcRackName, sts, status))
+	assert.Equal(api.ActionUpdateConfigMap.Name, status.CassandraRackStatus[dcRackName].CassandraLastAction.Name)
+	assert.Equal(api.StatusToDo, status.CassandraRackStatus[dcRackName].CassandraLastAction.Status)
+
+	//A change of the password of the keystores also restarts the racks
+	previousHash := status.TLSSecretHash
+	passwordSecret.Data["password"] = []byte("changed")
+	rcc.Client.Update(context.TODO(), passwordSecret)
+	assert.Nil(rcc.UpdateTLSSecretHash(cc, status))
+	assert.NotEqual(previousHash, status.TLSSecretHash)
+}
+
 // test that we detect a change in a the docker image
 func TestUpdateStatusIfDockerImageHasChanged(t *testing.T) {
 	// Mock request to simulate Reconcile() being called on an event for a
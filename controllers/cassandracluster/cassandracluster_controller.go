@@ -28,6 +28,7 @@ import (
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -51,6 +52,8 @@ type CassandraClusterReconciler struct {
 	Scheme *runtime.Scheme
 	Log    logr.Logger
 
+	Recorder record.EventRecorder
+
 	storedPdb         *policyv1beta1.PodDisruptionBudget
 	storedStatefulSet *appsv1.StatefulSet
 }
@@ -94,9 +97,20 @@ func (rcc *CassandraClusterReconciler) Reconcile(request reconcile.Request) (rec
 		if changed {
 			updateDeletePvcStrategy(cc)
 			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Info("Initialization: Update CassandraCluster")
-			return requeue, rcc.Client.Update(context.TODO(), cc)
+			//The status is a subresource which Update ignores, it's saved once the spec is
+			status := cc.Status.DeepCopy()
+			if err = rcc.Client.Update(context.TODO(), cc); err != nil {
+				return requeue, err
+			}
+			cc.Status = *status
+			return requeue, rcc.Client.Status().Update(context.TODO(), cc)
 		}
 	}
+
+	//A rollback restores a previous spec, which is then applied as any other change
+	if rolledBack, err := rcc.RollbackSpec(cc); rolledBack {
+		return requeue, err
+	}
 	cc.CheckDefaults()
 
 	if err = rcc.CheckDeletePVC(cc); err != nil {
@@ -113,6 +127,10 @@ func (rcc *CassandraClusterReconciler) Reconcile(request reconcile.Request) (rec
 		return requeue30, nil
 	}
 
+	if err = rcc.UpdateTLSSecretHash(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("UpdateTLSSecretHash Error: %v", err)
+	}
+
 	if err = rcc.ensureCassandraPodDisruptionBudget(cc); err != nil {
 		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ensureCassandraPodDisruptionBudget Error: %v", err)
 	}
@@ -122,6 +140,26 @@ func (rcc *CassandraClusterReconciler) Reconcile(request reconcile.Request) (rec
 		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("CheckPodsState Error: %v", err)
 	}
 
+	if err = rcc.MonitorPodDrains(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("MonitorPodDrains Error: %v", err)
+	}
+
+	if err = rcc.ReconcileRemediations(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ReconcileRemediations Error: %v", err)
+	}
+
+	if err = rcc.ReconcileNodeReplacements(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ReconcileNodeReplacements Error: %v", err)
+	}
+
+	if err = rcc.ReconcileKeyspaces(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ReconcileKeyspaces Error: %v", err)
+		//A dc can't be decommissioned while keyspaces still replicate data to it
+		if found, _, _ := cc.FindDCWithNodesTo0(); found {
+			return requeue5, err
+		}
+	}
+
 	//ReconcileRack will also add and initiate new racks, we must not go through racks before this method
 	if err = rcc.ReconcileRack(cc, status); err != nil {
 		return requeue5, err
@@ -132,6 +170,18 @@ func (rcc *CassandraClusterReconciler) Reconcile(request reconcile.Request) (rec
 
 	UpdateCassandraClusterStatusPhase(cc, status)
 
+	if err = rcc.ReconcileUpgrade(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ReconcileUpgrade Error: %v", err)
+	}
+
+	if err = rcc.ReconcileAuth(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ReconcileAuth Error: %v", err)
+	}
+
+	if err = rcc.ReconcileRepair(cc, status); err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("ReconcileRepair Error: %v", err)
+	}
+
 	//We could set different requeue based on current Operation
 	return requeue5, nil
 
@@ -0,0 +1,159 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ValidatingWebhookPath is the path the CassandraCluster validating webhook is served on
+const ValidatingWebhookPath = "/validate-db-orange-com-v2-cassandracluster"
+
+// +kubebuilder:webhook:path=/validate-db-orange-com-v2-cassandracluster,mutating=false,failurePolicy=fail,groups=db.orange.com,resources=cassandraclusters,verbs=update,versions=v2,name=vcassandracluster.kb.io
+
+// CassandraClusterValidator refuses at admission time the CassandraCluster changes that CheckNonAllowedChanges
+// would otherwise restore during the reconcile loop
+type CassandraClusterValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &CassandraClusterValidator{}
+var _ admission.DecoderInjector = &CassandraClusterValidator{}
+
+// InjectDecoder is called by the webhook server to give us a decoder for the admission requests
+func (v *CassandraClusterValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle validates an update of a CassandraCluster
+func (v *CassandraClusterValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+
+	cc := &api.CassandraCluster{}
+	if err := v.decoder.Decode(req, cc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	oldCC := &api.CassandraCluster{}
+	if err := v.decoder.DecodeRaw(req.OldObject, oldCC); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if allErrs := v.ValidateUpdate(cc, oldCC); len(allErrs) > 0 {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf("Admission refused: %v",
+			allErrs.ToAggregate())
+		res := admission.Denied(string(metav1.StatusReasonForbidden))
+		//The message is what kubectl displays to the user
+		res.Result.Message = allErrs.ToAggregate().Error()
+		return res
+	}
+	return admission.Allowed("")
+}
+
+// ValidateUpdate applies the rules of CheckNonAllowedChanges to the requested spec.
+// The reference is the last configuration applied by the operator, which is also what the reconcile loop
+// compares against, so that the operator can still restore a value accepted before the webhook was installed
+func (v *CassandraClusterValidator) ValidateUpdate(cc *api.CassandraCluster,
+	oldCC *api.CassandraCluster) field.ErrorList {
+	var allErrs field.ErrorList
+
+	//Only the operator updates the status and annotations, there is nothing to check
+	if reflect.DeepEqual(cc.Spec, oldCC.Spec) {
+		return nil
+	}
+
+	rcc := &CassandraClusterReconciler{Client: v.Client}
+	oldCRD := oldCC
+	if lastApplied, err := rcc.lastAppliedCluster(oldCC); err == nil && lastApplied != nil {
+		oldCRD = lastApplied
+	}
+
+	specPath := field.NewPath("spec")
+
+	//Global scaleDown to 0 is forbidden
+	if cc.Spec.NodesPerRacks == 0 {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("nodesPerRacks"),
+			fmt.Sprintf("can't be set to 0, current value is %d", oldCRD.Spec.NodesPerRacks)))
+	}
+
+	if !reflect.DeepEqual(cc.Spec.RestoreFrom, oldCRD.Spec.RestoreFrom) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("restoreFrom"),
+			"can only be set when the cluster is created"))
+	}
+
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		if cc.GetDataCapacityForDC(dcName) != oldCRD.GetDataCapacityForDC(dcName) {
+			if reason := rcc.StorageResizeRefusedReason(oldCRD.GetDataStorageClassForDC(dcName),
+				oldCRD.GetDataCapacityForDC(dcName), cc.GetDataCapacityForDC(dcName)); reason != "" {
+				allErrs = append(allErrs, field.Forbidden(
+					dcFieldPath(dc, "dataCapacity", cc.Spec.Topology.DC[dc].DataCapacity),
+					fmt.Sprintf("can't be changed from %s to %s for dc %s: %s", oldCRD.GetDataCapacityForDC(dcName),
+						cc.GetDataCapacityForDC(dcName), dcName, reason)))
+			}
+		}
+		if cc.GetDataStorageClassForDC(dcName) != oldCRD.GetDataStorageClassForDC(dcName) {
+			allErrs = append(allErrs, field.Forbidden(
+				dcFieldPath(dc, "dataStorageClass", cc.Spec.Topology.DC[dc].DataStorageClass),
+				fmt.Sprintf("can't be changed from %s to %s for dc %s", oldCRD.GetDataStorageClassForDC(dcName),
+					cc.GetDataStorageClassForDC(dcName), dcName)))
+		}
+	}
+
+	reasons := rcc.StorageConfigsResizeRefusedReasons(cc, oldCRD)
+	for i, storage := range cc.Spec.StorageConfigs {
+		if reason := reasons[storage.Name]; reason != "" {
+			allErrs = append(allErrs, field.Forbidden(
+				specPath.Child("storageConfigs").Index(i).Child("pvcSpec", "resources", "requests", "storage"),
+				reason))
+		}
+	}
+
+	//Topology is only checked when the previous changes are accepted, as CheckNonAllowedChanges does
+	if len(allErrs) > 0 {
+		return allErrs
+	}
+
+	//The scale down of a dc to 0 needs to ask the nodes if data is still replicated to it. The webhook does not
+	//call the pods, the reconciler refuses it and reports it in the ScaleDownRefused condition
+	if reason := TopologyChangeRefusedReason(cc, oldCRD); reason != "" {
+		return append(allErrs, field.Forbidden(specPath.Child("topology"), reason))
+	}
+
+	return allErrs
+}
+
+// dcFieldPath returns the path of a field that can be set either globally or for a dc, depending on
+// where the user has defined it
+func dcFieldPath(dc int, name string, dcValue string) *field.Path {
+	if dcValue != "" {
+		return field.NewPath("spec", "topology", "dc").Index(dc).Child(name)
+	}
+	return field.NewPath("spec").Child(name)
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func helperInitValidator(t *testing.T) (*CassandraClusterValidator, *api.CassandraCluster) {
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	rcc.updateCassandraStatus(cc, status)
+
+	decoder, _ := admission.NewDecoder(scheme.Scheme)
+	validator := &CassandraClusterValidator{Client: rcc.Client}
+	validator.InjectDecoder(decoder)
+	return validator, cc
+}
+
+func TestValidateUpdateOnlyStatusChanged(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	cc := oldCC.DeepCopy()
+	cc.Status.Phase = api.ClusterPhaseRunning.Name
+
+	assert.Empty(validator.ValidateUpdate(cc, oldCC))
+}
+
+func TestValidateUpdateAllowedChange(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	cc := oldCC.DeepCopy()
+	cc.Spec.AutoPilot = false
+
+	assert.Empty(validator.ValidateUpdate(cc, oldCC))
+}
+
+func TestValidateUpdateNonAllowedChanges(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	cc := oldCC.DeepCopy()
+	cc.Spec.NodesPerRacks = 0
+	cc.Spec.DataCapacity = "4Gi"
+	cc.Spec.DataStorageClass = "fast"
+
+	allErrs := validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(3, len(allErrs))
+	assert.Equal("spec.nodesPerRacks", allErrs[0].Field)
+	// dc1 defines its own dataCapacity, only dc2 uses the global one
+	assert.Equal("spec.dataCapacity", allErrs[1].Field)
+	assert.Contains(allErrs[1].Detail, "from 3Gi to 4Gi for dc dc2")
+	assert.Equal("spec.dataStorageClass", allErrs[2].Field)
+
+	cc = oldCC.DeepCopy()
+	cc.Spec.Topology.DC[0].DataCapacity = "20Gi"
+	allErrs = validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(1, len(allErrs))
+	assert.Equal("spec.topology.dc[0].dataCapacity", allErrs[0].Field)
+}
+
+func TestValidateUpdateDataCapacityWithExpandableVolumes(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	allowVolumeExpansion := true
+	validator.Client.Create(context.TODO(), &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "local-storage"},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	})
+
+	cc := oldCC.DeepCopy()
+	cc.Spec.DataCapacity = "4Gi"
+	assert.Empty(validator.ValidateUpdate(cc, oldCC))
+
+	cc.Spec.DataCapacity = "2Gi"
+	allErrs := validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(1, len(allErrs))
+	assert.Contains(allErrs[0].Detail, "volumes can't be shrunk")
+
+	cc = oldCC.DeepCopy()
+	cc.Spec.StorageConfigs[0].PVCSpec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("20Gi")
+	allErrs = validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(1, len(allErrs))
+	assert.Equal("spec.storageConfigs[0].pvcSpec.resources.requests.storage", allErrs[0].Field)
+	assert.Contains(allErrs[0].Detail, "can't get storage class standard-wait")
+}
+
+func TestValidateUpdateNonAllowedTopologyChanges(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	cc := oldCC.DeepCopy()
+	cc.Spec.Topology.DC[1].Rack = append(cc.Spec.Topology.DC[1].Rack, api.Rack{Name: "rack2"})
+	allErrs := validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(1, len(allErrs))
+	assert.Equal("spec.topology", allErrs[0].Field)
+	assert.Contains(allErrs[0].Detail, "No change other than adding/removing a DC can happen")
+
+	cc = oldCC.DeepCopy()
+	cc.Spec.Topology.DC.Remove(1)
+	allErrs = validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(1, len(allErrs))
+	assert.Contains(allErrs[0].Detail, "You must scale down the DC dc2 to 0 before deleting it")
+}
+
+func TestValidateUpdateRestoreFrom(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	cc := oldCC.DeepCopy()
+	cc.Spec.RestoreFrom = &api.RestoreFrom{StorageLocation: "s3://cassandra-backups", SnapshotTag: "weekly"}
+	allErrs := validator.ValidateUpdate(cc, oldCC)
+	assert.Equal(1, len(allErrs))
+	assert.Equal("spec.restoreFrom", allErrs[0].Field)
+	assert.Contains(allErrs[0].Detail, "can only be set when the cluster is created")
+}
+
+func TestValidateUpdateIsComparedToLastAppliedConfiguration(t *testing.T) {
+	assert := assert.New(t)
+	validator, cc := helperInitValidator(t)
+
+	// DataCapacity was changed before the webhook was installed, the operator must be able to restore it
+	oldCC := cc.DeepCopy()
+	oldCC.Spec.DataCapacity = "4Gi"
+
+	assert.Empty(validator.ValidateUpdate(cc, oldCC))
+}
+
+func TestHandleAdmissionRequest(t *testing.T) {
+	assert := assert.New(t)
+	validator, oldCC := helperInitValidator(t)
+
+	cc := oldCC.DeepCopy()
+	cc.Spec.DataStorageClass = "fast"
+
+	raw, _ := json.Marshal(cc)
+	oldRaw, _ := json.Marshal(oldCC)
+	req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+		Operation: admissionv1beta1.Update,
+		Object:    runtime.RawExtension{Raw: raw},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+	}}
+
+	res := validator.Handle(context.TODO(), req)
+	assert.False(res.Allowed)
+	assert.Contains(res.Result.Message, "spec.dataStorageClass")
+
+	req.Object = runtime.RawExtension{Raw: oldRaw}
+	res = validator.Handle(context.TODO(), req)
+	assert.True(res.Allowed)
+}
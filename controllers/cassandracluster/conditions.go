@@ -0,0 +1,121 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rollingUpdateActions are the actions rolling out the statefulsets of a rack
+var rollingUpdateActions = []string{api.ActionUpdateConfigMap.Name, api.ActionUpdateDockerImage.Name,
+	api.ActionUpdateSeedList.Name, api.ActionRollingRestart.Name, api.ActionUpdateResources.Name,
+	api.ActionUpdateStatefulSet.Name, api.ActionUpgradeCassandra.Name}
+
+func isRollingUpdateAction(action string) bool {
+	for _, name := range rollingUpdateActions {
+		if name == action {
+			return true
+		}
+	}
+	return false
+}
+
+// setCondition sets a condition of the status, its transition time only changes with its status
+func setCondition(cc *api.CassandraCluster, status *api.CassandraClusterStatus, conditionType string, racks []string,
+	trueReason, falseReason, format string) {
+	condition := metav1.Condition{Type: conditionType, Status: metav1.ConditionFalse, Reason: falseReason,
+		ObservedGeneration: cc.Generation}
+	if len(racks) > 0 {
+		sort.Strings(racks)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = trueReason
+		condition.Message = fmt.Sprintf(format, strings.Join(racks, ","))
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// updateClusterConditions computes the conditions of the cluster from the phases and the actions of its racks
+func updateClusterConditions(cc *api.CassandraCluster, status *api.CassandraClusterStatus) {
+	var progressing, degraded, scalingUp, scalingDown, rollingUpdate, operationFailed []string
+	for dcRackName, dcRackStatus := range status.CassandraRackStatus {
+		lastAction := dcRackStatus.CassandraLastAction
+		actionOngoing := lastAction.Status != "" && lastAction.Status != api.StatusDone
+		if actionOngoing || dcRackStatus.Phase == api.ClusterPhaseInitial.Name {
+			progressing = append(progressing, dcRackName)
+		}
+		//A rack missing ready nodes while no action explains it
+		if dcRackStatus.Phase == api.ClusterPhasePending.Name && !actionOngoing {
+			degraded = append(degraded, dcRackName)
+		}
+		if actionOngoing {
+			switch {
+			case lastAction.Name == api.ActionScaleUp.Name:
+				scalingUp = append(scalingUp, dcRackName)
+			case lastAction.Name == api.ActionScaleDown.Name:
+				scalingDown = append(scalingDown, dcRackName)
+			case isRollingUpdateAction(lastAction.Name):
+				rollingUpdate = append(rollingUpdate, dcRackName)
+			}
+		}
+		if len(dcRackStatus.PodLastOperation.PodsKO) > 0 {
+			operationFailed = append(operationFailed, dcRackName)
+		}
+	}
+
+	ready := metav1.Condition{Type: api.ConditionReady, Status: metav1.ConditionFalse,
+		Reason: "ClusterNotRunning", ObservedGeneration: cc.Generation,
+		Message: fmt.Sprintf("Cluster is %s, last action %s is %s", status.Phase, status.LastClusterAction,
+			status.LastClusterActionStatus)}
+	if status.Phase == api.ClusterPhaseRunning.Name && status.LastClusterActionStatus == api.StatusDone {
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = "ClusterRunning"
+		ready.Message = "All racks are running"
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	setCondition(cc, status, api.ConditionProgressing, progressing, "ActionOngoing", "NoActionOngoing",
+		"Action ongoing on racks %s")
+	setCondition(cc, status, api.ConditionDegraded, degraded, "RacksNotReady", "RacksReady",
+		"Racks %s are missing ready nodes")
+	setCondition(cc, status, api.ConditionScalingUp, scalingUp, api.ActionScaleUp.Name, "NoScaleUp",
+		"Racks %s are scaling up")
+	setCondition(cc, status, api.ConditionScalingDown, scalingDown, api.ActionScaleDown.Name, "NoScaleDown",
+		"Racks %s are scaling down")
+	setCondition(cc, status, api.ConditionRollingUpdate, rollingUpdate, "StatefulSetsUpdating",
+		"NoRollingUpdate", "Racks %s are being updated")
+	setCondition(cc, status, api.ConditionOperationFailed, operationFailed, "PodOperationFailed",
+		"NoPodOperationFailed", "Last pod operation has failed on racks %s")
+
+	//Once the snapshot of restoreFrom is restored in all the nodes, the cluster never restores it again
+	if isRestoring(cc, status) {
+		restored := metav1.Condition{Type: api.ConditionRestored, Status: metav1.ConditionFalse, Reason: "Restoring",
+			ObservedGeneration: cc.Generation,
+			Message:            fmt.Sprintf("Restoring snapshot %s", cc.Spec.RestoreFrom.SnapshotTag)}
+		if ready.Status == metav1.ConditionTrue {
+			restored.Status = metav1.ConditionTrue
+			restored.Reason = "Restored"
+			restored.Message = fmt.Sprintf("Snapshot %s restored in all the nodes", cc.Spec.RestoreFrom.SnapshotTag)
+		}
+		meta.SetStatusCondition(&status.Conditions, restored)
+	}
+
+	status.ObservedGeneration = cc.Generation
+}
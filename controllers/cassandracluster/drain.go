@@ -0,0 +1,131 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// drainStartTime returns when a terminating pod was asked to stop, which is when its PreStop hook started
+func drainStartTime(pod v1.Pod) metav1.Time {
+	startTime := pod.DeletionTimestamp.Time
+	if pod.DeletionGracePeriodSeconds != nil {
+		startTime = startTime.Add(-time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second)
+	}
+	return metav1.NewTime(startTime)
+}
+
+// podOrdinal returns the ordinal of a pod of a statefulset, -1 if its name has none
+func podOrdinal(podName string) int32 {
+	ordinal, err := strconv.ParseInt(podName[strings.LastIndex(podName, "-")+1:], 10, 32)
+	if err != nil {
+		return -1
+	}
+	return int32(ordinal)
+}
+
+// MonitorPodDrains records in the status of the racks when their pods are asked to stop and how long it takes
+// to drain them, so that the termination grace period of the pods can be tuned
+func (rcc *CassandraClusterReconciler) MonitorPodDrains(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+			rackName := cc.GetRackName(dc, rack)
+			dcRackName := cc.GetDCRackName(dcName, rackName)
+			dcRackStatus, exists := status.CassandraRackStatus[dcRackName]
+			if !exists {
+				continue
+			}
+			podsList, err := rcc.ListPods(cc.Namespace, k8s.LabelsForCassandraDCRack(cc, dcName, rackName))
+			if err != nil {
+				return err
+			}
+			rcc.monitorRackPodDrains(cc, dcRackName, dcRackStatus, podsList.Items)
+		}
+	}
+	return nil
+}
+
+// monitorRackPodDrains records the drains of the pods of a rack
+func (rcc *CassandraClusterReconciler) monitorRackPodDrains(cc *api.CassandraCluster, dcRackName string,
+	dcRackStatus *api.CassandraRackStatus, pods []v1.Pod) {
+	podsByName := map[string]v1.Pod{}
+	for _, pod := range pods {
+		podsByName[pod.Name] = pod
+		if pod.DeletionTimestamp == nil {
+			continue
+		}
+		startTime := drainStartTime(pod)
+		if drain, exists := dcRackStatus.PodDrains[pod.Name]; exists && !drain.StartTime.Before(&startTime) {
+			continue
+		}
+		if dcRackStatus.PodDrains == nil {
+			dcRackStatus.PodDrains = map[string]api.PodDrain{}
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName,
+			"pod": pod.Name}).Info("Pod is stopping, it is drained")
+		dcRackStatus.PodDrains[pod.Name] = api.PodDrain{StartTime: startTime}
+	}
+
+	nodesPerRacks := cc.GetNodesPerRacks(dcRackName)
+	for podName, drain := range dcRackStatus.PodDrains {
+		//Pods removed by a scale down are not recreated
+		if podOrdinal(podName) >= nodesPerRacks {
+			delete(dcRackStatus.PodDrains, podName)
+			continue
+		}
+		if drain.Duration != nil {
+			continue
+		}
+
+		endTime := metav1.Now()
+		pod, found := podsByName[podName]
+		switch {
+		case !found:
+			//The pod stopped before its drain was seen ending, its drain ends at the latest when it is recreated
+			continue
+		case pod.DeletionTimestamp == nil:
+			//The pod stopped and has been recreated since
+			if drain.StartTime.Before(&pod.CreationTimestamp) {
+				endTime = pod.CreationTimestamp
+			}
+		default:
+			jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(pod), JolokiaPort, rcc,
+				cc.Spec.ImageJolokiaSecret, cc.Namespace)
+			var mode operationMode
+			if err == nil {
+				mode, err = jolokiaClient.NodeOperationMode()
+			}
+			if err != nil || mode != DRAINED {
+				continue
+			}
+		}
+
+		duration := metav1.Duration{Duration: endTime.Sub(drain.StartTime.Time).Round(time.Second)}
+		drain.Duration = &duration
+		dcRackStatus.PodDrains[podName] = drain
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": podName,
+			"duration": duration.Duration}).Info("Pod drained")
+	}
+}
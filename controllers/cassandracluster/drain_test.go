@@ -0,0 +1,94 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMonitorPodDrains(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	pod := helperCreateUpgradePod(rcc, 0)
+	dcRackStatus := status.CassandraRackStatus["dc1-rack1"]
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerJolokiaOperationModeResponder(podName{FullName: k8s.PodHostname(*pod)}, NORMAL)
+
+	//Nothing is recorded while no pod stops
+	assert.Nil(rcc.MonitorPodDrains(cc, status))
+	assert.Empty(dcRackStatus.PodDrains)
+
+	deletionTime := metav1.NewTime(time.Now().Add(1800 * time.Second).Truncate(time.Second))
+	gracePeriod := int64(1800)
+	pod.DeletionTimestamp = &deletionTime
+	pod.DeletionGracePeriodSeconds = &gracePeriod
+	rcc.Client.Update(context.TODO(), pod)
+
+	assert.Nil(rcc.MonitorPodDrains(cc, status))
+	drain := dcRackStatus.PodDrains[pod.Name]
+	assert.Equal(deletionTime.Add(-1800*time.Second), drain.StartTime.Time)
+	assert.Nil(drain.Duration)
+
+	registerJolokiaOperationModeResponder(podName{FullName: k8s.PodHostname(*pod)}, DRAINED)
+	assert.Nil(rcc.MonitorPodDrains(cc, status))
+	drain = dcRackStatus.PodDrains[pod.Name]
+	assert.NotNil(drain.Duration)
+	assert.True(drain.Duration.Duration < time.Minute)
+}
+
+func TestMonitorRackPodDrainsOfStoppedPods(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	dcRackName := "dc1-rack1"
+	dcRackStatus := status.CassandraRackStatus[dcRackName]
+	pod := helperCreateUpgradePod(rcc, 0)
+
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	dcRackStatus.PodDrains = map[string]api.PodDrain{
+		pod.Name:                     {StartTime: startTime},
+		"cassandra-demo-dc1-rack1-3": {StartTime: startTime},
+	}
+
+	//A recreated pod was drained before it was created
+	pod.CreationTimestamp = metav1.NewTime(startTime.Add(40 * time.Second))
+	rcc.monitorRackPodDrains(cc, dcRackName, dcRackStatus, []v1.Pod{*pod})
+	assert.Equal(40*time.Second, dcRackStatus.PodDrains[pod.Name].Duration.Duration)
+
+	//Pods removed by a scale down are forgotten
+	_, found := dcRackStatus.PodDrains["cassandra-demo-dc1-rack1-3"]
+	assert.False(found)
+
+	//A pod gone before its drain is seen ending has no duration until it is recreated
+	dcRackStatus.PodDrains[pod.Name] = api.PodDrain{StartTime: startTime}
+	rcc.monitorRackPodDrains(cc, dcRackName, dcRackStatus, nil)
+	assert.Nil(dcRackStatus.PodDrains[pod.Name].Duration)
+	rcc.monitorRackPodDrains(cc, dcRackName, dcRackStatus, []v1.Pod{*pod})
+	assert.Equal(40*time.Second, dcRackStatus.PodDrains[pod.Name].Duration.Duration)
+}
@@ -24,6 +24,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -64,6 +65,28 @@ const (
 
 	cassandraConfigMapName = "cassandra-config"
 	defaultBackRestPort    = 4567
+
+	// TLS keystores. Their password is read from a Secret, the bootstrap container writes it in place of the
+	// placeholder of cassandra.yaml so that it is neither in the configmap nor in the statefulset
+	tlsContainerName               = "tls-keystores"
+	tlsVolumeName                  = "tls"
+	tlsMountPath                   = "/tls"
+	keystoresVolumeName            = "keystores"
+	keystoresMountPath             = "/keystores"
+	tlsKeystorePasswordPlaceholder = "__KEYSTORE_PASSWORD__"
+	tlsSecretHashAnnotation        = "cassandraclusters.db.orange.com/tls-secret-hash"
+
+	// The restore container is a no-op once the node is restored, i.e. once the marker file is in its data volume, or
+	// once the snapshot is restored in the cluster, i.e. once the ConfigMap named by restoredName exists
+	restoreContainerName = "restore"
+	restoredMarkerFile   = "/var/lib/cassandra/.restored"
+	restoredKey          = "snapshotTag"
+
+	// Address of the node each pod replaces, by pod name, read by the run.sh script of the bootstrap container
+	replaceNodesVolumeName = "replace-nodes"
+	replaceNodesMountPath  = "/replace-nodes"
+
+	archiveCommitlogScriptName = "archive-commitlog.sh"
 )
 
 type containerType int
@@ -160,6 +183,16 @@ func generateCassandraVolumes(cc *api.CassandraCluster) []v1.Volume {
 		emptyDir("tools"),
 		emptyDir("log"),
 		emptyDir("tmp"),
+		{
+			Name: replaceNodesVolumeName,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: replaceNodesName(cc)},
+					//Only exists while a node is replaced, so that the template does not change
+					Optional: func(b bool) *bool { return &b }(true),
+				},
+			},
+		},
 	}
 
 	if cc.Spec.ConfigMapName != "" {
@@ -176,6 +209,18 @@ func generateCassandraVolumes(cc *api.CassandraCluster) []v1.Volume {
 		})
 	}
 
+	if cc.Spec.TLS != nil {
+		v = append(v, v1.Volume{
+			Name: tlsVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName:  cc.Spec.TLS.SecretName,
+					DefaultMode: func(i int32) *int32 { return &i }(288), //288 is base10 to 0440 base8
+				},
+			},
+		}, emptyDir(keystoresVolumeName))
+	}
+
 	return v
 }
 
@@ -204,6 +249,7 @@ func generateContainerVolumeMount(cc *api.CassandraCluster, ct containerType) []
 	}
 
 	if ct == bootstrapContainer {
+		vm = append(vm, v1.VolumeMount{Name: replaceNodesVolumeName, MountPath: replaceNodesMountPath})
 		if cc.Spec.ConfigMapName != "" {
 			vm = append(vm, v1.VolumeMount{Name: "cassandra-config", MountPath: "/configmap"})
 		}
@@ -220,6 +266,10 @@ func generateContainerVolumeMount(cc *api.CassandraCluster, ct containerType) []
 		return vm
 	}
 
+	if cc.Spec.TLS != nil {
+		vm = append(vm, v1.VolumeMount{Name: keystoresVolumeName, MountPath: keystoresMountPath, ReadOnly: true})
+	}
+
 	return append(vm,
 		v1.VolumeMount{Name: "log", MountPath: "/var/log/cassandra"})
 }
@@ -321,7 +371,15 @@ func generateCassandraStatefulSet(cc *api.CassandraCluster, status *api.Cassandr
 	nodeAffinity := createNodeAffinity(nodeSelector)
 	nodesPerRacks := cc.GetNodesPerRacks(dcRackName)
 	rollingPartition := cc.GetRollingPartitionPerRacks(dcRackName)
+	//While the rack is upgraded to a new major version, the pods are only updated once drained by the operator
+	if dcRackStatus, exists := status.CassandraRackStatus[dcRackName]; exists &&
+		dcRackStatus.UpgradePartition != nil {
+		rollingPartition = *dcRackStatus.UpgradePartition
+	}
 	terminationPeriod := int64(api.DefaultTerminationGracePeriodSeconds)
+	if cc.Spec.TerminationGracePeriodSeconds != nil {
+		terminationPeriod = *cc.Spec.TerminationGracePeriodSeconds
+	}
 	var annotations = map[string]string{}
 	var tolerations = []v1.Toleration{}
 	if cc.Spec.Pod != nil {
@@ -385,6 +443,21 @@ func generateCassandraStatefulSet(cc *api.CassandraCluster, status *api.Cassandr
 		},
 	}
 
+	//The hash of the certificates triggers a rolling restart when they are renewed
+	if cc.Spec.TLS != nil {
+		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers,
+			createTLSKeystoresContainer(cc))
+		ss.Spec.Template.Annotations = k8s.MergeLabels(annotations,
+			map[string]string{tlsSecretHashAnnotation: status.TLSSecretHash})
+	}
+
+	//The snapshot of restoreFrom is restored before the nodes join the cluster. The container is kept once restored so
+	//that the pods are not restarted
+	if cc.Spec.RestoreFrom != nil {
+		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers,
+			createRestoreContainer(cc, dcName))
+	}
+
 	//Add secrets
 	if (cc.Spec.ImagePullSecret != v1.LocalObjectReference{}) {
 		ss.Spec.Template.Spec.ImagePullSecrets = []v1.LocalObjectReference{cc.Spec.ImagePullSecret}
@@ -628,21 +701,11 @@ func initContainerEnvVar(cc *api.CassandraCluster, status *api.CassandraClusterS
 	resources v1.ResourceRequirements, dcRackName string) []v1.EnvVar {
 	seedList := cc.SeedList(&status.SeedList)
 
-	image := strings.Split(cc.Spec.CassandraImage, ":")
-	serverVersion := cc.Spec.ServerVersion
-	if serverVersion == "" {
-		if len(image) >= 2 {
-			version := strings.Split(image[len(image)-1], "-")
-			serverVersion = version[0]
-			if len(version) != 1 {
-				serverVersion += ".0"
-			}
-		}
-	}
+	serverVersion := cassandraServerVersion(cc)
 
 	serverType := cc.Spec.ServerType
 	if serverType == "" {
-		if strings.Contains(image[0], "dse") {
+		if strings.Contains(strings.Split(cc.Spec.CassandraImage, ":")[0], "dse") {
 			serverType = "dse"
 		} else {
 			serverType = "cassandra"
@@ -672,6 +735,19 @@ func initContainerEnvVar(cc *api.CassandraCluster, status *api.CassandraClusterS
 		},
 	}
 
+	if cc.Spec.TLS != nil {
+		config["cassandra-yaml"] = encryptionOptions(cc.Spec.TLS)
+	}
+
+	if cc.Spec.Auth != nil {
+		if config["cassandra-yaml"] == nil {
+			config["cassandra-yaml"] = map[string]interface{}{}
+		}
+		config["cassandra-yaml"]["authenticator"] = "PasswordAuthenticator"
+		config["cassandra-yaml"]["authorizer"] = "CassandraAuthorizer"
+		config["cassandra-yaml"]["role_manager"] = "CassandraRoleManager"
+	}
+
 	parsedConfig := parseConfig(config)
 	dc := cc.GetDCFromDCRackName(dcRackName)
 	rack := cc.GetRackFromDCRackName(dcRackName)
@@ -743,9 +819,87 @@ func initContainerEnvVar(cc *api.CassandraCluster, status *api.CassandraClusterS
 	}
 }
 
+// encryptionOptions returns the cassandra.yaml options using the keystores created by the tls-keystores container.
+// The bootstrap container replaces the password placeholder
+func encryptionOptions(tls *api.TLS) map[string]interface{} {
+	options := map[string]interface{}{
+		"server_encryption_options": map[string]interface{}{
+			"internode_encryption": tls.InternodeEncryption,
+			"keystore":             keystoresMountPath + "/keystore.p12",
+			"keystore_password":    tlsKeystorePasswordPlaceholder,
+			"truststore":           keystoresMountPath + "/truststore.p12",
+			"truststore_password":  tlsKeystorePasswordPlaceholder,
+			"store_type":           "PKCS12",
+			"require_client_auth":  true,
+		},
+	}
+	if tls.ClientEncryption {
+		options["client_encryption_options"] = map[string]interface{}{
+			"enabled":             true,
+			"optional":            false,
+			"keystore":            keystoresMountPath + "/keystore.p12",
+			"keystore_password":   tlsKeystorePasswordPlaceholder,
+			"truststore":          keystoresMountPath + "/truststore.p12",
+			"truststore_password": tlsKeystorePasswordPlaceholder,
+			"store_type":          "PKCS12",
+			"require_client_auth": tls.RequireClientAuth,
+		}
+	}
+	return options
+}
+
+// cassandraServerVersion returns the version of Cassandra run by the nodes, serverVersion or else the tag of the image
+func cassandraServerVersion(cc *api.CassandraCluster) string {
+	serverVersion := cc.Spec.ServerVersion
+	image := strings.Split(cc.Spec.CassandraImage, ":")
+	if serverVersion == "" && len(image) >= 2 {
+		version := strings.Split(image[len(image)-1], "-")
+		serverVersion = version[0]
+		if len(version) != 1 {
+			serverVersion += ".0"
+		}
+	}
+	return serverVersion
+}
+
+// preStopBootstrapVersion is the first version of the bootstrap image which ships pre-stop.sh
+var preStopBootstrapVersion = []int{0, 1, 10}
+
+// bootstrapImageShipsPreStop returns whether the bootstrap image copies pre-stop.sh in the cassandra container. An image
+// whose tag is not a version is expected to ship it
+func bootstrapImageShipsPreStop(bootstrapImage string) bool {
+	image := strings.Split(bootstrapImage, ":")
+	if len(image) < 2 {
+		return true
+	}
+	version := strings.Split(strings.Split(image[len(image)-1], "-")[0], ".")
+	for i, minimum := range preStopBootstrapVersion {
+		if i >= len(version) {
+			return false
+		}
+		number, err := strconv.Atoi(version[i])
+		if err != nil {
+			return true
+		}
+		if number != minimum {
+			return number > minimum
+		}
+	}
+	return true
+}
+
+// cassandraMajorVersion returns the major version of Cassandra run by the nodes, 0 if it is unknown
+func cassandraMajorVersion(serverVersion string) int {
+	major, err := strconv.Atoi(strings.Split(serverVersion, ".")[0])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
 func jvmOptionName(cc *api.CassandraCluster) (jvmOption string)  {
 	jvmOption = "jvm-options"
-	if strings.HasPrefix(cc.Spec.ServerVersion, "4") {
+	if cassandraMajorVersion(cassandraServerVersion(cc)) >= 4 {
 		jvmOption = "jvm-server-options"
 	}
 	return
@@ -799,11 +953,27 @@ func bootstrapContainerEnvVar(cc *api.CassandraCluster, status *api.CassandraClu
 			},
 		},
 	}
+	if cc.Spec.TLS != nil {
+		bootstrapEnvVars = append(bootstrapEnvVars, keystorePasswordEnvVar(cc.Spec.TLS))
+	}
 	commonEnvVars := commonBootstrapCassandraEnvVar(cc)
 	bootstrapEnvVars = append(bootstrapEnvVars, commonEnvVars...)
 	return bootstrapEnvVars
 }
 
+// keystorePasswordEnvVar reads the password of the keystores from the Secret of spec.tls
+func keystorePasswordEnvVar(tls *api.TLS) v1.EnvVar {
+	return v1.EnvVar{
+		Name: "KEYSTORE_PASSWORD",
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: tls.KeystorePasswordSecret},
+				Key:                  "password",
+			},
+		},
+	}
+}
+
 func commonBootstrapCassandraEnvVar(cc *api.CassandraCluster) []v1.EnvVar {
 	commonEnvVars := []v1.EnvVar{
 		{
@@ -866,18 +1036,36 @@ func createInitConfigContainer(cc *api.CassandraCluster, status *api.CassandraCl
 }
 
 func createBaseConfigBuilderContainer(cc *api.CassandraCluster) v1.Container {
+	script := "cp -r /etc/cassandra/* /bootstrap/"
+	if cc.Spec.CommitlogArchiving {
+		script += "\n" + commitlogArchivingScript()
+	}
 
 	return v1.Container{
 		Name:            cassBaseConfigBuilderName,
 		Image:           cc.Spec.CassandraImage,
 		ImagePullPolicy: cc.Spec.ImagePullPolicy,
 		Command: 		 []string{"/bin/sh"},
-		Args: 			 []string{"-c", "cp -r /etc/cassandra/* /bootstrap/"},
+		Args: 			 []string{"-c", script},
 		VolumeMounts:    generateContainerVolumeMount(cc, initContainer),
 		Resources:       initContainerResources(),
 	}
 }
 
+// commitlogArchivingScript writes the commitlog_archiving.properties making Cassandra hard link each commitlog segment
+// it closes to the archive directory of the data volume, from where the backrest sidecar ships them. Cassandra splits
+// archive_command on spaces so the command is a script
+func commitlogArchivingScript() string {
+	return fmt.Sprintf(`cat > /bootstrap/%[1]s <<'EOF'
+#!/bin/sh
+mkdir -p %[2]s
+ln -f "$1" %[2]s/"$2" 2>/dev/null || cp -f "$1" %[2]s/"$2"
+EOF
+chmod 755 /bootstrap/%[1]s
+echo "archive_command=/etc/cassandra/%[1]s %%path %%name" > /bootstrap/commitlog_archiving.properties`,
+		archiveCommitlogScriptName, api.CommitlogArchiveDirectory)
+}
+
 // createCassandraBootstrapContainer will copy jar from bootstrap image to /extra-lib/ directory.
 // configure /etc/cassandra with Env var and with userConfigMap (if enabled) by running the run.sh script
 func createCassandraBootstrapContainer(cc *api.CassandraCluster, status *api.CassandraClusterStatus) v1.Container {
@@ -893,6 +1081,36 @@ func createCassandraBootstrapContainer(cc *api.CassandraCluster, status *api.Cas
 	}
 }
 
+// createTLSKeystoresContainer copies the PKCS12 keystore and truststore of the TLS secret, as created by cert-manager
+// with its keystores option. Without them, it converts the PEM certificates, the Cassandra image must then provide
+// openssl and keytool
+func createTLSKeystoresContainer(cc *api.CassandraCluster) v1.Container {
+	script := fmt.Sprintf(`set -e
+if [ -f %[1]s/keystore.p12 ] && [ -f %[1]s/truststore.p12 ]; then
+  cp %[1]s/keystore.p12 %[1]s/truststore.p12 %[2]s/
+  exit 0
+fi
+openssl pkcs12 -export -name cassandra -in %[1]s/tls.crt -inkey %[1]s/tls.key -certfile %[1]s/ca.crt \
+  -out %[2]s/keystore.p12 -passout env:KEYSTORE_PASSWORD
+rm -f %[2]s/truststore.p12
+keytool -importcert -noprompt -alias ca -file %[1]s/ca.crt -keystore %[2]s/truststore.p12 \
+  -storetype PKCS12 -storepass "$KEYSTORE_PASSWORD"`, tlsMountPath, keystoresMountPath)
+
+	return v1.Container{
+		Name:            tlsContainerName,
+		Image:           cc.Spec.CassandraImage,
+		ImagePullPolicy: cc.Spec.ImagePullPolicy,
+		Command:         []string{"/bin/sh"},
+		Args:            []string{"-c", script},
+		Env:             []v1.EnvVar{keystorePasswordEnvVar(cc.Spec.TLS)},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: tlsVolumeName, MountPath: tlsMountPath, ReadOnly: true},
+			{Name: keystoresVolumeName, MountPath: keystoresMountPath},
+		},
+		Resources: initContainerResources(),
+	}
+}
+
 func getPos(slice []v1.VolumeMount, value string) int {
 	for i, v := range slice {
 		if v.Name == value {
@@ -1017,6 +1235,21 @@ func createCassandraContainer(cc *api.CassandraCluster, status *api.CassandraClu
 		Resources:    resources,
 	}
 
+	//The node is drained before it stops so that it doesn't have to replay its commitlog when it restarts
+	if bootstrapImageShipsPreStop(cc.Spec.BootstrapImage) {
+		cassandraContainer.Lifecycle = &v1.Lifecycle{
+			PreStop: &v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: []string{
+						"/bin/bash",
+						"-c",
+						"/etc/cassandra/pre-stop.sh",
+					},
+				},
+			},
+		}
+	}
+
 	if cc.Spec.LivenessFailureThreshold != nil {
 		cassandraContainer.LivenessProbe.FailureThreshold = *cc.Spec.LivenessFailureThreshold
 	}
@@ -1060,3 +1293,75 @@ func backrestSidecarContainer(cc *api.CassandraCluster) v1.Container {
 
 	return container
 }
+
+// isRestoring returns true until the snapshot of restoreFrom is restored in all the nodes of the cluster
+func isRestoring(cc *api.CassandraCluster, status *api.CassandraClusterStatus) bool {
+	return cc.Spec.RestoreFrom != nil && !meta.IsStatusConditionTrue(status.Conditions, api.ConditionRestored)
+}
+
+// restoreScript restores the snapshot of the node with the esop arguments it is given, then writes the marker file so
+// that the snapshot is not restored again when the pod restarts. The node restored has the name of the pod, with the
+// name of the cluster backed up as prefix
+const restoreScript = `set -e
+if [ -n "$RESTORED" ]; then
+  echo "Snapshot $RESTORED already restored in the cluster, the node starts empty"
+  exit 0
+fi
+if [ -f ` + restoredMarkerFile + ` ]; then
+  echo "Snapshot already restored in the node"
+  exit 0
+fi
+esop restore "$@" --storage-location="$STORAGE_LOCATION${POD_NAME#$CLUSTER_NAME}"
+touch ` + restoredMarkerFile
+
+// createRestoreContainer downloads the snapshot of the node from the storage location of restoreFrom before
+// Cassandra starts. The host ID of the node is resolved from the topology file of the snapshot and the tokens of the
+// node in its manifest are written in cassandra.yaml, so that the node owns the same ranges as in the cluster backed up
+func createRestoreContainer(cc *api.CassandraCluster, dcName string) v1.Container {
+	restoreFrom := cc.Spec.RestoreFrom
+	sourceName := cc.Name
+	if restoreFrom.ClusterName != "" {
+		sourceName = restoreFrom.ClusterName
+	}
+	container := backrestSidecarContainer(cc)
+	container.Name = restoreContainerName
+	container.Ports = nil
+	container.Command = []string{"sh", "-c", restoreScript, restoreContainerName}
+	container.Args = []string{
+		"--snapshot-tag=" + restoreFrom.SnapshotTag,
+		"--restoration-strategy-type=IN_PLACE",
+		"--update-cassandra-yaml=true",
+		"--resolve-host-id-from-topology=true",
+		"--cassandra-directory=/var/lib/cassandra",
+		"--cassandra-config-directory=/etc/cassandra",
+		"--k8s-namespace=" + cc.Namespace,
+	}
+	if restoreFrom.Secret != "" {
+		container.Args = append(container.Args, "--k8s-secret-name="+restoreFrom.Secret)
+	}
+	container.Env = []v1.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &v1.EnvVarSource{
+				FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		},
+		{Name: "CLUSTER_NAME", Value: cc.Name},
+		{
+			Name: "STORAGE_LOCATION",
+			Value: fmt.Sprintf("%s/%s/%s/%s", strings.TrimSuffix(restoreFrom.StorageLocation, "/"), sourceName,
+				dcName, sourceName),
+		},
+		{
+			Name: "RESTORED",
+			ValueFrom: &v1.EnvVarSource{
+				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: restoredName(cc)},
+					Key:                  restoredKey,
+					Optional:             func(b bool) *bool { return &b }(true),
+				},
+			},
+		},
+	}
+	return container
+}
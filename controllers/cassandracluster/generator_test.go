@@ -22,11 +22,14 @@ import (
 	"github.com/ghodss/yaml"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
 	"testing"
 
 	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -59,7 +62,7 @@ func helperInitCluster(t *testing.T, name string) (*CassandraClusterReconciler,
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &ccList)
 	cl := fake.NewFakeClientWithScheme(fakeClientScheme, objs...)
 	// Create a CassandraClusterReconciler object with the scheme and fake client.
-	rcc := CassandraClusterReconciler{Client: cl, Scheme: fakeClientScheme}
+	rcc := CassandraClusterReconciler{Client: cl, Scheme: fakeClientScheme, Recorder: record.NewFakeRecorder(100)}
 
 	cc.InitCassandraRackList()
 	return &rcc, &cc
@@ -151,10 +154,11 @@ func TestVolumeMounts(t *testing.T) {
 	assert.Equal(t, "/bootstrap", volumeMounts[getPos(volumeMounts, "bootstrap")].MountPath)
 
 	volumeMounts = generateContainerVolumeMount(cc, bootstrapContainer)
-	assert.Equal(t, 3, len(volumeMounts))
+	assert.Equal(t, 4, len(volumeMounts))
 	assert.Equal(t, "/etc/cassandra", volumeMounts[getPos(volumeMounts, "bootstrap")].MountPath)
 	assert.Equal(t, "/extra-lib", volumeMounts[getPos(volumeMounts, "extra-lib")].MountPath)
 	assert.Equal(t, "/opt/bin", volumeMounts[getPos(volumeMounts, "tools")].MountPath)
+	assert.Equal(t, "/replace-nodes", volumeMounts[getPos(volumeMounts, "replace-nodes")].MountPath)
 
 	volumeMounts = generateContainerVolumeMount(cc, cassandraContainer)
 	assert.Equal(t, 6, len(volumeMounts))
@@ -463,6 +467,157 @@ func TestGenerateCassandraStatefulSetBackrestVolumes(t *testing.T) {
 
 }
 
+func TestGenerateCassandraStatefulSetWithTLS(t *testing.T) {
+	assert := assert.New(t)
+	dcName := "dc1"
+	dcRackName := fmt.Sprintf("%s-rack1", dcName)
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.TLS = &api.TLS{SecretName: "cassandra-tls", KeystorePasswordSecret: "cassandra-keystore",
+		ClientEncryption: true}
+	cc.CheckDefaults()
+	cc.Status.TLSSecretHash = "0123456789"
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 0, 0)
+	sts, _ := generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	assert.Equal("0123456789", sts.Spec.Template.Annotations[tlsSecretHashAnnotation])
+	_, found := cc.Spec.Pod.Annotations[tlsSecretHashAnnotation]
+	assert.False(found, "Pod annotations of the CassandraCluster must not be modified")
+
+	initContainers := sts.Spec.Template.Spec.InitContainers
+	tlsContainer := initContainers[len(initContainers)-1]
+	assert.Equal(tlsContainerName, tlsContainer.Name)
+
+	//The password of the keystores is only read from its Secret
+	for _, container := range []v1.Container{tlsContainer, initContainers[2]} {
+		password := GetEnvVarByName(container.Env, "KEYSTORE_PASSWORD")
+		assert.Empty(password.Value)
+		assert.Equal("cassandra-keystore", password.ValueFrom.SecretKeyRef.Name)
+		assert.Equal("password", password.ValueFrom.SecretKeyRef.Key)
+	}
+	assert.Equal(bootstrapContainerName, initContainers[2].Name)
+
+	var tlsVolume *v1.Volume
+	for i, volume := range sts.Spec.Template.Spec.Volumes {
+		if volume.Name == tlsVolumeName {
+			tlsVolume = &sts.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	assert.NotNil(tlsVolume)
+	assert.Equal("cassandra-tls", tlsVolume.Secret.SecretName)
+
+	cassandraContainer := sts.Spec.Template.Spec.Containers[2]
+	assert.Equal(cassandraContainerName, cassandraContainer.Name)
+	assert.True(volumesContains(cassandraContainer.VolumeMounts,
+		v1.VolumeMount{Name: keystoresVolumeName, MountPath: keystoresMountPath, ReadOnly: true}))
+
+	configFileData, _ := gabs.ParseJSON([]byte(GetEnvVarByName(initContainers[1].Env, "CONFIG_FILE_DATA").Value))
+	assert.Equal("all", configFileData.Path(
+		"cassandra-yaml.server_encryption_options.internode_encryption").Data())
+	assert.Equal(true, configFileData.Path("cassandra-yaml.client_encryption_options.enabled").Data())
+	assert.Equal(false, configFileData.Path("cassandra-yaml.client_encryption_options.require_client_auth").Data())
+	assert.Equal(tlsKeystorePasswordPlaceholder, configFileData.Path(
+		"cassandra-yaml.server_encryption_options.keystore_password").Data())
+	//Default options are still set
+	assert.Equal(5000.0, configFileData.Path("cassandra-yaml.read_request_timeout_in_ms").Data())
+}
+
+func TestGenerateCassandraStatefulSetWithAuth(t *testing.T) {
+	assert := assert.New(t)
+	dcName := "dc1"
+	dcRackName := fmt.Sprintf("%s-rack1", dcName)
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.TLS = &api.TLS{SecretName: "cassandra-tls"}
+	cc.Spec.Auth = &api.Auth{SuperuserSecret: "cassandra-superuser"}
+	cc.CheckDefaults()
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 0, 0)
+	sts, _ := generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	initContainers := sts.Spec.Template.Spec.InitContainers
+	configFileData, _ := gabs.ParseJSON([]byte(GetEnvVarByName(initContainers[1].Env, "CONFIG_FILE_DATA").Value))
+	assert.Equal("PasswordAuthenticator", configFileData.Path("cassandra-yaml.authenticator").Data())
+	assert.Equal("CassandraAuthorizer", configFileData.Path("cassandra-yaml.authorizer").Data())
+	assert.Equal("CassandraRoleManager", configFileData.Path("cassandra-yaml.role_manager").Data())
+	//Encryption options are kept
+	assert.Equal("all", configFileData.Path(
+		"cassandra-yaml.server_encryption_options.internode_encryption").Data())
+}
+
+func TestGenerateCassandraStatefulSetWithCommitlogArchiving(t *testing.T) {
+	assert := assert.New(t)
+	dcName := "dc1"
+	dcRackName := fmt.Sprintf("%s-rack1", dcName)
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 0, 0)
+	sts, _ := generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	baseConfigBuilder := sts.Spec.Template.Spec.InitContainers[0]
+	assert.Equal(cassBaseConfigBuilderName, baseConfigBuilder.Name)
+	assert.Equal([]string{"-c", "cp -r /etc/cassandra/* /bootstrap/"}, baseConfigBuilder.Args)
+
+	cc.Spec.CommitlogArchiving = true
+	sts, _ = generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	script := sts.Spec.Template.Spec.InitContainers[0].Args[1]
+	assert.True(strings.HasPrefix(script, "cp -r /etc/cassandra/* /bootstrap/\n"))
+	assert.Contains(script,
+		"archive_command=/etc/cassandra/archive-commitlog.sh %path %name\" > /bootstrap/commitlog_archiving.properties")
+	assert.Contains(script, "ln -f \"$1\" /var/lib/cassandra/commitlog_archive/\"$2\"")
+}
+
+func TestGenerateCassandraStatefulSetWithRestoreFrom(t *testing.T) {
+	assert := assert.New(t)
+	dcName := "dc1"
+	dcRackName := fmt.Sprintf("%s-rack1", dcName)
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.RestoreFrom = &api.RestoreFrom{StorageLocation: "s3://cassandra-backups/", SnapshotTag: "weekly",
+		Secret: "cloud-backup-secrets"}
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 0, 0)
+	status := cc.Status.DeepCopy()
+	sts, _ := generateCassandraStatefulSet(cc, status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	initContainers := sts.Spec.Template.Spec.InitContainers
+	restoreContainer := initContainers[len(initContainers)-1]
+	assert.Equal(restoreContainerName, restoreContainer.Name)
+	assert.Equal(cc.Spec.BackRestSidecar.Image, restoreContainer.Image)
+	assert.Empty(restoreContainer.Ports)
+	assert.Equal("metadata.name", restoreContainer.Env[0].ValueFrom.FieldRef.FieldPath)
+	assert.Equal([]string{"sh", "-c", restoreScript, restoreContainerName}, restoreContainer.Command)
+	//The arguments are given to esop as they are
+	assert.Equal([]string{"--snapshot-tag=weekly", "--restoration-strategy-type=IN_PLACE",
+		"--update-cassandra-yaml=true", "--resolve-host-id-from-topology=true",
+		"--cassandra-directory=/var/lib/cassandra", "--cassandra-config-directory=/etc/cassandra",
+		"--k8s-namespace=ns", "--k8s-secret-name=cloud-backup-secrets"}, restoreContainer.Args)
+	assert.Contains(restoreScript,
+		`esop restore "$@" --storage-location="$STORAGE_LOCATION${POD_NAME#$CLUSTER_NAME}"`)
+	assert.Equal("cassandra-demo", GetEnvVarByName(restoreContainer.Env, "CLUSTER_NAME").Value)
+	assert.Equal("s3://cassandra-backups/cassandra-demo/dc1/cassandra-demo",
+		GetEnvVarByName(restoreContainer.Env, "STORAGE_LOCATION").Value)
+
+	//A cluster with another name restores the nodes of the cluster backed up
+	cc.Spec.RestoreFrom.ClusterName = "cassandra-prod"
+	sts, _ = generateCassandraStatefulSet(cc, status, dcName, dcRackName, labels, nodeSelector, nil)
+	initContainers = sts.Spec.Template.Spec.InitContainers
+	restoreContainer = initContainers[len(initContainers)-1]
+	assert.Equal("s3://cassandra-backups/cassandra-prod/dc1/cassandra-prod",
+		GetEnvVarByName(restoreContainer.Env, "STORAGE_LOCATION").Value)
+	assert.Equal("/var/lib/cassandra",
+		restoreContainer.VolumeMounts[getPos(restoreContainer.VolumeMounts, "data")].MountPath)
+
+	//Once restored, the container is kept so that the pods are not restarted. The nodes added later read from the
+	//ConfigMap created once restored that they must not restore the snapshot
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: api.ConditionRestored,
+		Status: metav1.ConditionTrue, Reason: "Restored"})
+	restoredSts, _ := generateCassandraStatefulSet(cc, status, dcName, dcRackName, labels, nodeSelector, nil)
+	assert.Equal(sts.Spec.Template, restoredSts.Spec.Template)
+	restored := GetEnvVarByName(restoreContainer.Env, "RESTORED").ValueFrom.ConfigMapKeyRef
+	assert.Equal("cassandra-demo-restored", restored.Name)
+	assert.True(*restored.Optional)
+}
+
 func TestCassandraStatefulSetHasNoDuplicateVolumes(t *testing.T) {
 	dcName := "dc1"
 	dcRackName := fmt.Sprintf("%s-rack1", dcName)
@@ -481,7 +636,7 @@ func TestCassandraStatefulSetHasNoDuplicateVolumes(t *testing.T) {
 		}
 	}
 	assert.Equal(cassandraLogVolumeMounts, 1, "Duplicate volume mount found in Cassandra container")
-	assert.Equal(len(sts.Spec.Template.Spec.Volumes), 4, "Volume defined when it is a VolumeClaim")
+	assert.Equal(len(sts.Spec.Template.Spec.Volumes), 5, "Volume defined when it is a VolumeClaim")
 }
 
 func checkResourcesConfiguration(t *testing.T, containers []v1.Container, cpu string, memory string) {
@@ -797,4 +952,61 @@ func checkInitContainerVarEnv(t *testing.T, initContainerEnvVar []v1.EnvVar, var
 			assert.Equal(value, env.Value)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestGenerateCassandraStatefulSetDrainsOnStop(t *testing.T) {
+	assert := assert.New(t)
+	dcName := "dc1"
+	dcRackName := "dc1-rack1"
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.CheckDefaults()
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 0, 0)
+	sts, _ := generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	assert.Equal(int64(api.DefaultTerminationGracePeriodSeconds),
+		*sts.Spec.Template.Spec.TerminationGracePeriodSeconds)
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if container.Name == cassandraContainerName {
+			assert.Equal([]string{"/bin/bash", "-c", "/etc/cassandra/pre-stop.sh"},
+				container.Lifecycle.PreStop.Exec.Command)
+		}
+	}
+
+	terminationPeriod := int64(600)
+	cc.Spec.TerminationGracePeriodSeconds = &terminationPeriod
+	sts, _ = generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+	assert.Equal(terminationPeriod, *sts.Spec.Template.Spec.TerminationGracePeriodSeconds)
+
+	//A bootstrap image without pre-stop.sh gets no PreStop hook
+	cc.Spec.BootstrapImage = "orangeopensource/cassandra-bootstrap:0.1.9"
+	sts, _ = generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if container.Name == cassandraContainerName {
+			assert.Nil(container.Lifecycle)
+		}
+	}
+}
+
+func TestBootstrapImageShipsPreStop(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		image string
+		ships bool
+	}{
+		{"orangeopensource/cassandra-bootstrap:0.1.10", true},
+		{"orangeopensource/cassandra-bootstrap:0.1.11", true},
+		{"orangeopensource/cassandra-bootstrap:0.2.0", true},
+		{"orangeopensource/cassandra-bootstrap:1.0", true},
+		{"orangeopensource/cassandra-bootstrap:0.1.10-rc1", true},
+		{"orangeopensource/cassandra-bootstrap:0.1.9", false},
+		{"orangeopensource/cassandra-bootstrap:0.1.4-ipv6", false},
+		{"orangeopensource/cassandra-bootstrap:0.1", false},
+		{"orangeopensource/cassandra-bootstrap:latest", true},
+		{"orangeopensource/cassandra-bootstrap", true},
+		{"registry:5000/cassandra-bootstrap", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.ships, bootstrapImageShipsPreStop(tt.image), tt.image)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"reflect"
+	"sort"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+)
+
+// dcIsRunning returns true when all the racks of a dc are running
+func dcIsRunning(cc *api.CassandraCluster, status *api.CassandraClusterStatus, dcName string) bool {
+	found := false
+	for dcRackName, dcRackStatus := range status.CassandraRackStatus {
+		if cc.GetDCNameFromDCRackName(dcRackName) != dcName {
+			continue
+		}
+		if dcRackStatus.Phase != api.ClusterPhaseRunning.Name {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// managedKeyspacesWithoutReplicaInDC returns the keyspaces of spec.keyspaces which won't have replicas in the dc
+// anymore. Their replication is altered by ReconcileKeyspaces before the dc is decommissioned
+func managedKeyspacesWithoutReplicaInDC(cc *api.CassandraCluster, dcName string) []string {
+	var keyspaces []string
+	for _, keyspace := range cc.Spec.Keyspaces {
+		if keyspace.Replication[dcName] == 0 || dcNodes(cc, dcName) == 0 {
+			keyspaces = append(keyspaces, keyspace.Name)
+		}
+	}
+	return keyspaces
+}
+
+// ReconcileKeyspaces applies the replication of spec.keyspaces with ALTER KEYSPACE.
+// A dc is added to the replication of a keyspace once all its racks are running, then its pods are labeled to
+// rebuild from a dc already having the data. A dc scaled down to 0 or removed from the topology is removed from the
+// replication before its nodes are decommissioned
+func (rcc *CassandraClusterReconciler) ReconcileKeyspaces(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	if len(cc.Spec.Keyspaces) == 0 {
+		return nil
+	}
+
+	pod, err := rcc.readyCassandraPod(cc)
+	if err != nil {
+		return err
+	}
+	jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(*pod), JolokiaPort, rcc,
+		cc.Spec.ImageJolokiaSecret, cc.Namespace)
+	if err != nil {
+		return err
+	}
+	username, password, err := rcc.cqlCredentials(cc, status)
+	if err != nil {
+		return err
+	}
+
+	if status.Keyspaces == nil {
+		status.Keyspaces = map[string]map[string]int32{}
+	}
+	//dc to rebuild -> dc to stream the data from
+	rebuildFrom := map[string]string{}
+
+	for _, keyspace := range cc.Spec.Keyspaces {
+		applied := status.Keyspaces[keyspace.Name]
+		replication := map[string]int32{}
+		var addedDCs, sourceDCs []string
+
+		for dcName, replicas := range keyspace.Replication {
+			if replicas == 0 || dcNodes(cc, dcName) == 0 {
+				continue
+			}
+			if _, ok := applied[dcName]; ok {
+				replication[dcName] = replicas
+				sourceDCs = append(sourceDCs, dcName)
+				continue
+			}
+			//The replication may have been set before the keyspace was managed by the operator
+			hasData, err := jolokiaClient.hasKeyspaceDataInDC(keyspace.Name, dcName)
+			if err != nil {
+				return err
+			}
+			if hasData {
+				replication[dcName] = replicas
+				sourceDCs = append(sourceDCs, dcName)
+				continue
+			}
+			if !dcIsRunning(cc, status, dcName) {
+				logrus.WithFields(logrus.Fields{"cluster": cc.Name, "keyspace": keyspace.Name,
+					"dc": dcName}).Info("Waiting for dc to be running before replicating keyspace to it")
+				continue
+			}
+			replication[dcName] = replicas
+			addedDCs = append(addedDCs, dcName)
+		}
+
+		//A keyspace can't be left without replicas
+		if len(replication) == 0 || reflect.DeepEqual(replication, applied) {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "keyspace": keyspace.Name,
+			"replication": replicationString(replication)}).Info("Update replication of keyspace")
+		if err = execCQL(cc, pod, username, password, "ALTER KEYSPACE "+cqlIdentifier(keyspace.Name)+
+			" WITH replication = "+replicationString(replication)); err != nil {
+			return err
+		}
+		status.Keyspaces[keyspace.Name] = replication
+
+		if len(sourceDCs) == 0 {
+			continue
+		}
+		sort.Strings(sourceDCs)
+		for _, dcName := range addedDCs {
+			rebuildFrom[dcName] = sourceDCs[0]
+		}
+	}
+
+	for dcName, sourceDC := range rebuildFrom {
+		labels := map[string]string{"operation-name": api.OperationRebuild, "operation-argument": sourceDC}
+		if cc.Spec.AutoPilot {
+			labels["operation-status"] = api.StatusToDo
+		} else {
+			labels["operation-status"] = api.StatusManual
+		}
+		for dc := 0; dc < cc.GetDCSize(); dc++ {
+			if cc.GetDCName(dc) != dcName {
+				continue
+			}
+			for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+				rcc.addPodOperationLabels(cc, dcName, cc.GetRackName(dc, rack), labels)
+			}
+		}
+	}
+
+	//Keyspaces removed from spec.keyspaces are not managed anymore
+	for name := range status.Keyspaces {
+		managed := false
+		for _, keyspace := range cc.Spec.Keyspaces {
+			managed = managed || keyspace.Name == name
+		}
+		if !managed {
+			delete(status.Keyspaces, name)
+		}
+	}
+	return nil
+}
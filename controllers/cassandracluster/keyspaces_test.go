@@ -0,0 +1,96 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func helperCreateCassandraPod(t *testing.T, rcc *CassandraClusterReconciler, cc *api.CassandraCluster,
+	dcName, rackName string) {
+	name := cc.Name + "-" + cc.GetDCRackName(dcName, rackName) + "-0"
+	err := rcc.Client.Create(context.TODO(), &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cc.Namespace,
+			Labels: k8s.LabelsForCassandraDCRack(cc, dcName, rackName)},
+		Spec: v1.PodSpec{Hostname: name, Subdomain: cc.Name},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{{Name: cassandraContainerName, Ready: true}},
+		},
+	})
+	assert.Nil(t, err)
+}
+
+func TestReconcileKeyspaces(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.Keyspaces = []api.KeyspaceReplication{{Name: "demo", Replication: map[string]int32{"dc1": 2, "dc2": 1}}}
+	status := cc.Status.DeepCopy()
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2"} {
+		status.CassandraRackStatus[dcRackName].Phase = api.ClusterPhaseRunning.Name
+	}
+	helperCreateCassandraPod(t, rcc, cc, "dc1", "rack1")
+	helperCreateCassandraPod(t, rcc, cc, "dc2", "rack1")
+	statements := helperMockExecCQL(t, "")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	//demo is only replicated to dc1
+	httpmock.RegisterResponder("POST", JolokiaURL("cassandra-demo-dc1-rack1-0.cassandra-demo", JolokiaPort),
+		httpmock.NewStringResponder(200, `{"request": {"mbean": "org.apache.cassandra.db:type=StorageService",
+				"arguments": ["demo"], "type": "exec", "operation": "describeRingJMX"},
+			"value": ["TokenRange(start_token:4572538884437204647, end_token:4764428918503636065, endpoints:[10.244.3.8], rpc_endpoints:[10.244.3.8], endpoint_details:[EndpointDetails(host:10.244.3.8, datacenter:dc1, rack:rack1)])"],
+			"timestamp": 1541908753, "status": 200}`))
+
+	//dc2 is not running yet, demo stays in dc1
+	assert.Nil(rcc.ReconcileKeyspaces(cc, status))
+	assert.Equal([]cqlStatement{{"cassandra",
+		`ALTER KEYSPACE "demo" WITH replication = {'class': 'NetworkTopologyStrategy', 'dc1': 2}`}}, *statements)
+	assert.Equal(map[string]int32{"dc1": 2}, status.Keyspaces["demo"])
+
+	//dc2 is running, demo is replicated to it and its pods rebuild from dc1
+	*statements = nil
+	status.CassandraRackStatus["dc2-rack1"].Phase = api.ClusterPhaseRunning.Name
+	assert.Nil(rcc.ReconcileKeyspaces(cc, status))
+	assert.Equal([]cqlStatement{{"cassandra", `ALTER KEYSPACE "demo" WITH replication = ` +
+		`{'class': 'NetworkTopologyStrategy', 'dc1': 2, 'dc2': 1}`}}, *statements)
+	pod := &v1.Pod{}
+	rcc.Client.Get(context.TODO(), types.NamespacedName{Name: "cassandra-demo-dc2-rack1-0",
+		Namespace: cc.Namespace}, pod)
+	assert.Equal(api.OperationRebuild, pod.Labels["operation-name"])
+	assert.Equal("dc1", pod.Labels["operation-argument"])
+
+	//Nothing has changed
+	*statements = nil
+	assert.Nil(rcc.ReconcileKeyspaces(cc, status))
+	assert.Empty(*statements)
+
+	//dc2 is scaled down to 0, demo is not replicated to it anymore before its decommission
+	cc.Spec.Topology.DC[1].NodesPerRacks = func(i int32) *int32 { return &i }(0)
+	assert.Equal([]string{"demo"}, managedKeyspacesWithoutReplicaInDC(cc, "dc2"))
+	assert.Nil(rcc.ReconcileKeyspaces(cc, status))
+	assert.Equal([]cqlStatement{{"cassandra",
+		`ALTER KEYSPACE "demo" WITH replication = {'class': 'NetworkTopologyStrategy', 'dc1': 2}`}}, *statements)
+	assert.Equal(map[string]int32{"dc1": 2}, status.Keyspaces["demo"])
+}
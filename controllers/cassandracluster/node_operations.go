@@ -18,6 +18,8 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strings"
 
 	"context"
 
@@ -235,6 +237,97 @@ func (jolokiaClient *JolokiaClient) NodeRebuild(dc string) error {
 	return nil
 }
 
+/*NodeRepair starts a repair of a keyspace on the pod using a jolokia Client and returns the number of the repair
+command*/
+func (jolokiaClient *JolokiaClient) NodeRepair(keyspace string, options map[string]string) (int, error) {
+	result, err := checkJolokiaErrors(jolokiaClient.executeOperation("org.apache.cassandra.db:type=StorageService",
+		"repairAsync(java.lang.String,java.util.Map)",
+		[]interface{}{keyspace, options}, ""))
+	if err != nil {
+		return 0, fmt.Errorf("Cannot repair keyspace %s: %v", keyspace, err.Error())
+	}
+	command, ok := result.Value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("Cannot repair keyspace %s: unexpected command %v", keyspace, result.Value)
+	}
+	return int(command), nil
+}
+
+/*repairThreadPools returns the thread pools of the repair commands running on the node. Each command has its own
+thread pool named Repair#<command> until it ends*/
+func (jolokiaClient *JolokiaClient) repairThreadPools() ([]string, error) {
+	beans, err := jolokiaClient.client.ListBeans("org.apache.cassandra.internal")
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list repair thread pools: %v", err.Error())
+	}
+	threadPools := []string{}
+	for _, bean := range beans {
+		if strings.HasPrefix(bean, "type=Repair#") {
+			threadPools = append(threadPools, strings.TrimPrefix(bean, "type="))
+		}
+	}
+	return threadPools, nil
+}
+
+func (jolokiaClient *JolokiaClient) hasRepairSessions() (bool, error) {
+	threadPools, err := jolokiaClient.repairThreadPools()
+	if err != nil {
+		return true, err
+	}
+	return len(threadPools) > 0, nil
+}
+
+func (jolokiaClient *JolokiaClient) repairIsRunning(command int) (bool, error) {
+	threadPools, err := jolokiaClient.repairThreadPools()
+	if err != nil {
+		return true, err
+	}
+	return funk.ContainsString(threadPools, fmt.Sprintf("Repair#%d", command)), nil
+}
+
+/*repairFailure returns why a repair command failed, or an empty string if it succeeded. Cassandra keeps the status
+of the repair commands since 4.0 only, a status which can't be read is an error*/
+func (jolokiaClient *JolokiaClient) repairFailure(command int) (string, error) {
+	result, err := checkJolokiaErrors(jolokiaClient.executeOperation("org.apache.cassandra.db:type=StorageService",
+		"getParentRepairStatus", []interface{}{command}, ""))
+	if err != nil {
+		return "", fmt.Errorf("Cannot get status of repair #%d: %v", command, err.Error())
+	}
+	repairStatus, _ := result.Value.([]interface{})
+	if len(repairStatus) == 0 {
+		return "", fmt.Errorf("Cannot get status of repair #%d: unknown command", command)
+	}
+	if repairStatus[0] != "FAILED" {
+		return "", nil
+	}
+	return fmt.Sprintf("%v", repairStatus[1:]), nil
+}
+
+/*primaryRanges returns the token ranges of a keyspace for which the node is the first replica*/
+func (jolokiaClient *JolokiaClient) primaryRanges(keyspace, nodeIP string) ([][2]string, error) {
+	result, err := checkJolokiaErrors(jolokiaClient.executeOperation("org.apache.cassandra.db:type=StorageService",
+		"getRangeToEndpointMap", []interface{}{keyspace}, ""))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get token ranges of keyspace %s: %v", keyspace, err.Error())
+	}
+	rangeToEndpoints, _ := result.Value.(map[string]interface{})
+	var ranges [][2]string
+	for tokenRange, endpoints := range rangeToEndpoints {
+		replicas, _ := endpoints.([]interface{})
+		if len(replicas) == 0 || replicas[0] != nodeIP {
+			continue
+		}
+		// A range is serialized as [start, end]
+		tokens := strings.Split(strings.Trim(tokenRange, "[]"), ", ")
+		if len(tokens) != 2 {
+			return nil, fmt.Errorf("Cannot parse token range %s", tokenRange)
+		}
+		ranges = append(ranges, [2]string{tokens[0], tokens[1]})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	return ranges, nil
+}
+
 /*NodeDecommission decommissions a node using a jolokia client and returns any error*/
 func (jolokiaClient *JolokiaClient) NodeDecommission(v4 bool) error {
 	args:=[]interface{}{}
@@ -367,4 +460,61 @@ func (jolokiaClient *JolokiaClient) hasJoiningNodes() (bool, error) {
 	}
 	v, _ := result.Value.([]interface{})
 	return len(v)>0, nil
-}
\ No newline at end of file
+}
+func (jolokiaClient *JolokiaClient) unreachableNodes() ([]string, error) {
+	request := go_jolokia.NewJolokiaRequest(go_jolokia.READ,
+		"org.apache.cassandra.db:type=StorageService", nil, "UnreachableNodes")
+	result, err := checkJolokiaErrors(jolokiaClient.executeReadRequest(request))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get list of unreachable nodes: %v", err.Error())
+	}
+	nodes := []string{}
+	v, _ := result.Value.([]interface{})
+	for _, node := range v {
+		nodes = append(nodes, node.(string))
+	}
+	return nodes, nil
+}
+
+/*schemaVersions returns the schema versions of the cluster as nodetool describecluster shows them, unreachable
+nodes are listed under the UNREACHABLE version*/
+func (jolokiaClient *JolokiaClient) schemaVersions() (map[string][]string, error) {
+	request := go_jolokia.NewJolokiaRequest(go_jolokia.READ,
+		"org.apache.cassandra.db:type=StorageProxy", nil, "SchemaVersions")
+	result, err := checkJolokiaErrors(jolokiaClient.executeReadRequest(request))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get schema versions: %v", err.Error())
+	}
+	schemaVersions := map[string][]string{}
+	v, _ := result.Value.(map[string]interface{})
+	for version, hosts := range v {
+		list, _ := hosts.([]interface{})
+		for _, host := range list {
+			schemaVersions[version] = append(schemaVersions[version], host.(string))
+		}
+	}
+	return schemaVersions, nil
+}
+
+/*ReleaseVersion returns the version of Cassandra run by the node using a jolokia client and returns any error*/
+func (jolokiaClient *JolokiaClient) ReleaseVersion() (string, error) {
+	request := go_jolokia.NewJolokiaRequest(go_jolokia.READ,
+		"org.apache.cassandra.db:type=StorageService", nil, "ReleaseVersion")
+	result, err := checkJolokiaErrors(jolokiaClient.executeReadRequest(request))
+	if err != nil {
+		return "", fmt.Errorf("Cannot get ReleaseVersion: %v", err.Error())
+	}
+	v, _ := result.Value.(string)
+	return v, nil
+}
+
+/*NodeDrain flushes the memtables of the node and stops it from accepting writes using a jolokia client and returns
+any error*/
+func (jolokiaClient *JolokiaClient) NodeDrain() error {
+	_, err := checkJolokiaErrors(jolokiaClient.executeOperation("org.apache.cassandra.db:type=StorageService",
+		"drain", []interface{}{}, ""))
+	if err != nil {
+		return fmt.Errorf("Cannot drain: %v", err.Error())
+	}
+	return nil
+}
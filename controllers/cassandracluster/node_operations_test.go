@@ -415,6 +415,47 @@ func TestNodeDecommission(t *testing.T) {
 	}
 }
 
+func TestNodeDrain(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort),
+		httpmock.NewStringResponder(200, `{"request": {"mbean": "org.apache.cassandra.db:type=StorageService",
+							       "arguments": [],
+							       "type": "exec",
+							       "operation": "drain"},
+						   "value": null,
+					  	   "timestamp": 1528848808,
+						   "status": 200}`))
+	jolokiaClient, _ := NewJolokiaClient(host, jolokiaPort, nil, v1.LocalObjectReference{}, "ns")
+	err := jolokiaClient.NodeDrain()
+	if err != nil {
+		t.Errorf("NodeDrain failed with : %v", err)
+	}
+}
+
+func TestSchemaVersions(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort),
+		httpmock.NewStringResponder(200, `{"request": {"mbean": "org.apache.cassandra.db:type=StorageProxy",
+							       "attribute": "SchemaVersions",
+							       "type": "read"},
+						   "value": {"59adb24e-f3cd-3e02-97f0-5b395827453f": ["10.244.2.5", "10.244.3.7"],
+							     "UNREACHABLE": ["10.244.3.8"]},
+					  	   "timestamp": 1528848808,
+						   "status": 200}`))
+	jolokiaClient, _ := NewJolokiaClient(host, jolokiaPort, nil, v1.LocalObjectReference{}, "ns")
+	schemaVersions, err := jolokiaClient.schemaVersions()
+	if err != nil {
+		t.Errorf("schemaVersions failed with : %v", err)
+	}
+	expected := map[string][]string{"59adb24e-f3cd-3e02-97f0-5b395827453f": {"10.244.2.5", "10.244.3.7"},
+		"UNREACHABLE": {"10.244.3.8"}}
+	if !reflect.DeepEqual(schemaVersions, expected) {
+		t.Errorf("schemaVersions returned a bad answer: %v", schemaVersions)
+	}
+}
+
 func TestNodeOperationMode(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -480,3 +521,106 @@ func TestHostIDMap(t *testing.T) {
 		t.Errorf("hostIDMap returned a bad answer: %s", hostIDMap)
 	}
 }
+
+func TestNodeRepair(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort),
+		httpmock.NewStringResponder(200, `{"request": {"mbean": "org.apache.cassandra.db:type=StorageService",
+							       "arguments": ["demo1", {"primaryRange": "true"}],
+							       "type": "exec",
+							       "operation": "repairAsync(java.lang.String,java.util.Map)"},
+						   "value": 3,
+					  	   "timestamp": 1528848808,
+						   "status": 200}`))
+	jolokiaClient, _ := NewJolokiaClient(host, JolokiaPort, nil,
+		v1.LocalObjectReference{}, "ns")
+	command, err := jolokiaClient.NodeRepair("demo1", map[string]string{"primaryRange": "true"})
+	if err != nil {
+		t.Errorf("NodeRepair failed with : %s", err)
+	}
+	if command != 3 {
+		t.Errorf("NodeRepair returned a bad command: %d", command)
+	}
+}
+
+func TestRepairFailure(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	repairStatus := `["FAILED", "Repair session failed"]`
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort),
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, `{"request": {"mbean": "org.apache.cassandra.db:type=StorageService",
+							       "arguments": [3],
+							       "type": "exec",
+							       "operation": "getParentRepairStatus"},
+						   "value": `+repairStatus+`,
+						   "timestamp": 1528848808,
+						   "status": 200}`), nil
+		})
+	jolokiaClient, _ := NewJolokiaClient(host, JolokiaPort, nil,
+		v1.LocalObjectReference{}, "ns")
+
+	failure, err := jolokiaClient.repairFailure(3)
+	if err != nil || failure != "[Repair session failed]" {
+		t.Errorf("repairFailure returned a bad answer: %v %v", failure, err)
+	}
+
+	repairStatus = `["COMPLETED"]`
+	failure, err = jolokiaClient.repairFailure(3)
+	if err != nil || failure != "" {
+		t.Errorf("repairFailure returned a bad answer: %v %v", failure, err)
+	}
+
+	//A command the node does not know is not considered successful
+	repairStatus = `null`
+	if _, err = jolokiaClient.repairFailure(3); err == nil {
+		t.Errorf("repairFailure should fail for an unknown command")
+	}
+}
+
+func TestRepairIsRunning(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	//The list requests of go_jolokia add a / to the url
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort)+"/",
+		httpmock.NewStringResponder(200, `{"request": {"path": "org.apache.cassandra.internal", "type": "list"},
+			"value": {"type=CompactionExecutor": {}, "type=Repair#3": {}},
+			"timestamp": 1528850319,
+			"status": 200}`))
+	jolokiaClient, _ := NewJolokiaClient(host, JolokiaPort, nil,
+		v1.LocalObjectReference{}, "ns")
+	running, err := jolokiaClient.repairIsRunning(3)
+	if err != nil || !running {
+		t.Errorf("repairIsRunning returned a bad answer: %v %v", running, err)
+	}
+	running, err = jolokiaClient.repairIsRunning(4)
+	if err != nil || running {
+		t.Errorf("repairIsRunning returned a bad answer: %v %v", running, err)
+	}
+	hasRepairSessions, err := jolokiaClient.hasRepairSessions()
+	if err != nil || !hasRepairSessions {
+		t.Errorf("hasRepairSessions returned a bad answer: %v %v", hasRepairSessions, err)
+	}
+}
+
+func TestPrimaryRanges(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort),
+		httpmock.NewStringResponder(200, `{"request": {"mbean": "org.apache.cassandra.db:type=StorageService",
+				"arguments": ["demo1"], "type": "exec", "operation": "getRangeToEndpointMap"},
+			"value": {"[-100, 200]": ["10.244.3.8", "10.244.3.9"],
+				"[200, -100]": ["10.244.3.9", "10.244.3.8"]},
+			"timestamp": 1528850319,
+			"status": 200}`))
+	jolokiaClient, _ := NewJolokiaClient(host, JolokiaPort, nil,
+		v1.LocalObjectReference{}, "ns")
+	ranges, err := jolokiaClient.primaryRanges("demo1", "10.244.3.8")
+	if err != nil {
+		t.Errorf("primaryRanges failed with : %v", err)
+	}
+	if !reflect.DeepEqual(ranges, [][2]string{{"-100", "200"}}) {
+		t.Errorf("primaryRanges returned a bad answer: %v", ranges)
+	}
+}
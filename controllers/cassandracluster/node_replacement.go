@@ -0,0 +1,230 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// replaceNodesName returns the name of the ConfigMap holding the address of the Cassandra node each pod replaces, by
+// pod name. It is mounted in the bootstrap container, so that only the pod which replaces the node gets its address
+func replaceNodesName(cc *api.CassandraCluster) string {
+	return cc.Name + "-replace-nodes"
+}
+
+// setReplaceAddress sets the address of the Cassandra node a pod replaces, removes it when address is empty
+func (rcc *CassandraClusterReconciler) setReplaceAddress(cc *api.CassandraCluster, podName, address string) error {
+	replaceNodes := &v1.ConfigMap{}
+	err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: replaceNodesName(cc), Namespace: cc.Namespace},
+		replaceNodes)
+	if apierrors.IsNotFound(err) {
+		if address == "" {
+			return nil
+		}
+		replaceNodes = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: replaceNodesName(cc),
+			Namespace: cc.Namespace, Labels: k8s.LabelsForCassandra(cc)},
+			Data: map[string]string{podName: address}}
+		k8s.AddOwnerRefToObject(replaceNodes, k8s.AsOwner(cc))
+		return rcc.Client.Create(context.TODO(), replaceNodes)
+	}
+	if err != nil {
+		return err
+	}
+
+	if address == "" {
+		delete(replaceNodes.Data, podName)
+	} else {
+		if replaceNodes.Data == nil {
+			replaceNodes.Data = map[string]string{}
+		}
+		replaceNodes.Data[podName] = address
+	}
+	return rcc.Client.Update(context.TODO(), replaceNodes)
+}
+
+// replaceNode replaces the Cassandra node of a pod by a new one bootstrapped on empty volumes: the persistent volume
+// claims of the pod are deleted along with it, and the new pod gets the address of the node to take over its token
+// ranges. The address is taken from status.CassandraNodesStatus when address is empty
+func (rcc *CassandraClusterReconciler) replaceNode(cc *api.CassandraCluster, status *api.CassandraClusterStatus,
+	pod *v1.Pod, address string) error {
+	dcRackName := pod.Labels["dc-rack"]
+	dcRackStatus, exists := status.CassandraRackStatus[dcRackName]
+	if !exists {
+		return fmt.Errorf("rack %s of pod %s not found", dcRackName, pod.Name)
+	}
+	if dcRackStatus.NodeReplacement != nil {
+		return fmt.Errorf("the node of pod %s is already being replaced in rack %s",
+			dcRackStatus.NodeReplacement.Pod, dcRackName)
+	}
+	nodeStatus := status.CassandraNodesStatus[pod.Name]
+	if address == "" {
+		address = nodeStatus.NodeIp
+	}
+	if address == "" {
+		return fmt.Errorf("address of the Cassandra node of pod %s is unknown", pod.Name)
+	}
+	hostID := ""
+	if nodeStatus.NodeIp == address {
+		hostID = nodeStatus.HostId
+	}
+
+	if err := rcc.setReplaceAddress(cc, pod.Name, address); err != nil {
+		return err
+	}
+	dcRackStatus.NodeReplacement = &api.NodeReplacement{Pod: pod.Name, Address: address, HostID: hostID,
+		StartTime: metav1.Now()}
+
+	dcName, rackName := cc.GetDCNameAndRackNameFromDCRackName(dcRackName)
+	pvcs, err := rcc.ListPVC(cc.Namespace, k8s.LabelsForCassandraDCRack(cc, dcName, rackName))
+	if err != nil {
+		return err
+	}
+	for i, pvc := range pvcs.Items {
+		if !strings.HasSuffix(pvc.Name, "-"+pod.Name) {
+			continue
+		}
+		if err := rcc.deletePVC(&pvcs.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+			"pvc": pvc.Name}).Info("PVC deleted to replace the node")
+	}
+
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+		"address": address, "hostID": hostID}).Info("Pod deleted, its Cassandra node is replaced")
+	if err := rcc.deletePodOnNode(pod); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// startRequestedNodeReplacements replaces the Cassandra nodes of the pods labeled with operation-name=replacenode
+// and operation-status=ToDo. The label operation-argument can give the address of the node to replace when it is
+// not in status.CassandraNodesStatus. A replacement which can't start is recorded in the operation history and
+// its pod labeled with operation-status=Error
+func (rcc *CassandraClusterReconciler) startRequestedNodeReplacements(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	podsList, err := rcc.ListPods(cc.Namespace, k8s.MergeLabels(k8s.LabelsForCassandra(cc),
+		map[string]string{"operation-name": api.OperationReplaceNode, "operation-status": api.StatusToDo}))
+	if err != nil {
+		return err
+	}
+	for i := range podsList.Items {
+		pod := &podsList.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := rcc.replaceNode(cc, status, pod, pod.Labels["operation-argument"]); err != nil {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "pod": pod.Name,
+				"err": err}).Error("Can't replace the Cassandra node")
+			rcc.Recorder.Event(cc, v1.EventTypeWarning, "NodeReplacementFailed",
+				fmt.Sprintf("Can't replace the Cassandra node of pod %s: %v", pod.Name, err))
+			addOperationHistory(status, pod.Labels["dc-rack"], *pod, api.OperationReplaceNode, err)
+			if err := rcc.UpdatePodLabel(pod, map[string]string{"operation-status": api.StatusError,
+				"operation-end": k8s.LabelTime()}); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		rcc.Recorder.Event(cc, v1.EventTypeNormal, "NodeReplacementStarted",
+			fmt.Sprintf("Replacing Cassandra node %s of pod %s",
+				status.CassandraRackStatus[pod.Labels["dc-rack"]].NodeReplacement.Address, pod.Name))
+	}
+	return nil
+}
+
+// nodeReplacementIsDone returns true once the Cassandra node of a new pod has taken over the node it replaces: it
+// is in NORMAL mode, no longer streams data and, when the host ID of the replaced node is known, owns it
+func (rcc *CassandraClusterReconciler) nodeReplacementIsDone(cc *api.CassandraCluster, pod *v1.Pod,
+	replacement *api.NodeReplacement) (bool, error) {
+	jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(*pod), JolokiaPort, rcc,
+		cc.Spec.ImageJolokiaSecret, cc.Namespace)
+	if err != nil {
+		return false, err
+	}
+	mode, err := jolokiaClient.NodeOperationMode()
+	if err != nil || mode != NORMAL {
+		return false, err
+	}
+	streaming, err := jolokiaClient.hasStreamingSessions()
+	if err != nil || streaming {
+		return false, err
+	}
+	if replacement.HostID == "" {
+		return true, nil
+	}
+	hostIDMap, err := jolokiaClient.hostIDMap()
+	if err != nil {
+		return false, err
+	}
+	if hostID := hostIDMap[pod.Status.PodIP]; hostID != replacement.HostID {
+		return false, fmt.Errorf("node %s has host ID %s instead of %s", pod.Status.PodIP, hostID,
+			replacement.HostID)
+	}
+	return true, nil
+}
+
+// ReconcileNodeReplacements starts the replacements of Cassandra nodes requested with pod labels and ends the ones
+// whose new node has taken over, removing the address of the replaced node so that the pod does not replace it
+// again
+func (rcc *CassandraClusterReconciler) ReconcileNodeReplacements(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	if err := rcc.startRequestedNodeReplacements(cc, status); err != nil {
+		return err
+	}
+	for dcRackName, dcRackStatus := range status.CassandraRackStatus {
+		replacement := dcRackStatus.NodeReplacement
+		if replacement == nil {
+			continue
+		}
+		pod, err := rcc.GetPod(cc.Namespace, replacement.Pod)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if pod.CreationTimestamp.Before(&replacement.StartTime) || !cassandraPodIsReady(pod) {
+			continue
+		}
+		if done, err := rcc.nodeReplacementIsDone(cc, pod, replacement); !done {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+				"address": replacement.Address, "err": err}).Info("Waiting for the Cassandra node to be replaced")
+			continue
+		}
+
+		if err := rcc.setReplaceAddress(cc, replacement.Pod, ""); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+			"address": replacement.Address}).Info("Cassandra node replaced")
+		rcc.Recorder.Event(cc, v1.EventTypeNormal, "NodeReplaced",
+			fmt.Sprintf("Cassandra node %s of pod %s replaced", replacement.Address, pod.Name))
+		addOperationHistory(status, dcRackName, *pod, api.OperationReplaceNode, nil)
+		status.OperationHistory[len(status.OperationHistory)-1].StartTime = &replacement.StartTime
+		dcRackStatus.NodeReplacement = nil
+	}
+	return nil
+}
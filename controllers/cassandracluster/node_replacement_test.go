@@ -0,0 +1,136 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// helperMockReplaceJolokia answers the jolokia requests sent to the new node of a replacement
+func helperMockReplaceJolokia(t *testing.T, hostName, operationMode, currentStreams, hostIDMap string) {
+	httpmock.RegisterResponder("POST", JolokiaURL(hostName, jolokiaPort),
+		func(req *http.Request) (*http.Response, error) {
+			var execrequestdata execRequestData
+			if err := json.NewDecoder(req.Body).Decode(&execrequestdata); err != nil {
+				t.Error("Can't decode request received")
+			}
+			value := "null"
+			switch execrequestdata.Attribute {
+			case "OperationMode":
+				value = `"` + operationMode + `"`
+			case "CurrentStreams":
+				value = currentStreams
+			case "HostIdMap":
+				value = hostIDMap
+			}
+			return httpmock.NewStringResponse(200, fmt.Sprintf(`{"value": %s, "timestamp": 1528848808,
+				"status": 200}`, value)), nil
+		})
+}
+
+func TestReplaceNodeRequestedByLabel(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	pod := helperCreatePendingPod(rcc, cc)
+	rcc.UpdatePodLabel(pod, map[string]string{"operation-name": api.OperationReplaceNode,
+		"operation-status": api.StatusToDo})
+
+	//The replacement can't start without the address of the node
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	pod, _ = rcc.GetPod(pod.Namespace, pod.Name)
+	assert.Equal(api.StatusError, pod.Labels["operation-status"])
+	assert.Equal(1, len(status.OperationHistory))
+	assert.Equal(api.OperationReplaceNode, status.OperationHistory[0].Name)
+	assert.Equal(api.StatusError, status.OperationHistory[0].Status)
+
+	//The address can be given in the label operation-argument
+	rcc.UpdatePodLabel(pod, map[string]string{"operation-status": api.StatusToDo,
+		"operation-argument": "10.244.3.8"})
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	_, err := rcc.GetPod(pod.Namespace, pod.Name)
+	assert.NotNil(err)
+	_, err = rcc.GetPVC(pod.Namespace, "data-"+pod.Name)
+	assert.NotNil(err)
+	replacement := status.CassandraRackStatus["dc1-rack1"].NodeReplacement
+	assert.Equal("10.244.3.8", replacement.Address)
+	assert.Equal("", replacement.HostID)
+}
+
+func TestReconcileNodeReplacements(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	pod := helperCreatePendingPod(rcc, cc)
+	status.CassandraNodesStatus = map[string]api.CassandraNodeStatus{
+		pod.Name: {HostId: "ae6ed2b8-3c3b-4a41-9e2a-1c0e1d06e3a5", NodeIp: "10.244.3.8"}}
+	assert.Nil(rcc.replaceNode(cc, status, pod, ""))
+	replacement := status.CassandraRackStatus["dc1-rack1"].NodeReplacement
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	//Nothing is checked until the new pod is ready
+	newPod := helperCreateUpgradePod(rcc, 0)
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	assert.NotNil(status.CassandraRackStatus["dc1-rack1"].NodeReplacement)
+	assert.Equal(0, httpmock.GetTotalCallCount())
+
+	//The replacement goes on while the new node streams data
+	newPod.CreationTimestamp = metav1.NewTime(replacement.StartTime.Add(time.Minute))
+	newPod.Status.PodIP = "10.244.3.9"
+	rcc.UpdatePod(newPod)
+	hostName := k8s.PodHostname(*newPod)
+	helperMockReplaceJolokia(t, hostName, "JOINING", `[]`, `{}`)
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	assert.NotNil(status.CassandraRackStatus["dc1-rack1"].NodeReplacement)
+	helperMockReplaceJolokia(t, hostName, "NORMAL", `[{"planId": "b5c6f2e0"}]`, `{}`)
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	assert.NotNil(status.CassandraRackStatus["dc1-rack1"].NodeReplacement)
+
+	//The new node must have taken over the host ID of the replaced one
+	helperMockReplaceJolokia(t, hostName, "NORMAL", `[]`,
+		`{"10.244.3.9": "0e5b1c2f-9f6a-4d2c-8a41-3b1d7c0e6f42"}`)
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	assert.NotNil(status.CassandraRackStatus["dc1-rack1"].NodeReplacement)
+
+	helperMockReplaceJolokia(t, hostName, "NORMAL", `[]`,
+		`{"10.244.3.9": "ae6ed2b8-3c3b-4a41-9e2a-1c0e1d06e3a5"}`)
+	assert.Nil(rcc.ReconcileNodeReplacements(cc, status))
+	assert.Nil(status.CassandraRackStatus["dc1-rack1"].NodeReplacement)
+	replaceNodes := &v1.ConfigMap{}
+	assert.Nil(rcc.Client.Get(context.TODO(), types.NamespacedName{Name: replaceNodesName(cc),
+		Namespace: cc.Namespace}, replaceNodes))
+	_, found := replaceNodes.Data["cassandra-demo-dc1-rack1-0"]
+	assert.False(found)
+	assert.Equal(api.OperationReplaceNode, status.OperationHistory[0].Name)
+	assert.Equal(api.StatusDone, status.OperationHistory[0].Status)
+	assert.Equal(replacement.StartTime, *status.OperationHistory[0].StartTime)
+}
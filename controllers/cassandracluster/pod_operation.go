@@ -27,6 +27,7 @@ import (
 
 	api "github.com/Orange-OpenSource/casskop/api/v2"
 	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/Orange-OpenSource/casskop/version"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -54,6 +55,7 @@ const (
 	NORMAL operationMode = "NORMAL"
 	LEAVING = "LEAVING"
 	DECOMMISSIONED = "DECOMMISSIONED"
+	DRAINED = "DRAINED"
 	UNKNOWN = "UNKNOWN"
 )
 
@@ -65,7 +67,9 @@ var podOperationMap = map[string]op{
 	api.OperationUpgradeSSTables: {(*CassandraClusterReconciler).runUpgradeSSTables,
 		(*JolokiaClient).hasUpgradeSSTablesCompactions, nil},
 	api.OperationRemove:          {(*CassandraClusterReconciler).runRemove,
-		(*JolokiaClient).hasLeavingNodes,(*CassandraClusterReconciler).postRunRemove}}
+		(*JolokiaClient).hasLeavingNodes,(*CassandraClusterReconciler).postRunRemove},
+	api.OperationRepair:          {(*CassandraClusterReconciler).runRepair,
+		(*JolokiaClient).hasRepairSessions, nil}}
 
 const breakResyncLoop    = true
 const continueResyncLoop = false
@@ -632,6 +636,29 @@ func (rcc *CassandraClusterReconciler) updatePodLastOperation(clusterName, dcRac
 	podLastOperation.Pods = k8s.RemoveString(podLastOperation.Pods, podName)
 }
 
+// addOperationHistory records the outcome of an operation on a pod in status.OperationHistory, keeping only the
+// last api.MaxOperationHistory operations
+func addOperationHistory(status *api.CassandraClusterStatus, dcRackName string, pod v1.Pod, operationName string,
+	err error) {
+	now := metav1.Now()
+	record := api.PodOperationRecord{Name: strings.ToLower(operationName), Pod: pod.Name, DCRack: dcRackName,
+		Status: api.StatusDone, EndTime: &now, OperatorName: os.Getenv("POD_NAME"), OperatorVersion: version.Version}
+	// The pod given to the operation may not have the labels set when it started
+	if startTime, err := k8s.LabelTime2Time(pod.Labels["operation-start"]); err == nil {
+		record.StartTime = &metav1.Time{Time: startTime}
+	} else if dcRackStatus, ok := status.CassandraRackStatus[dcRackName]; ok {
+		record.StartTime = dcRackStatus.PodLastOperation.StartTime
+	}
+	if err != nil {
+		record.Status = api.StatusError
+		record.Error = err.Error()
+	}
+	status.OperationHistory = append(status.OperationHistory, record)
+	if len(status.OperationHistory) > api.MaxOperationHistory {
+		status.OperationHistory = status.OperationHistory[len(status.OperationHistory)-api.MaxOperationHistory:]
+	}
+}
+
 /* finalizeOperation sets the labels on the pod where ran an operation depending on the error status
    It also updates status.CassandraRackStatus[dcRackName].PodLastOperation
 */
@@ -648,6 +675,7 @@ func (rcc *CassandraClusterReconciler) finalizeOperation(err error, cc *api.Cass
 	ccRefreshed := cc.DeepCopy()
 
 	rcc.updatePodLastOperation(cc.Name, dcRackName, pod.Name, strings.Title(operationName), status, err)
+	addOperationHistory(status, dcRackName, pod, operationName, err)
 
 	for {
 		if err = rcc.UpdatePodLabel(&pod, labels); err != nil {
@@ -749,6 +777,50 @@ func (rcc *CassandraClusterReconciler) runRebuild(hostName string, cc *api.Cassa
 	return err
 }
 
+func (rcc *CassandraClusterReconciler) runRepair(hostName string, cc *api.CassandraCluster, dcRackName string,
+	pod v1.Pod) error {
+	operation := strings.Title(api.OperationRepair)
+
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+		"hostName": hostName, "operation": operation}).Info("Operation start")
+
+	jolokiaClient, err := NewJolokiaClient(hostName, JolokiaPort, rcc,
+		cc.Spec.ImageJolokiaSecret, cc.Namespace)
+	if err != nil {
+		return err
+	}
+	keyspaces, err := repairKeyspaces(cc, jolokiaClient)
+	if err != nil {
+		return err
+	}
+
+	// A failing keyspace does not prevent the others from being repaired
+	failedKeyspaces := []string{}
+	for _, keyspace := range keyspaces {
+		optionsList, err := repairOptions(cc.Spec.Repair, jolokiaClient, keyspace, pod.Status.PodIP)
+		for _, options := range optionsList {
+			if err != nil {
+				break
+			}
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+				"keyspace": keyspace, "options": options, "operation": operation}).Info("Execute the Jolokia Operation")
+			var command int
+			if command, err = jolokiaClient.NodeRepair(keyspace, options); err == nil {
+				err = waitRepair(jolokiaClient, command, repairOutcomeIsKnown(cc))
+			}
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName, "pod": pod.Name,
+				"keyspace": keyspace, "operation": operation, "err": err}).Error("Repair failed")
+			failedKeyspaces = append(failedKeyspaces, keyspace)
+		}
+	}
+	if len(failedKeyspaces) > 0 {
+		return fmt.Errorf("Repair failed for keyspaces %v", failedKeyspaces)
+	}
+	return nil
+}
+
 func (rcc *CassandraClusterReconciler) runRemove(hostName string, cc *api.CassandraCluster, dcRackName string, pod v1.Pod) error {
 	operation := strings.Title(api.OperationRemove)
 
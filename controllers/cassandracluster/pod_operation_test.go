@@ -15,7 +15,9 @@
 package cassandracluster
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	api "github.com/Orange-OpenSource/casskop/api/v2"
 	"github.com/stretchr/testify/assert"
@@ -77,3 +79,31 @@ func TestPodsSlice(t *testing.T) {
 	assert.Equal(podsSlice, []v1.Pod{*pod})
 	assert.Equal(checkOnly, true)
 }
+
+func TestAddOperationHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := &cc.Status
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-demo-dc1-rack1-0",
+		Labels: map[string]string{"operation-start": "20201114T020003"}}}
+
+	addOperationHistory(status, "dc1-rack1", pod, "Cleanup", nil)
+	addOperationHistory(status, "dc1-rack1", pod, "Upgradesstables", errors.New("no space left"))
+
+	assert.Equal(2, len(status.OperationHistory))
+	assert.Equal("cleanup", status.OperationHistory[0].Name)
+	assert.Equal(api.StatusDone, status.OperationHistory[0].Status)
+	assert.Equal("cassandra-demo-dc1-rack1-0", status.OperationHistory[0].Pod)
+	assert.Equal("2020-11-14T02:00:03Z", status.OperationHistory[0].StartTime.UTC().Format(time.RFC3339))
+	assert.Equal("upgradesstables", status.OperationHistory[1].Name)
+	assert.Equal(api.StatusError, status.OperationHistory[1].Status)
+	assert.Equal("no space left", status.OperationHistory[1].Error)
+
+	//Only the last operations are kept
+	for i := 0; i < api.MaxOperationHistory; i++ {
+		addOperationHistory(status, "dc1-rack1", pod, "Rebuild", nil)
+	}
+	assert.Equal(api.MaxOperationHistory, len(status.OperationHistory))
+	assert.Equal("rebuild", status.OperationHistory[0].Name)
+}
@@ -16,8 +16,13 @@ package cassandracluster
 
 import (
 	"context"
+	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,6 +30,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 )
 
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
 func (rcc *CassandraClusterReconciler) GetPVC(namespace, name string) (*v1.PersistentVolumeClaim, error) {
 
 	o := &v1.PersistentVolumeClaim{
@@ -63,3 +70,121 @@ func (rcc *CassandraClusterReconciler) deletePVC(pvc *v1.PersistentVolumeClaim)
 	return rcc.Client.Delete(context.TODO(), pvc)
 
 }
+
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+
+// GetStorageClass returns the storage class named name, or the default storage class of the cluster if name is empty
+func (rcc *CassandraClusterReconciler) GetStorageClass(name string) (*storagev1.StorageClass, error) {
+	if name != "" {
+		storageClass := &storagev1.StorageClass{}
+		return storageClass, rcc.Client.Get(context.TODO(), types.NamespacedName{Name: name}, storageClass)
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := rcc.Client.List(context.TODO(), storageClasses); err != nil {
+		return nil, err
+	}
+	for i, storageClass := range storageClasses.Items {
+		if storageClass.Annotations[defaultStorageClassAnnotation] == "true" {
+			return &storageClasses.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no default storage class found")
+}
+
+// StorageResizeRefusedReason returns why volumes of the storage class named storageClassName can't be resized
+// from oldCapacity to newCapacity, or an empty string if the resize is possible
+func (rcc *CassandraClusterReconciler) StorageResizeRefusedReason(storageClassName string, oldCapacity,
+	newCapacity string) string {
+	if oldCapacity == "" || newCapacity == "" {
+		return "there is no persistent volume to resize"
+	}
+	oldQuantity, err := resource.ParseQuantity(oldCapacity)
+	if err != nil {
+		return fmt.Sprintf("can't parse %s: %v", oldCapacity, err)
+	}
+	newQuantity, err := resource.ParseQuantity(newCapacity)
+	if err != nil {
+		return fmt.Sprintf("can't parse %s: %v", newCapacity, err)
+	}
+	if newQuantity.Cmp(oldQuantity) < 0 {
+		return "volumes can't be shrunk"
+	}
+
+	storageClass, err := rcc.GetStorageClass(storageClassName)
+	if err != nil {
+		return fmt.Sprintf("can't get storage class %s: %v", storageClassName, err)
+	}
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		return fmt.Sprintf("storage class %s does not allow volume expansion", storageClass.Name)
+	}
+	return ""
+}
+
+// pvcName returns the name of the PVC created by the statefulset for the volume claim template and the pod ordinal
+func pvcName(statefulSet *appsv1.StatefulSet, template v1.PersistentVolumeClaim, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", template.Name, statefulSet.Name, ordinal)
+}
+
+// expandPVCs requests on the PVCs of the first replicas pods of the statefulset the storage of its
+// volumeClaimTemplates. The expansion itself is done by the provisioner of the storage class
+func (rcc *CassandraClusterReconciler) expandPVCs(statefulSet *appsv1.StatefulSet, replicas int32) error {
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		request := template.Spec.Resources.Requests[v1.ResourceStorage]
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			pvc, err := rcc.GetPVC(statefulSet.Namespace, pvcName(statefulSet, template, ordinal))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			current := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+			if current.Cmp(request) >= 0 {
+				continue
+			}
+			if pvc.Spec.Resources.Requests == nil {
+				pvc.Spec.Resources.Requests = v1.ResourceList{}
+			}
+			pvc.Spec.Resources.Requests[v1.ResourceStorage] = request
+			if err = rcc.Client.Update(context.TODO(), pvc); err != nil {
+				return fmt.Errorf("failed to expand PVC %s: %v", pvc.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pvcsExpansionStatus returns whether all PVCs of the statefulset pods have reached the storage requested by its
+// volumeClaimTemplates, and the pods to restart so that the file system of their volumes can be resized.
+// A PVC not created yet by the statefulset is pending
+func (rcc *CassandraClusterReconciler) pvcsExpansionStatus(statefulSet *appsv1.StatefulSet) (bool, []string,
+	error) {
+	expanded := true
+	var podsToRestart []string
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		request := template.Spec.Resources.Requests[v1.ResourceStorage]
+		for ordinal := int32(0); ordinal < *statefulSet.Spec.Replicas; ordinal++ {
+			pvc, err := rcc.GetPVC(statefulSet.Namespace, pvcName(statefulSet, template, ordinal))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					expanded = false
+					continue
+				}
+				return false, nil, err
+			}
+			capacity := pvc.Status.Capacity[v1.ResourceStorage]
+			if capacity.Cmp(request) >= 0 {
+				continue
+			}
+			expanded = false
+			for _, condition := range pvc.Status.Conditions {
+				if condition.Type == v1.PersistentVolumeClaimFileSystemResizePending &&
+					condition.Status == v1.ConditionTrue {
+					podsToRestart = append(podsToRestart, fmt.Sprintf("%s-%d", statefulSet.Name, ordinal))
+				}
+			}
+		}
+	}
+	return expanded, podsToRestart, nil
+}
@@ -16,7 +16,6 @@ package cassandracluster
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -28,8 +27,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/r3labs/diff"
 	"github.com/sirupsen/logrus"
+	"github.com/thoas/go-funk"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -88,15 +89,13 @@ func updateDeletePvcStrategy(cc *api.CassandraCluster) {
 
 // CheckDeletePVC checks if DeletePVC is updated and update DeletePVC strategy
 func (rcc *CassandraClusterReconciler) CheckDeletePVC(cc *api.CassandraCluster) error {
-	var oldCRD api.CassandraCluster
-	if cc.Annotations[api.AnnotationLastApplied] == "" {
+	//We retrieve the last spec applied from the spec history
+	oldCRD, err := rcc.lastAppliedCluster(cc)
+	if err != nil {
+		logrus.Errorf("[%s]: Can't get Old version of CRD: %v", cc.Name, err)
 		return nil
 	}
-
-	//We retrieved our last-applied-configuration stored in the CRD
-	err := json.Unmarshal([]byte(cc.Annotations[api.AnnotationLastApplied]), &oldCRD)
-	if err != nil {
-		logrus.Errorf("[%s]: Can't get Old version of CRD", cc.Name)
+	if oldCRD == nil {
 		return nil
 	}
 
@@ -114,20 +113,18 @@ func (rcc *CassandraClusterReconciler) CheckDeletePVC(cc *api.CassandraCluster)
 // and Patch the CRD with correct values
 func (rcc *CassandraClusterReconciler) CheckNonAllowedChanges(cc *api.CassandraCluster,
 	status *api.CassandraClusterStatus) bool {
-	var oldCRD api.CassandraCluster
-	if cc.Annotations[api.AnnotationLastApplied] == "" {
-		return false
-	}
-
-	if lac, _ := cc.ComputeLastAppliedConfiguration(); string(lac) == cc.Annotations[api.AnnotationLastApplied] {
+	if !rcc.specChanged(cc) {
 		//there are no changes to take care about
 		return false
 	}
 
-	//We retrieved our last-applied-configuration stored in the CRD
-	err := json.Unmarshal([]byte(cc.Annotations[api.AnnotationLastApplied]), &oldCRD)
+	//We retrieve the last spec applied from the spec history
+	oldCRD, err := rcc.lastAppliedCluster(cc)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Error("Can't get Old version of CRD")
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Errorf("Can't get Old version of CRD: %v", err)
+		return false
+	}
+	if oldCRD == nil {
 		return false
 	}
 
@@ -140,16 +137,34 @@ func (rcc *CassandraClusterReconciler) CheckNonAllowedChanges(cc *api.CassandraC
 		needUpdate = true
 	}
 
+	//The snapshot of restoreFrom is only restored when the cluster is created
+	if !reflect.DeepEqual(cc.Spec.RestoreFrom, oldCRD.Spec.RestoreFrom) {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
+			Warning("The Operator has refused the change on RestoreFrom, it can only be set at creation")
+		cc.Spec.RestoreFrom = oldCRD.Spec.RestoreFrom
+		needUpdate = true
+	}
+
+	resizeStorageDCs := map[string]bool{}
 	for dc := 0; dc < cc.GetDCSize(); dc++ {
 		dcName := cc.GetDCName(dc)
-		//DataCapacity change is forbidden
+		//DataCapacity can only be increased if the storage class allows volume expansion
 		if cc.GetDataCapacityForDC(dcName) != oldCRD.GetDataCapacityForDC(dcName) {
-			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "dcName": dcName}).
-				Warningf("The Operator has refused the change on DataCapacity from [%s] to NewValue[%s]",
-					oldCRD.GetDataCapacityForDC(dcName), cc.GetDataCapacityForDC(dcName))
-			cc.Spec.DataCapacity = oldCRD.Spec.DataCapacity
-			cc.Spec.Topology.DC[dc].DataCapacity = oldCRD.Spec.Topology.DC[dc].DataCapacity
-			needUpdate = true
+			reason := rcc.StorageResizeRefusedReason(oldCRD.GetDataStorageClassForDC(dcName),
+				oldCRD.GetDataCapacityForDC(dcName), cc.GetDataCapacityForDC(dcName))
+			if reason == "" {
+				logrus.WithFields(logrus.Fields{"cluster": cc.Name, "dcName": dcName}).
+					Infof("We ask to resize DataCapacity from [%s] to [%s]",
+						oldCRD.GetDataCapacityForDC(dcName), cc.GetDataCapacityForDC(dcName))
+				resizeStorageDCs[dcName] = true
+			} else {
+				logrus.WithFields(logrus.Fields{"cluster": cc.Name, "dcName": dcName}).
+					Warningf("The Operator has refused the change on DataCapacity from [%s] to NewValue[%s]: %s",
+						oldCRD.GetDataCapacityForDC(dcName), cc.GetDataCapacityForDC(dcName), reason)
+				cc.Spec.DataCapacity = oldCRD.Spec.DataCapacity
+				cc.Spec.Topology.DC[dc].DataCapacity = oldCRD.Spec.Topology.DC[dc].DataCapacity
+				needUpdate = true
+			}
 		}
 		//DataStorage
 		if cc.GetDataStorageClassForDC(dcName) != oldCRD.GetDataStorageClassForDC(dcName) {
@@ -162,6 +177,37 @@ func (rcc *CassandraClusterReconciler) CheckNonAllowedChanges(cc *api.CassandraC
 		}
 	}
 
+	//Only the requests of existing StorageConfigs can be increased
+	for name, reason := range rcc.StorageConfigsResizeRefusedReasons(cc, oldCRD) {
+		if reason == "" {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
+				Infof("We ask to resize the PVCs of StorageConfig %s", name)
+			for dc := 0; dc < cc.GetDCSize(); dc++ {
+				resizeStorageDCs[cc.GetDCName(dc)] = true
+			}
+			continue
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
+			Warningf("The Operator has refused the change on StorageConfig %s: %s", name, reason)
+		cc.Spec.StorageConfigs = oldCRD.Spec.StorageConfigs
+		needUpdate = true
+	}
+
+	//A new major version of Cassandra is only rolled out on a healthy cluster
+	if reason := rcc.MajorVersionChangeRefusedReason(cc, oldCRD, status); reason != "" {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
+			Warningf("The Operator has refused the change on the version of Cassandra from [%s] to NewValue[%s]: %s",
+				cassandraServerVersion(oldCRD), cassandraServerVersion(cc), reason)
+		cc.Spec.CassandraImage = oldCRD.Spec.CassandraImage
+		cc.Spec.ServerVersion = oldCRD.Spec.ServerVersion
+		needUpdate = true
+	} else if isMajorUpgrade(oldCRD, cc) {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
+			Infof("We ask to upgrade Cassandra from [%s] to [%s]", cassandraServerVersion(oldCRD),
+				cassandraServerVersion(cc))
+		setUpgradeStatus(cc, status)
+	}
+
 	if needUpdate {
 		status.LastClusterAction = api.ActionCorrectCRDConfig.Name
 		ClusterActionMetric.set(api.ActionCorrectCRDConfig, cc.Name)
@@ -169,12 +215,12 @@ func (rcc *CassandraClusterReconciler) CheckNonAllowedChanges(cc *api.CassandraC
 	}
 
 	var updateStatus string
-	if needUpdate, updateStatus = CheckTopologyChanges(rcc, cc, status, &oldCRD); needUpdate {
+	if needUpdate, updateStatus = CheckTopologyChanges(rcc, cc, status, oldCRD); needUpdate {
 		if updateStatus != "" {
 			status.LastClusterAction = updateStatus
 		}
 		if updateStatus == api.ActionCorrectCRDConfig.Name {
-			cc.Spec.Topology = (&oldCRD).Spec.Topology
+			cc.Spec.Topology = oldCRD.Spec.Topology
 			ClusterActionMetric.set(api.ActionCorrectCRDConfig, cc.Name)
 		}
 
@@ -186,7 +232,7 @@ func (rcc *CassandraClusterReconciler) CheckNonAllowedChanges(cc *api.CassandraC
 		return true
 	}
 
-	if needUpdate = rcc.CheckNonAllowedScaleDown(cc, &oldCRD); needUpdate {
+	if needUpdate = rcc.CheckNonAllowedScaleDown(cc, status, oldCRD); needUpdate {
 		status.LastClusterAction = api.ActionCorrectCRDConfig.Name
 		ClusterActionMetric.set(api.ActionCorrectCRDConfig, cc.Name)
 		return true
@@ -217,9 +263,59 @@ func (rcc *CassandraClusterReconciler) CheckNonAllowedChanges(cc *api.CassandraC
 		}
 	}
 
+	//PVCs are resized one rack at a time, we flag each dcrackname of the dcs whose storage has changed
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		if !resizeStorageDCs[dcName] {
+			continue
+		}
+		for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+			dcRackName := cc.GetDCRackName(dcName, cc.GetRackName(dc, rack))
+			dcRackStatus, exists := status.CassandraRackStatus[dcRackName]
+			if !exists {
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name,
+				"dc-rack": dcRackName}).Info("Update Rack Status ResizeStorage=ToDo")
+			dcRackStatus.CassandraLastAction.Name = api.ActionResizeStorage.Name
+			ClusterActionMetric.set(api.ActionResizeStorage, cc.Name)
+			dcRackStatus.CassandraLastAction.Status = api.StatusToDo
+			now := metav1.Now()
+			dcRackStatus.CassandraLastAction.StartTime = &now
+			dcRackStatus.CassandraLastAction.EndTime = nil
+		}
+	}
+
 	return false
 }
 
+// StorageConfigsResizeRefusedReasons returns, for each StorageConfig whose storage request has changed, why its
+// PVCs can't be resized. An empty reason means the PVCs can be expanded
+func (rcc *CassandraClusterReconciler) StorageConfigsResizeRefusedReasons(cc *api.CassandraCluster,
+	oldCRD *api.CassandraCluster) map[string]string {
+	reasons := map[string]string{}
+	for _, storage := range cc.Spec.StorageConfigs {
+		for _, oldStorage := range oldCRD.Spec.StorageConfigs {
+			if storage.Name != oldStorage.Name || storage.PVCSpec == nil || oldStorage.PVCSpec == nil {
+				continue
+			}
+			request := storage.PVCSpec.Resources.Requests[v1.ResourceStorage]
+			oldRequest := oldStorage.PVCSpec.Resources.Requests[v1.ResourceStorage]
+			if request.Cmp(oldRequest) == 0 {
+				continue
+			}
+			var storageClassName string
+			if oldStorage.PVCSpec.StorageClassName != nil {
+				storageClassName = *oldStorage.PVCSpec.StorageClassName
+			}
+			reasons[storage.Name] = rcc.StorageResizeRefusedReason(storageClassName, oldRequest.String(),
+				request.String())
+		}
+	}
+	return reasons
+}
+
 func generatePaths(s string) []string {
 	return strings.Split(s, ".")
 }
@@ -298,51 +394,54 @@ func hasChange(changelog diff.Changelog, changeType string, paths ...string) boo
 func CheckTopologyChanges(rcc *CassandraClusterReconciler, cc *api.CassandraCluster,
 	status *api.CassandraClusterStatus, oldCRD *api.CassandraCluster) (bool, string) {
 
+	if reason := TopologyChangeRefusedReason(cc, oldCRD); reason != "" {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
+			topologyChangeRefused+"%s: %v restored to %v", reason, cc.Spec.Topology, oldCRD.Spec.Topology)
+		return true, api.ActionCorrectCRDConfig.Name
+	}
+
+	if cc.GetDCRackSize() < oldCRD.GetDCRackSize() {
+		dcName := cc.GetRemovedDCName(oldCRD)
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf("Removing DC %s", dcName)
+
+		//We apply this change to the Cluster status
+		return rcc.deleteDCObjects(cc, status)
+	}
+
+	return false, ""
+}
+
+// TopologyChangeRefusedReason returns why the topology change from oldCRD to cc is not allowed, or an empty
+// string if the operator can apply it
+func TopologyChangeRefusedReason(cc *api.CassandraCluster, oldCRD *api.CassandraCluster) string {
 	changelog, _ := diff.Diff(oldCRD.Spec.Topology, cc.Spec.Topology)
 
 	if hasChange(changelog, diff.UPDATE) ||
 		hasChange(changelog, diff.DELETE, "DC.Rack", "-DC") ||
 		hasChange(changelog, diff.CREATE, "DC.Rack", "-DC") {
-		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
-			topologyChangeRefused+"No change other than adding/removing a DC can happen: %v restored to %v",
-			cc.Spec.Topology, oldCRD.Spec.Topology)
-		return true, api.ActionCorrectCRDConfig.Name
+		return "No change other than adding/removing a DC can happen"
 	}
 
 	if cc.GetDCSize() < oldCRD.GetDCSize()-1 {
-		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
-			topologyChangeRefused+"You can only remove 1 DC at a time, "+
-				"not only a Rack: %v restored to %v", cc.Spec.Topology, oldCRD.Spec.Topology)
-		return true, api.ActionCorrectCRDConfig.Name
+		return "You can only remove 1 DC at a time, not only a Rack"
 	}
 
 	if cc.GetDCRackSize() < oldCRD.GetDCRackSize() {
 
 		if cc.Status.LastClusterAction == api.ActionScaleDown.Name &&
 			cc.Status.LastClusterActionStatus != api.StatusDone {
-			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
-				Warningf(topologyChangeRefused +
-					"You must wait to the end of ScaleDown to 0 before deleting a DC")
-			return true, api.ActionCorrectCRDConfig.Name
+			return "You must wait to the end of ScaleDown to 0 before deleting a DC"
 		}
 
 		dcName := cc.GetRemovedDCName(oldCRD)
 
 		//We need to check how many nodes were in the old CRD (before the user delete it)
 		if found, nbNodes := oldCRD.GetDCNodesPerRacksFromName(dcName); found && nbNodes > 0 {
-			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).
-				Warningf(topologyChangeRefused+
-					"You must scale down the DC %s to 0 before deleting it", dcName)
-			return true, api.ActionCorrectCRDConfig.Name
+			return fmt.Sprintf("You must scale down the DC %s to 0 before deleting it", dcName)
 		}
-
-		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf("Removing DC %s", dcName)
-
-		//We apply this change to the Cluster status
-		return rcc.deleteDCObjects(cc, status)
 	}
 
-	return false, ""
+	return ""
 }
 
 func (rcc *CassandraClusterReconciler) deleteDCObjects(cc *api.CassandraCluster,
@@ -382,59 +481,76 @@ func (rcc *CassandraClusterReconciler) deleteDCObjects(cc *api.CassandraCluster,
 //CheckNonAllowedScaleDown goal is to discard the scaleDown to 0 is there is still replicated data towards the
 // corresponding DC
 func (rcc *CassandraClusterReconciler) CheckNonAllowedScaleDown(cc *api.CassandraCluster,
-	oldCRD *api.CassandraCluster) bool {
+	status *api.CassandraClusterStatus, oldCRD *api.CassandraCluster) bool {
 
 	if ok, dcName, dc := cc.FindDCWithNodesTo0(); ok {
 		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Infof("Ask ScaleDown to 0 for dc %s", dcName)
 
-		//We take the first Rack
-		rackName := cc.GetRackName(dc, 0)
-
-		selector := k8s.MergeLabels(k8s.LabelsForCassandraDCRack(cc, dcName, rackName))
-		podsList, err := rcc.ListPods(cc.Namespace, selector)
-		if err != nil || len(podsList.Items) < 1 {
-			if err != nil {
-				logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
-					"The Operator has refused the ScaleDown (no pod found). "+
-						"topology %v restored to %v", cc.Spec.Topology, oldCRD.Spec.Topology)
-				cc.Spec.Topology = oldCRD.Spec.Topology
-				return true
-			}
-			//else there is already no pods so it's ok
-			return false
+		if reason := rcc.ScaleDownRefusedReason(cc, dcName, dc); reason != "" {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
+				"The Operator has refused the ScaleDown (%s). topology %v restored to %v", reason,
+				cc.Spec.Topology, oldCRD.Spec.Topology)
+			cc.Spec.Topology = oldCRD.Spec.Topology
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: api.ConditionScaleDownRefused,
+				Status: metav1.ConditionTrue, Reason: "DataReplicatedToDC", ObservedGeneration: cc.Generation,
+				Message: fmt.Sprintf("Scale down of dc %s to 0 refused: %s", dcName, reason)})
+			return true
 		}
+	}
+	//The condition is kept until the spec changes without a refused scale down
+	if meta.FindStatusCondition(status.Conditions, api.ConditionScaleDownRefused) != nil {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: api.ConditionScaleDownRefused,
+			Status: metav1.ConditionFalse, Reason: "ScaleDownAllowed", ObservedGeneration: cc.Generation})
+	}
+	return false
+}
 
-		//We take the first available Pod
-		for _, pod := range podsList.Items {
-			if pod.Status.Phase != v1.PodRunning || pod.DeletionTimestamp != nil {
-				continue
-			}
-			hostName := k8s.PodHostname(pod)
-			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Debugf("The Operator will ask node %s", hostName)
-			jolokiaClient, err := NewJolokiaClient(hostName, JolokiaPort, rcc,
-				cc.Spec.ImageJolokiaSecret, cc.Namespace)
-			var keyspacesWithData []string
-			if err == nil {
-				keyspacesWithData, err = jolokiaClient.NonLocalKeyspacesInDC(dcName)
-			}
-			if err != nil {
-				logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
-					"The Operator has refused the ScaleDown (NonLocalKeyspacesInDC failed %s). ", err)
-				cc.Spec.Topology = oldCRD.Spec.Topology
-				return true
-			}
-			if len(keyspacesWithData) != 0 {
-				logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
-					"The Operator has refused the ScaleDown. Keyspaces still having data %v", keyspacesWithData)
-				cc.Spec.Topology = oldCRD.Spec.Topology
-				return true
+// ScaleDownRefusedReason asks a running node of the DC if keyspaces still replicate data to it. It returns why
+// the scale down to 0 of this DC is not allowed, or an empty string if it can happen
+func (rcc *CassandraClusterReconciler) ScaleDownRefusedReason(cc *api.CassandraCluster, dcName string,
+	dc int) string {
+	//We take the first Rack
+	rackName := cc.GetRackName(dc, 0)
+
+	selector := k8s.MergeLabels(k8s.LabelsForCassandraDCRack(cc, dcName, rackName))
+	podsList, err := rcc.ListPods(cc.Namespace, selector)
+	if err != nil {
+		return "no pod found"
+	}
+
+	//We take the first available Pod
+	for _, pod := range podsList.Items {
+		if pod.Status.Phase != v1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		hostName := k8s.PodHostname(pod)
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Debugf("The Operator will ask node %s", hostName)
+		jolokiaClient, err := NewJolokiaClient(hostName, JolokiaPort, rcc,
+			cc.Spec.ImageJolokiaSecret, cc.Namespace)
+		var keyspacesWithData []string
+		if err == nil {
+			keyspacesWithData, err = jolokiaClient.NonLocalKeyspacesInDC(dcName)
+		}
+		if err != nil {
+			return fmt.Sprintf("NonLocalKeyspacesInDC failed %s", err)
+		}
+		//The replication of the keyspaces managed by the operator is altered before the decommission
+		managedKeyspaces := managedKeyspacesWithoutReplicaInDC(cc, dcName)
+		var unmanagedKeyspaces []string
+		for _, keyspace := range keyspacesWithData {
+			if !funk.ContainsString(managedKeyspaces, keyspace) {
+				unmanagedKeyspaces = append(unmanagedKeyspaces, keyspace)
 			}
-			logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
-				"Cassandra has no more replicated data on dc %s, we can scale Down to 0", dcName)
-			return false
 		}
+		if len(unmanagedKeyspaces) != 0 {
+			return fmt.Sprintf("Keyspaces still having data %v", unmanagedKeyspaces)
+		}
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name}).Warningf(
+			"Cassandra has no more replicated data on dc %s, we can scale Down to 0", dcName)
+		return ""
 	}
-	return false
+	//there is already no pods so it's ok
+	return ""
 }
 
 //ReconcileRack will try to reconcile cassandra for each of the couple DC/Rack defined in the topology
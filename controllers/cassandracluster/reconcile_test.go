@@ -15,6 +15,7 @@
 package cassandracluster
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Orange-OpenSource/casskop/controllers/common"
@@ -29,7 +30,11 @@ import (
 	"github.com/r3labs/diff"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestFlipCassandraClusterUpdateSeedListStatusScaleDC2(t *testing.T) {
@@ -331,6 +336,91 @@ func TestFlipCassandraClusterUpdateSeedListStatusScaleDown(t *testing.T) {
 }
 
 //mock example https://github.com/operator-framework/operator-sdk/blob/e74dd322b291b111f78702cf71e5ac843a0c8912/doc/user/unit-testing.md
+func TestUpdateCassandraStatusConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Generation = 3
+	status := cc.Status.DeepCopy()
+
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.Equal(int64(3), status.ObservedGeneration)
+	assert.True(meta.IsStatusConditionFalse(status.Conditions, api.ConditionReady))
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionProgressing))
+
+	for _, dcRackStatus := range status.CassandraRackStatus {
+		dcRackStatus.Phase = api.ClusterPhaseRunning.Name
+		dcRackStatus.CassandraLastAction.Status = api.StatusDone
+	}
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionReady))
+	for _, conditionType := range []string{api.ConditionProgressing, api.ConditionDegraded, api.ConditionScalingUp,
+		api.ConditionScalingDown, api.ConditionRollingUpdate, api.ConditionOperationFailed} {
+		assert.True(meta.IsStatusConditionFalse(status.Conditions, conditionType), conditionType)
+	}
+
+	status.CassandraRackStatus["dc1-rack2"].CassandraLastAction.Name = api.ActionScaleUp.Name
+	status.CassandraRackStatus["dc1-rack2"].CassandraLastAction.Status = api.StatusOngoing
+	status.CassandraRackStatus["dc2-rack1"].Phase = api.ClusterPhasePending.Name
+	status.CassandraRackStatus["dc1-rack1"].PodLastOperation.PodsKO = []string{"cassandra-demo-dc1-rack1-0"}
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.True(meta.IsStatusConditionFalse(status.Conditions, api.ConditionReady))
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionScalingUp))
+	assert.Equal("Racks dc1-rack2 are scaling up",
+		meta.FindStatusCondition(status.Conditions, api.ConditionScalingUp).Message)
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionDegraded))
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionOperationFailed))
+	assert.True(meta.IsStatusConditionFalse(status.Conditions, api.ConditionRollingUpdate))
+
+	//The status is a subresource, its update does not change the generation it was computed for
+	assert.Equal(int64(3), cc.Status.ObservedGeneration)
+	for _, condition := range cc.Status.Conditions {
+		assert.Equal(int64(3), condition.ObservedGeneration, condition.Type)
+	}
+}
+
+func TestUpdateCassandraStatusRestored(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.Nil(meta.FindStatusCondition(status.Conditions, api.ConditionRestored))
+
+	cc.Spec.RestoreFrom = &api.RestoreFrom{StorageLocation: "s3://cassandra-backups", SnapshotTag: "weekly"}
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.True(meta.IsStatusConditionFalse(status.Conditions, api.ConditionRestored))
+	restored := &v1.ConfigMap{}
+	err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: restoredName(cc), Namespace: cc.Namespace},
+		restored)
+	assert.True(apierrors.IsNotFound(err))
+
+	for _, dcRackStatus := range status.CassandraRackStatus {
+		dcRackStatus.Phase = api.ClusterPhaseRunning.Name
+		dcRackStatus.CassandraLastAction.Status = api.StatusDone
+	}
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionRestored))
+	//The nodes added from now on don't restore the snapshot
+	assert.Nil(rcc.Client.Get(context.TODO(), types.NamespacedName{Name: restoredName(cc), Namespace: cc.Namespace},
+		restored))
+	assert.Equal("weekly", restored.Data[restoredKey])
+
+	//The cluster stays restored when it is not ready anymore
+	status.CassandraRackStatus["dc2-rack1"].Phase = api.ClusterPhasePending.Name
+	UpdateCassandraClusterStatusPhase(cc, status)
+	rcc.updateCassandraStatus(cc, status)
+	assert.True(meta.IsStatusConditionFalse(status.Conditions, api.ConditionReady))
+	assert.True(meta.IsStatusConditionTrue(status.Conditions, api.ConditionRestored))
+}
+
 func TestCheckNonAllowedChangesNodesTo0(t *testing.T) {
 	assert := assert.New(t)
 
@@ -349,6 +439,21 @@ func TestCheckNonAllowedChangesNodesTo0(t *testing.T) {
 	assert.Equal(int32(1), cc.Spec.NodesPerRacks)
 }
 
+func TestCheckNonAllowedChangesRestoreFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+
+	status := cc.Status.DeepCopy()
+	rcc.updateCassandraStatus(cc, status)
+
+	//RestoreFrom can't be added to an existing cluster
+	cc.Spec.RestoreFrom = &api.RestoreFrom{StorageLocation: "s3://cassandra-backups", SnapshotTag: "weekly"}
+	res := rcc.CheckNonAllowedChanges(cc, status)
+	assert.Equal(true, res)
+	assert.Nil(cc.Spec.RestoreFrom)
+}
+
 func TestCheckNonAllowedChangesMix1(t *testing.T) {
 	assert := assert.New(t)
 	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
@@ -406,6 +511,69 @@ func TestCheckNonAllowedChangesResourcesIsAllowedButNeedAttention(t *testing.T)
 	assert.Equal(api.StatusToDo, status.CassandraRackStatus[dcRackName].CassandraLastAction.Status)
 }
 
+func TestCheckNonAllowedChangesDataCapacityIsAllowedIfVolumesAreExpandable(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	rcc.updateCassandraStatus(cc, status)
+
+	allowVolumeExpansion := true
+	rcc.Client.Create(context.TODO(), &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "local-storage"},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	})
+
+	//Volumes can't be shrunk
+	cc.Spec.DataCapacity = "2Gi" //instead of "3Gi"
+	res := rcc.CheckNonAllowedChanges(cc, status)
+	assert.Equal(true, res)
+	assert.Equal("3Gi", cc.Spec.DataCapacity)
+	rcc.updateCassandraStatus(cc, status)
+
+	//dc1 has its own dataCapacity, only racks of dc2 need to be resized
+	cc.Spec.DataCapacity = "4Gi" //instead of "3Gi"
+	res = rcc.CheckNonAllowedChanges(cc, status)
+	assert.Equal(false, res)
+	assert.Equal("4Gi", cc.Spec.DataCapacity)
+
+	dcRackName := "dc1-rack1"
+	assert.Equal(api.ClusterPhaseInitial.Name, status.CassandraRackStatus[dcRackName].CassandraLastAction.Name)
+	dcRackName = "dc2-rack1"
+	assert.Equal(api.ActionResizeStorage.Name, status.CassandraRackStatus[dcRackName].CassandraLastAction.Name)
+	assert.Equal(api.StatusToDo, status.CassandraRackStatus[dcRackName].CassandraLastAction.Status)
+
+	//dc1 uses a storage class which does not allow volume expansion
+	cc.Spec.DataCapacity = "3Gi"
+	cc.Spec.Topology.DC[0].DataCapacity = "20Gi" //instead of "10Gi"
+	res = rcc.CheckNonAllowedChanges(cc, status)
+	assert.Equal(true, res)
+	assert.Equal("10Gi", cc.Spec.Topology.DC[0].DataCapacity)
+}
+
+func TestCheckNonAllowedChangesStorageConfigsIsAllowedIfVolumesAreExpandable(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	rcc.updateCassandraStatus(cc, status)
+
+	allowVolumeExpansion := true
+	rcc.Client.Create(context.TODO(), &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "standard-wait"},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	})
+
+	cc.Spec.StorageConfigs[0].PVCSpec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("20Gi")
+	res := rcc.CheckNonAllowedChanges(cc, status)
+	assert.Equal(false, res)
+
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		assert.Equal(api.ActionResizeStorage.Name, status.CassandraRackStatus[dcRackName].CassandraLastAction.Name)
+		assert.Equal(api.StatusToDo, status.CassandraRackStatus[dcRackName].CassandraLastAction.Status)
+	}
+}
+
 func TestCheckNonAllowedChangesRemove2DC(t *testing.T) {
 	assert := assert.New(t)
 
@@ -607,6 +775,11 @@ func TestCheckNonAllowedChangesScaleDown(t *testing.T) {
 
 	//We have restore nodesperrack
 	assert.Equal(int32(1), *cc.Spec.Topology.DC[1].NodesPerRacks)
+	//The refusal is reported in a condition
+	refused := meta.FindStatusCondition(status.Conditions, api.ConditionScaleDownRefused)
+	assert.NotNil(refused)
+	assert.Equal(metav1.ConditionTrue, refused.Status)
+	assert.Contains(refused.Message, "demo1")
 
 	//Changes replicated keyspaces (remove demo1 and demo2 which still have replicated datas
 	//allKeyspaces is a global test variable
@@ -620,6 +793,7 @@ func TestCheckNonAllowedChangesScaleDown(t *testing.T) {
 
 	//Nodes Per Rack is still 0
 	assert.Equal(int32(0), *cc.Spec.Topology.DC[1].NodesPerRacks)
+	assert.True(meta.IsStatusConditionFalse(status.Conditions, api.ConditionScaleDownRefused))
 }
 
 func TestInitClusterWithDeletePVC(t *testing.T) {
@@ -0,0 +1,295 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	remediationReasonImagePullBackOff  = "ImagePullBackOff"
+	remediationReasonPendingOnDeadNode = "PendingOnDeadNode"
+	remediationReasonNodeDown          = "NodeDown"
+
+	// remediationBackoff is the time during which no action is taken again on a pod for the same reason
+	remediationBackoff = 10 * time.Minute
+
+	hostnameLabel = "kubernetes.io/hostname"
+)
+
+var defaultRemediation = api.Remediation{ImagePullBackOff: api.RemediationDeletePod,
+	PendingOnDeadNode: api.RemediationAlert, NodeDown: api.RemediationAlert, NodeDownSeconds: api.DefaultNodeDownSeconds}
+
+// +kubebuilder:rbac:groups="",resources=nodes;persistentvolumes,verbs=get;list;watch
+
+// ReconcileRemediations detects unhealthy pods and Cassandra nodes and applies to them the actions of
+// spec.remediation
+func (rcc *CassandraClusterReconciler) ReconcileRemediations(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	remediation := cc.Spec.Remediation
+	if remediation == nil {
+		remediation = &defaultRemediation
+	}
+
+	podsList, err := rcc.ListCassandraClusterPods(cc)
+	if err != nil {
+		return err
+	}
+	for i := range podsList {
+		pod := &podsList[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if message := rcc.imagePullBackOffFixed(cc, pod); message != "" {
+			rcc.remediate(cc, status, pod, remediationReasonImagePullBackOff, remediation.ImagePullBackOff, message)
+			continue
+		}
+		if message := rcc.pendingOnDeadNode(pod); message != "" {
+			rcc.remediate(cc, status, pod, remediationReasonPendingOnDeadNode, remediation.PendingOnDeadNode,
+				message)
+		}
+	}
+	return rcc.checkNodesDown(cc, status, podsList, remediation)
+}
+
+// containerImage returns the image of a container of a pod spec, empty if the pod spec has no such container
+func containerImage(podSpec v1.PodSpec, name string) string {
+	for _, containers := range [][]v1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, container := range containers {
+			if container.Name == name {
+				return container.Image
+			}
+		}
+	}
+	return ""
+}
+
+// imagePullBackOffFixed returns why a pod stuck in ImagePullBackOff would start once recreated: the image it can't
+// pull has been changed in its statefulset since it was created. It returns an empty string otherwise
+func (rcc *CassandraClusterReconciler) imagePullBackOffFixed(cc *api.CassandraCluster, pod *v1.Pod) string {
+	var containerStatuses []v1.ContainerStatus
+	containerStatuses = append(containerStatuses, pod.Status.InitContainerStatuses...)
+	containerStatuses = append(containerStatuses, pod.Status.ContainerStatuses...)
+	for _, containerStatus := range containerStatuses {
+		if containerStatus.State.Waiting == nil || (containerStatus.State.Waiting.Reason != "ImagePullBackOff" &&
+			containerStatus.State.Waiting.Reason != "ErrImagePull") {
+			continue
+		}
+		storedStatefulSet, err := rcc.GetStatefulSet(cc.Namespace, cc.Name+"-"+pod.Labels["dc-rack"])
+		if err != nil {
+			return ""
+		}
+		podImage := containerImage(pod.Spec, containerStatus.Name)
+		image := containerImage(storedStatefulSet.Spec.Template.Spec, containerStatus.Name)
+		if image != "" && image != podImage {
+			return fmt.Sprintf("container %s can't pull image %s, its statefulset now uses %s",
+				containerStatus.Name, podImage, image)
+		}
+	}
+	return ""
+}
+
+// nodeIsReady returns true if a kubernetes node exists and is ready
+func (rcc *CassandraClusterReconciler) nodeIsReady(nodeName string) bool {
+	node := &v1.Node{}
+	if err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		return !apierrors.IsNotFound(err)
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// localVolumeNode returns the kubernetes node a persistent volume is bound to by its node affinity, as the local
+// volumes are, empty if it has none
+func localVolumeNode(pv *v1.PersistentVolume) string {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return ""
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == hostnameLabel && expression.Operator == v1.NodeSelectorOpIn &&
+				len(expression.Values) == 1 {
+				return expression.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// pendingOnDeadNode returns why a pod can't be scheduled when one of its persistent volumes is bound to a kubernetes
+// node which is gone or not ready. It returns an empty string otherwise
+func (rcc *CassandraClusterReconciler) pendingOnDeadNode(pod *v1.Pod) string {
+	if pod.Status.Phase != v1.PodPending || pod.Spec.NodeName != "" {
+		return ""
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc, err := rcc.GetPVC(pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		if err != nil || pvc.Spec.VolumeName == "" {
+			continue
+		}
+		pv := &v1.PersistentVolume{}
+		if err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+			continue
+		}
+		if nodeName := localVolumeNode(pv); nodeName != "" && !rcc.nodeIsReady(nodeName) {
+			return fmt.Sprintf("volume %s of pvc %s is on node %s which is gone or not ready", pv.Name, pvc.Name,
+				nodeName)
+		}
+	}
+	return ""
+}
+
+// podOfAddress returns the name of the pod whose Cassandra node has an address, empty if it is unknown
+func podOfAddress(status *api.CassandraClusterStatus, address string) string {
+	for podName, nodeStatus := range status.CassandraNodesStatus {
+		if nodeStatus.NodeIp == address {
+			return podName
+		}
+	}
+	return ""
+}
+
+// isReplaced returns true if the Cassandra node of a pod is being replaced
+func isReplaced(status *api.CassandraClusterStatus, podName string) bool {
+	for _, dcRackStatus := range status.CassandraRackStatus {
+		if dcRackStatus.NodeReplacement != nil && dcRackStatus.NodeReplacement.Pod == podName {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNodesDown records since when the Cassandra nodes are seen down by a ready node, and remediates the ones down
+// for more than remediation.NodeDownSeconds
+func (rcc *CassandraClusterReconciler) checkNodesDown(cc *api.CassandraCluster, status *api.CassandraClusterStatus,
+	podsList []v1.Pod, remediation *api.Remediation) error {
+	firstPod, err := GetLastOrFirstPodReady(podsList, false)
+	if err != nil {
+		//No node can tell which ones are down
+		return nil
+	}
+	jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(*firstPod), JolokiaPort, rcc,
+		cc.Spec.ImageJolokiaSecret, cc.Namespace)
+	if err != nil {
+		return err
+	}
+	unreachableNodes, err := jolokiaClient.unreachableNodes()
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	nodesDownSince := map[string]metav1.Time{}
+	for _, address := range unreachableNodes {
+		podName := podOfAddress(status, address)
+		if podName == "" || isReplaced(status, podName) {
+			continue
+		}
+		since, found := status.NodesDownSince[podName]
+		if !found {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "pod": podName,
+				"address": address}).Warn("Cassandra node is down")
+			since = now
+		}
+		nodesDownSince[podName] = since
+		if now.Sub(since.Time) < time.Duration(remediation.NodeDownSeconds)*time.Second {
+			continue
+		}
+
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: cc.Namespace}}
+		if storedPod := k8s.PodByName(&v1.PodList{Items: podsList}, podName); storedPod != nil {
+			pod = storedPod
+		}
+		rcc.remediate(cc, status, pod, remediationReasonNodeDown, remediation.NodeDown,
+			fmt.Sprintf("Cassandra node %s is down since %s", address, since.Format(time.RFC3339)))
+	}
+
+	status.NodesDownSince = nil
+	if len(nodesDownSince) > 0 {
+		status.NodesDownSince = nodesDownSince
+	}
+	return nil
+}
+
+// deletePodOnNode deletes a pod, without waiting for it to stop if its kubernetes node is gone or not ready
+func (rcc *CassandraClusterReconciler) deletePodOnNode(pod *v1.Pod) error {
+	if pod.Spec.NodeName != "" && !rcc.nodeIsReady(pod.Spec.NodeName) {
+		return rcc.ForceDeletePod(pod)
+	}
+	return rcc.DeletePod(pod)
+}
+
+// lastRemediation returns the last action taken on a pod for a reason, nil if there is none
+func lastRemediation(status *api.CassandraClusterStatus, podName, reason string) *api.RemediationRecord {
+	for i := len(status.Remediations) - 1; i >= 0; i-- {
+		if status.Remediations[i].Pod == podName && status.Remediations[i].Reason == reason {
+			return &status.Remediations[i]
+		}
+	}
+	return nil
+}
+
+// remediate applies an action to an unhealthy pod, at most once every remediationBackoff for the same reason. It
+// records the action as an event of the cluster and in status.Remediations, keeping only the last
+// api.MaxRemediations ones
+func (rcc *CassandraClusterReconciler) remediate(cc *api.CassandraCluster, status *api.CassandraClusterStatus,
+	pod *v1.Pod, reason string, action api.RemediationAction, message string) {
+	if last := lastRemediation(status, pod.Name, reason); last != nil &&
+		time.Since(last.Time.Time) < remediationBackoff {
+		return
+	}
+
+	var err error
+	switch action {
+	case api.RemediationDeletePod:
+		if err = rcc.deletePodOnNode(pod); apierrors.IsNotFound(err) {
+			err = nil
+		}
+	case api.RemediationReplaceNode:
+		err = rcc.replaceNode(cc, status, pod, "")
+	}
+
+	record := api.RemediationRecord{Pod: pod.Name, Reason: reason, Action: action, Time: metav1.Now(),
+		Message: message}
+	if err != nil {
+		record.Message = fmt.Sprintf("%s, %s failed: %v", message, action, err)
+	}
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "pod": pod.Name, "reason": reason,
+		"action": action}).Warn(record.Message)
+	rcc.Recorder.Event(cc, v1.EventTypeWarning, reason, fmt.Sprintf("%s on pod %s: %s", action, pod.Name,
+		record.Message))
+
+	status.Remediations = append(status.Remediations, record)
+	if len(status.Remediations) > api.MaxRemediations {
+		status.Remediations = status.Remediations[len(status.Remediations)-api.MaxRemediations:]
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// helperCreatePendingPod creates a pod of the rack dc1-rack1 of cluster cassandra-demo which can't be scheduled as
+// its data volume is on node kube-node-1, which is gone
+func helperCreatePendingPod(rcc *CassandraClusterReconciler, cc *api.CassandraCluster) *v1.Pod {
+	pod := &v1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cassandra-demo-dc1-rack1-0",
+			Namespace: cc.Namespace,
+			Labels:    k8s.LabelsForCassandraDCRack(cc, "dc1", "rack1"),
+		},
+		Spec: v1.PodSpec{Volumes: []v1.Volume{{Name: "data", VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: "data-cassandra-demo-dc1-rack1-0"}}}}},
+		Status: v1.PodStatus{Phase: v1.PodPending},
+	}
+	rcc.CreatePod(pod)
+
+	rcc.Client.Create(context.TODO(), &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "local-pv-1"},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("3Gi")},
+			NodeAffinity: &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{{
+					Key: hostnameLabel, Operator: v1.NodeSelectorOpIn, Values: []string{"kube-node-1"}}}}}}},
+		},
+	})
+	rcc.Client.Create(context.TODO(), &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-cassandra-demo-dc1-rack1-0", Namespace: cc.Namespace,
+			Labels: k8s.LabelsForCassandraDCRack(cc, "dc1", "rack1")},
+		Spec: v1.PersistentVolumeClaimSpec{VolumeName: "local-pv-1"},
+	})
+	return pod
+}
+
+func TestRemediateImagePullBackOff(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	sts, _ := generateCassandraStatefulSet(cc, status, "dc1", "dc1-rack1", map[string]string{}, nil, nil)
+	rcc.Client.Create(context.TODO(), sts)
+
+	pod := &v1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-demo-dc1-rack1-0", Namespace: cc.Namespace,
+			Labels: k8s.LabelsForCassandraDCRack(cc, "dc1", "rack1")},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: cassandraContainerName, Image: "cassandra:3.11.77"}}},
+		Status: v1.PodStatus{Phase: v1.PodPending, ContainerStatuses: []v1.ContainerStatus{{
+			Name:  cassandraContainerName,
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}}}},
+	}
+	rcc.CreatePod(pod)
+
+	//The pod is deleted so that it is recreated with the image of the statefulset
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	_, err := rcc.GetPod(pod.Namespace, pod.Name)
+	assert.NotNil(err)
+	assert.Equal(1, len(status.Remediations))
+	assert.Equal(pod.Name, status.Remediations[0].Pod)
+	assert.Equal(remediationReasonImagePullBackOff, status.Remediations[0].Reason)
+	assert.Equal(api.RemediationDeletePod, status.Remediations[0].Action)
+	assert.Equal(1, len(rcc.Recorder.(*record.FakeRecorder).Events))
+
+	//It is not deleted again right away
+	pod.ResourceVersion = ""
+	rcc.CreatePod(pod)
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	_, err = rcc.GetPod(pod.Namespace, pod.Name)
+	assert.Nil(err)
+	assert.Equal(1, len(status.Remediations))
+
+	//Nothing is done while the pod still uses the image of the statefulset
+	status.Remediations = nil
+	pod, _ = rcc.GetPod(pod.Namespace, pod.Name)
+	pod.Spec.Containers[0].Image = cc.Spec.CassandraImage
+	rcc.UpdatePod(pod)
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	assert.Empty(status.Remediations)
+}
+
+func TestRemediatePendingOnDeadNode(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	pod := helperCreatePendingPod(rcc, cc)
+
+	//The issue is only reported by default
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	assert.Equal(1, len(status.Remediations))
+	assert.Equal(remediationReasonPendingOnDeadNode, status.Remediations[0].Reason)
+	assert.Equal(api.RemediationAlert, status.Remediations[0].Action)
+	_, err := rcc.GetPod(pod.Namespace, pod.Name)
+	assert.Nil(err)
+
+	//The pod is deleted
+	cc.Spec.Remediation = &api.Remediation{PendingOnDeadNode: api.RemediationDeletePod}
+	cc.CheckDefaults()
+	status.Remediations = nil
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	assert.Equal(api.RemediationDeletePod, status.Remediations[0].Action)
+	_, err = rcc.GetPod(pod.Namespace, pod.Name)
+	assert.NotNil(err)
+
+	//The node is replaced on a new volume
+	pod.ResourceVersion = ""
+	rcc.CreatePod(pod)
+	cc.Spec.Remediation.PendingOnDeadNode = api.RemediationReplaceNode
+	status.Remediations = nil
+	status.CassandraNodesStatus = map[string]api.CassandraNodeStatus{
+		pod.Name: {HostId: "ae6ed2b8-3c3b-4a41-9e2a-1c0e1d06e3a5", NodeIp: "10.244.3.8"}}
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	assert.Equal(api.RemediationReplaceNode, status.Remediations[0].Action)
+	_, err = rcc.GetPod(pod.Namespace, pod.Name)
+	assert.NotNil(err)
+	_, err = rcc.GetPVC(pod.Namespace, "data-"+pod.Name)
+	assert.NotNil(err)
+	replacement := status.CassandraRackStatus["dc1-rack1"].NodeReplacement
+	assert.Equal(pod.Name, replacement.Pod)
+	assert.Equal("10.244.3.8", replacement.Address)
+	assert.Equal("ae6ed2b8-3c3b-4a41-9e2a-1c0e1d06e3a5", replacement.HostID)
+
+	replaceNodes := &v1.ConfigMap{}
+	assert.Nil(rcc.Client.Get(context.TODO(), types.NamespacedName{Name: replaceNodesName(cc),
+		Namespace: cc.Namespace}, replaceNodes))
+	assert.Equal("10.244.3.8", replaceNodes.Data["cassandra-demo-dc1-rack1-0"])
+}
+
+func TestRemediateNodeDown(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := cc.Status.DeepCopy()
+	pod := helperCreateUpgradePod(rcc, 0)
+	status.CassandraNodesStatus = map[string]api.CassandraNodeStatus{
+		"cassandra-demo-dc1-rack1-1": {HostId: "ae6ed2b8-3c3b-4a41-9e2a-1c0e1d06e3a5", NodeIp: "10.244.3.8"}}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var drained []string
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `["10.244.3.8"]`, `{}`, "3.11.7", &drained)
+
+	//Nothing is done until the node is down for long enough
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	since, found := status.NodesDownSince["cassandra-demo-dc1-rack1-1"]
+	assert.True(found)
+	assert.Empty(status.Remediations)
+
+	status.NodesDownSince["cassandra-demo-dc1-rack1-1"] = metav1.NewTime(since.Add(-11 * time.Minute))
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	assert.Equal(1, len(status.Remediations))
+	assert.Equal(remediationReasonNodeDown, status.Remediations[0].Reason)
+	assert.Equal(api.RemediationAlert, status.Remediations[0].Action)
+
+	//The node is forgotten once up
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `[]`, `{}`, "3.11.7", &drained)
+	assert.Nil(rcc.ReconcileRemediations(cc, status))
+	assert.Nil(status.NodesDownSince)
+}
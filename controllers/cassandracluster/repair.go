@@ -0,0 +1,316 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// repairStatusMaxErrors is the number of consecutive errors reading the status of a repair command after which it is
+// no longer waited for
+const repairStatusMaxErrors = 30
+
+// repairStatusDelay is the delay between two reads of the status of a repair command
+var repairStatusDelay = monitorSleepDelay
+
+var (
+	minToken      = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 63))
+	tokenRing     = new(big.Int).Lsh(big.NewInt(1), 64)
+	maxToken      = new(big.Int).Add(minToken, tokenRing)
+	defaultRepair = api.Repair{PrimaryRange: func(b bool) *bool { return &b }(true),
+		Parallelism: api.DefaultRepairParallelism}
+)
+
+// splitTokenRange splits the Murmur3 token range (start, end] in parts ranges of the same size
+func splitTokenRange(start, end string, parts int32) ([][2]string, error) {
+	startToken, ok := new(big.Int).SetString(start, 10)
+	if !ok {
+		return nil, fmt.Errorf("Cannot parse token %s", start)
+	}
+	endToken, ok := new(big.Int).SetString(end, 10)
+	if !ok {
+		return nil, fmt.Errorf("Cannot parse token %s", end)
+	}
+	//The range wraps around the ring
+	if endToken.Cmp(startToken) <= 0 {
+		endToken.Add(endToken, tokenRing)
+	}
+	size := new(big.Int).Sub(endToken, startToken)
+	if size.Cmp(big.NewInt(int64(parts))) < 0 {
+		return [][2]string{{start, end}}, nil
+	}
+
+	token := func(i int32) string {
+		t := new(big.Int).Mul(size, big.NewInt(int64(i)))
+		t.Div(t, big.NewInt(int64(parts)))
+		t.Add(t, startToken)
+		if t.Cmp(maxToken) >= 0 {
+			t.Sub(t, tokenRing)
+		}
+		return t.String()
+	}
+	var ranges [][2]string
+	for i := int32(0); i < parts; i++ {
+		ranges = append(ranges, [2]string{token(i), token(i + 1)})
+	}
+	return ranges, nil
+}
+
+// repairOptions returns the options of each repairAsync call needed to repair a keyspace on a node
+func repairOptions(repair *api.Repair, jolokiaClient *JolokiaClient, keyspace,
+	nodeIP string) ([]map[string]string, error) {
+	if repair == nil {
+		repair = &defaultRepair
+	}
+	options := map[string]string{"parallelism": repair.Parallelism, "incremental": "false"}
+	if repair.Subranges == 0 {
+		if repair.PrimaryRange == nil || *repair.PrimaryRange {
+			options["primaryRange"] = "true"
+		}
+		return []map[string]string{options}, nil
+	}
+
+	primaryRanges, err := jolokiaClient.primaryRanges(keyspace, nodeIP)
+	if err != nil {
+		return nil, err
+	}
+	var optionsList []map[string]string
+	for _, primaryRange := range primaryRanges {
+		subranges, err := splitTokenRange(primaryRange[0], primaryRange[1], repair.Subranges)
+		if err != nil {
+			return nil, err
+		}
+		for _, subrange := range subranges {
+			subrangeOptions := map[string]string{"ranges": subrange[0] + ":" + subrange[1]}
+			for key, value := range options {
+				subrangeOptions[key] = value
+			}
+			optionsList = append(optionsList, subrangeOptions)
+		}
+	}
+	return optionsList, nil
+}
+
+// repairKeyspaces returns the keyspaces to repair: the ones of the current repair when it is not a scheduled one,
+// the ones of spec.repair otherwise
+func repairKeyspaces(cc *api.CassandraCluster, jolokiaClient *JolokiaClient) ([]string, error) {
+	if cc.Status.Repair != nil && len(cc.Status.Repair.Keyspaces) > 0 {
+		return cc.Status.Repair.Keyspaces, nil
+	}
+	if cc.Spec.Repair != nil && len(cc.Spec.Repair.Keyspaces) > 0 {
+		return cc.Spec.Repair.Keyspaces, nil
+	}
+	return jolokiaClient.nonLocalKeyspaces()
+}
+
+// repairOutcomeIsKnown returns whether the nodes keep the status of the repairs, which Cassandra does since 4.0
+func repairOutcomeIsKnown(cc *api.CassandraCluster) bool {
+	return cassandraMajorVersion(cassandraServerVersion(cc)) >= 4
+}
+
+// waitRepair waits for the end of a repair command and returns an error if it failed. When its outcome can't be
+// known, the command is only waited for. A command whose status can't be read repairStatusMaxErrors times in a row,
+// because of Jolokia or a restarted node, is an error
+func waitRepair(jolokiaClient *JolokiaClient, command int, outcomeIsKnown bool) error {
+	for failedReads := 0; ; time.Sleep(repairStatusDelay) {
+		running, err := jolokiaClient.repairIsRunning(command)
+		if err == nil && !running {
+			break
+		}
+		if err == nil {
+			failedReads = 0
+			continue
+		}
+		if failedReads++; failedReads >= repairStatusMaxErrors {
+			return fmt.Errorf("Cannot get status of repair #%d: %v", command, err)
+		}
+	}
+	if !outcomeIsKnown {
+		return nil
+	}
+	failure, err := jolokiaClient.repairFailure(command)
+	if err != nil {
+		return err
+	}
+	if failure != "" {
+		return fmt.Errorf("Repair #%d failed: %s", command, failure)
+	}
+	return nil
+}
+
+// dcRackNames returns the racks in the order of the topology
+func dcRackNames(cc *api.CassandraCluster) []string {
+	var names []string
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+			names = append(names, cc.GetDCRackName(dcName, cc.GetRackName(dc, rack)))
+		}
+	}
+	return names
+}
+
+func (rcc *CassandraClusterReconciler) startRackRepair(cc *api.CassandraCluster, status *api.CassandraClusterStatus,
+	dcRackName string) {
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName}).Info("Start repair of rack")
+	status.Repair.DCRack = dcRackName
+	dcName, rackName := cc.GetDCNameAndRackNameFromDCRackName(dcRackName)
+	rcc.addPodOperationLabels(cc, dcName, rackName,
+		map[string]string{"operation-name": api.OperationRepair, "operation-status": api.StatusToDo})
+}
+
+// startUnscheduledRepair starts a repair of some keyspaces outside the schedule of spec.repair, which it does not
+// change. The racks are repaired one after another as in a scheduled repair
+func (rcc *CassandraClusterReconciler) startUnscheduledRepair(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus, keyspaces []string) {
+	racks := dcRackNames(cc)
+	if len(racks) == 0 {
+		return
+	}
+	if status.Repair == nil {
+		status.Repair = &api.RepairStatus{}
+	}
+	now := metav1.Now()
+	status.Repair.Keyspaces = keyspaces
+	status.Repair.UnscheduledStartTime = &now
+	status.Repair.Failed = false
+	status.Repair.OutcomeUnknown = !repairOutcomeIsKnown(cc)
+	rcc.startRackRepair(cc, status, racks[0])
+}
+
+// ReconcileRepair starts the repairs scheduled in spec.repair. The racks are repaired one after another, each
+// node of a rack running the repair of its ranges as a pod operation
+func (rcc *CassandraClusterReconciler) ReconcileRepair(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	//An unscheduled repair goes on without spec.repair
+	if cc.Spec.Repair == nil && (status.Repair == nil || status.Repair.DCRack == "") {
+		return nil
+	}
+	if status.Repair == nil {
+		status.Repair = &api.RepairStatus{}
+	}
+	racks := dcRackNames(cc)
+	if len(racks) == 0 {
+		return nil
+	}
+
+	if status.Repair.DCRack == "" {
+		schedule, err := cron.ParseStandard(cc.Spec.Repair.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid repair schedule %s: %v", cc.Spec.Repair.Schedule, err)
+		}
+		lastRepair := cc.CreationTimestamp.Time
+		if status.Repair.StartTime != nil {
+			lastRepair = status.Repair.StartTime.Time
+		}
+		now := metav1.Now()
+		if schedule.Next(lastRepair).After(now.Time) {
+			return nil
+		}
+		//A repair does not start during another action on the cluster
+		if status.Phase != api.ClusterPhaseRunning.Name || status.LastClusterActionStatus != api.StatusDone {
+			return nil
+		}
+		status.Repair.StartTime = &now
+		status.Repair.Keyspaces = nil
+		status.Repair.UnscheduledStartTime = nil
+		status.Repair.Failed = false
+		status.Repair.OutcomeUnknown = !repairOutcomeIsKnown(cc)
+		rcc.startRackRepair(cc, status, racks[0])
+		return nil
+	}
+
+	dcName, rackName := cc.GetDCNameAndRackNameFromDCRackName(status.Repair.DCRack)
+	podsList, err := rcc.ListPods(cc.Namespace, k8s.MergeLabels(k8s.LabelsForCassandraDCRack(cc, dcName, rackName),
+		map[string]string{"operation-name": api.OperationRepair}))
+	if err != nil {
+		return err
+	}
+	for _, pod := range podsList.Items {
+		switch pod.Labels["operation-status"] {
+		case api.StatusToDo, api.StatusOngoing:
+			return nil
+		case api.StatusError:
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": status.Repair.DCRack,
+				"pod": pod.Name}).Warn("Repair has failed")
+			status.Repair.Failed = true
+		}
+	}
+
+	for i, dcRackName := range racks {
+		if dcRackName == status.Repair.DCRack && i+1 < len(racks) {
+			rcc.startRackRepair(cc, status, racks[i+1])
+			return nil
+		}
+	}
+
+	status.Repair.DCRack = ""
+	startTime := status.Repair.StartTime
+	keyspaces := status.Repair.Keyspaces
+	if len(keyspaces) > 0 {
+		startTime = status.Repair.UnscheduledStartTime
+	} else if cc.Spec.Repair != nil {
+		keyspaces = cc.Spec.Repair.Keyspaces
+	}
+	if len(keyspaces) == 0 {
+		pod, err := rcc.readyCassandraPod(cc)
+		if err != nil {
+			return err
+		}
+		jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(*pod), JolokiaPort, rcc,
+			cc.Spec.ImageJolokiaSecret, cc.Namespace)
+		if err != nil {
+			return err
+		}
+		if keyspaces, err = jolokiaClient.nonLocalKeyspaces(); err != nil {
+			return err
+		}
+	}
+	status.Repair.LastEndedRepairs = recordRepair(status.Repair.LastEndedRepairs, keyspaces, *startTime)
+
+	logging := logrus.WithFields(logrus.Fields{"cluster": cc.Name, "keyspaces": strings.Join(keyspaces, ",")})
+	switch {
+	case status.Repair.Failed:
+		logging.Warn("Repair has ended with errors")
+	case status.Repair.OutcomeUnknown:
+		logging.Warn("Repair has ended, whether it failed is unknown as Cassandra keeps the status of the " +
+			"repairs since 4.0")
+	default:
+		logging.Info("Repair is done")
+		status.Repair.LastSuccessfulRepairs = recordRepair(status.Repair.LastSuccessfulRepairs, keyspaces,
+			*startTime)
+	}
+	return nil
+}
+
+// recordRepair stores the start time of a repair for each of its keyspaces
+func recordRepair(repairs map[string]metav1.Time, keyspaces []string, startTime metav1.Time) map[string]metav1.Time {
+	if repairs == nil {
+		repairs = map[string]metav1.Time{}
+	}
+	for _, keyspace := range keyspaces {
+		repairs[keyspace] = startTime
+	}
+	return repairs
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSplitTokenRange(t *testing.T) {
+	assert := assert.New(t)
+
+	ranges, err := splitTokenRange("-100", "200", 3)
+	assert.Nil(err)
+	assert.Equal([][2]string{{"-100", "0"}, {"0", "100"}, {"100", "200"}}, ranges)
+
+	//The range wraps around the ring
+	ranges, err = splitTokenRange("9223372036854775708", "-9223372036854775708", 2)
+	assert.Nil(err)
+	assert.Equal([][2]string{{"9223372036854775708", "-9223372036854775808"},
+		{"-9223372036854775808", "-9223372036854775708"}}, ranges)
+
+	//A range smaller than the number of parts is not split
+	ranges, err = splitTokenRange("1", "2", 4)
+	assert.Nil(err)
+	assert.Equal([][2]string{{"1", "2"}}, ranges)
+
+	_, err = splitTokenRange("a", "2", 4)
+	assert.NotNil(err)
+}
+
+func helperPodOperationLabels(t *testing.T, rcc *CassandraClusterReconciler, cc *api.CassandraCluster,
+	dcRackName string) map[string]string {
+	pod := &v1.Pod{}
+	assert.Nil(t, rcc.Client.Get(context.TODO(), types.NamespacedName{Name: cc.Name + "-" + dcRackName + "-0",
+		Namespace: cc.Namespace}, pod))
+	return pod.Labels
+}
+
+func helperSetPodOperationStatus(t *testing.T, rcc *CassandraClusterReconciler, cc *api.CassandraCluster,
+	dcRackName, operationStatus string) {
+	pod := &v1.Pod{}
+	assert.Nil(t, rcc.Client.Get(context.TODO(), types.NamespacedName{Name: cc.Name + "-" + dcRackName + "-0",
+		Namespace: cc.Namespace}, pod))
+	pod.Labels["operation-status"] = operationStatus
+	assert.Nil(t, rcc.Client.Update(context.TODO(), pod))
+}
+
+func TestReconcileRepair(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.Repair = &api.Repair{Schedule: "0 2 * * *", Keyspaces: []string{"demo"}}
+	cc.Spec.CassandraImage = "cassandra:4.0.1"
+	cc.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour))
+	status := cc.Status.DeepCopy()
+	status.Phase = api.ClusterPhaseRunning.Name
+	status.LastClusterActionStatus = api.StatusDone
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		dcName, rackName := cc.GetDCNameAndRackNameFromDCRackName(dcRackName)
+		helperCreateCassandraPod(t, rcc, cc, dcName, rackName)
+	}
+
+	//The first rack is labeled to be repaired
+	assert.Nil(rcc.ReconcileRepair(cc, status))
+	assert.Equal("dc1-rack1", status.Repair.DCRack)
+	assert.NotNil(status.Repair.StartTime)
+	labels := helperPodOperationLabels(t, rcc, cc, "dc1-rack1")
+	assert.Equal(api.OperationRepair, labels["operation-name"])
+	assert.Equal(api.StatusToDo, labels["operation-status"])
+	assert.Equal("", helperPodOperationLabels(t, rcc, cc, "dc1-rack2")["operation-name"])
+
+	//The next rack waits for the repair of the first one
+	assert.Nil(rcc.ReconcileRepair(cc, status))
+	assert.Equal("dc1-rack1", status.Repair.DCRack)
+
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		assert.Equal(dcRackName, status.Repair.DCRack)
+		helperSetPodOperationStatus(t, rcc, cc, dcRackName, api.StatusDone)
+		assert.Nil(rcc.ReconcileRepair(cc, status))
+	}
+	assert.Equal("", status.Repair.DCRack)
+	assert.False(status.Repair.Failed)
+	assert.False(status.Repair.OutcomeUnknown)
+	assert.Equal(map[string]metav1.Time{"demo": *status.Repair.StartTime}, status.Repair.LastSuccessfulRepairs)
+	assert.Equal(map[string]metav1.Time{"demo": *status.Repair.StartTime}, status.Repair.LastEndedRepairs)
+
+	//The next repair is not due yet
+	assert.Nil(rcc.ReconcileRepair(cc, status))
+	assert.Equal("", status.Repair.DCRack)
+
+	//A failed repair does not update the last successful repairs
+	startTime := metav1.NewTime(time.Now().Add(-48 * time.Hour))
+	status.Repair.StartTime = &startTime
+	assert.Nil(rcc.ReconcileRepair(cc, status))
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		helperSetPodOperationStatus(t, rcc, cc, dcRackName, api.StatusError)
+		assert.Nil(rcc.ReconcileRepair(cc, status))
+	}
+	assert.Equal("", status.Repair.DCRack)
+	assert.True(status.Repair.Failed)
+	assert.NotEqual(*status.Repair.StartTime, status.Repair.LastSuccessfulRepairs["demo"])
+	assert.Equal(*status.Repair.StartTime, status.Repair.LastEndedRepairs["demo"])
+
+	//Cassandra 3 does not keep the status of the repairs, a repair which ended is not known to have succeeded
+	cc.Spec.CassandraImage = "cassandra:3.11.7"
+	startTime = metav1.NewTime(time.Now().Add(-24 * time.Hour))
+	status.Repair.StartTime = &startTime
+	assert.Nil(rcc.ReconcileRepair(cc, status))
+	assert.True(status.Repair.OutcomeUnknown)
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		helperSetPodOperationStatus(t, rcc, cc, dcRackName, api.StatusDone)
+		assert.Nil(rcc.ReconcileRepair(cc, status))
+	}
+	assert.Equal("", status.Repair.DCRack)
+	assert.False(status.Repair.Failed)
+	assert.NotEqual(*status.Repair.StartTime, status.Repair.LastSuccessfulRepairs["demo"])
+	assert.Equal(*status.Repair.StartTime, status.Repair.LastEndedRepairs["demo"])
+}
+
+func TestReconcileUnscheduledRepair(t *testing.T) {
+	assert := assert.New(t)
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.Spec.CassandraImage = "cassandra:4.0.1"
+	status := cc.Status.DeepCopy()
+	status.Phase = api.ClusterPhaseRunning.Name
+	status.LastClusterActionStatus = api.StatusDone
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		dcName, rackName := cc.GetDCNameAndRackNameFromDCRackName(dcRackName)
+		helperCreateCassandraPod(t, rcc, cc, dcName, rackName)
+	}
+
+	//A repair started outside the schedule goes on without spec.repair and keeps the schedule
+	rcc.startUnscheduledRepair(cc, status, []string{"system_auth"})
+	assert.Equal("dc1-rack1", status.Repair.DCRack)
+	assert.Equal(api.OperationRepair, helperPodOperationLabels(t, rcc, cc, "dc1-rack1")["operation-name"])
+	cc.Status = *status
+	keyspaces, err := repairKeyspaces(cc, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"system_auth"}, keyspaces)
+
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		assert.Equal(dcRackName, status.Repair.DCRack)
+		helperSetPodOperationStatus(t, rcc, cc, dcRackName, api.StatusDone)
+		assert.Nil(rcc.ReconcileRepair(cc, status))
+	}
+	assert.Equal("", status.Repair.DCRack)
+	assert.Nil(status.Repair.StartTime)
+	assert.Equal(map[string]metav1.Time{"system_auth": *status.Repair.UnscheduledStartTime},
+		status.Repair.LastSuccessfulRepairs)
+
+	//A scheduled repair repairs the keyspaces of spec.repair
+	cc.Spec.Repair = &api.Repair{Schedule: "0 2 * * *", Keyspaces: []string{"demo"}}
+	cc.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour))
+	assert.Nil(rcc.ReconcileRepair(cc, status))
+	assert.NotNil(status.Repair.StartTime)
+	assert.Nil(status.Repair.Keyspaces)
+	cc.Status = *status
+	keyspaces, err = repairKeyspaces(cc, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"demo"}, keyspaces)
+}
+
+func TestWaitRepair(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	previousDelay := repairStatusDelay
+	repairStatusDelay = 0
+	defer func() { repairStatusDelay = previousDelay }()
+
+	runningChecks, failing := 2, false
+	//The list requests of go_jolokia add a / to the url
+	threadPoolsResponder := func(req *http.Request) (*http.Response, error) {
+		if failing {
+			return httpmock.NewStringResponse(500, ""), nil
+		}
+		threadPools := `{}`
+		if runningChecks--; runningChecks >= 0 {
+			threadPools = `{"type=Repair#3": {}}`
+		}
+		return httpmock.NewStringResponse(200, `{"request": {"path": "org.apache.cassandra.internal",
+			"type": "list"}, "value": `+threadPools+`, "timestamp": 1528850319, "status": 200}`), nil
+	}
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort)+"/", threadPoolsResponder)
+	jolokiaClient, _ := NewJolokiaClient(host, JolokiaPort, nil, v1.LocalObjectReference{}, "ns")
+
+	//Without the status of the repairs, a command is only waited for
+	assert.Nil(waitRepair(jolokiaClient, 3, false))
+	assert.Equal(3, httpmock.GetTotalCallCount())
+
+	//A command whose status can't be read is not waited for forever
+	httpmock.Reset()
+	httpmock.RegisterResponder("POST", JolokiaURL(host, jolokiaPort)+"/", threadPoolsResponder)
+	failing = true
+	assert.NotNil(waitRepair(jolokiaClient, 3, false))
+	assert.Equal(repairStatusMaxErrors, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// restoredName returns the name of the ConfigMap created once the snapshot of restoreFrom is restored in all the
+// nodes. The restore container of the pods created after does not restore the snapshot when it exists
+func restoredName(cc *api.CassandraCluster) string {
+	return cc.Name + "-restored"
+}
+
+// markRestored creates the ConfigMap named by restoredName once the snapshot of restoreFrom is restored
+func (rcc *CassandraClusterReconciler) markRestored(cc *api.CassandraCluster, status *api.CassandraClusterStatus) error {
+	if cc.Spec.RestoreFrom == nil || isRestoring(cc, status) {
+		return nil
+	}
+	restored := &v1.ConfigMap{}
+	err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: restoredName(cc), Namespace: cc.Namespace},
+		restored)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "snapshotTag": cc.Spec.RestoreFrom.SnapshotTag}).
+		Info("Snapshot restored in all the nodes, the nodes added from now on start empty")
+	restored = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: restoredName(cc), Namespace: cc.Namespace,
+		Labels: k8s.LabelsForCassandra(cc)},
+		Data: map[string]string{restoredKey: cc.Spec.RestoreFrom.SnapshotTag}}
+	k8s.AddOwnerRefToObject(restored, k8s.AsOwner(cc))
+	return rcc.Client.Create(context.TODO(), restored)
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// specHistoryLimit is the number of specs kept in the spec history of a cluster
+const specHistoryLimit = 10
+
+// specHistoryAccepted is the key of the spec history keeping the spec accepted by the operator until the
+// statefulsets have applied it and it is recorded under a new revision
+const specHistoryAccepted = "accepted"
+
+// specHistoryName returns the name of the ConfigMap keeping the specs applied to a cluster, by revision
+func specHistoryName(cc *api.CassandraCluster) string {
+	return cc.Name + "-spec-history"
+}
+
+// appliedConfiguration returns what the spec history keeps of a cluster: its name, namespace, labels and spec
+func appliedConfiguration(cc *api.CassandraCluster) (string, error) {
+	applied := api.CassandraCluster{
+		TypeMeta:   cc.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{Name: cc.Name, Namespace: cc.Namespace, Labels: cc.Labels},
+		Spec:       cc.Spec,
+	}
+	data, err := json.Marshal(applied)
+	return string(data), err
+}
+
+// specHash returns the hash of a spec kept in the spec history
+func specHash(applied string) string {
+	hash := sha256.Sum256([]byte(applied))
+	return hex.EncodeToString(hash[:])
+}
+
+// acceptedSpec returns the spec of a spec history the next changes are compared to: the one accepted by the
+// operator if it is not applied yet, the one of the applied revision otherwise
+func acceptedSpec(history *v1.ConfigMap, appliedRevision int64) string {
+	if accepted := history.Data[specHistoryAccepted]; accepted != "" {
+		return accepted
+	}
+	return history.Data[strconv.FormatInt(appliedRevision, 10)]
+}
+
+// getSpecHistory returns the spec history of a cluster, nil if it has none yet
+func (rcc *CassandraClusterReconciler) getSpecHistory(cc *api.CassandraCluster) (*v1.ConfigMap, error) {
+	history := &v1.ConfigMap{}
+	err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: specHistoryName(cc), Namespace: cc.Namespace},
+		history)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return history, err
+}
+
+// appliedCluster returns the cluster as applied at a revision of its spec history
+func (rcc *CassandraClusterReconciler) appliedCluster(cc *api.CassandraCluster,
+	revision int64) (*api.CassandraCluster, error) {
+	history, err := rcc.getSpecHistory(cc)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil || history.Data[strconv.FormatInt(revision, 10)] == "" {
+		return nil, fmt.Errorf("revision %d not found in spec history %s", revision, specHistoryName(cc))
+	}
+
+	var applied api.CassandraCluster
+	if err := json.Unmarshal([]byte(history.Data[strconv.FormatInt(revision, 10)]), &applied); err != nil {
+		return nil, err
+	}
+	return &applied, nil
+}
+
+// lastAppliedCluster returns the cluster as last accepted by the operator, nil if nothing was accepted yet.
+// A cluster applied by an older operator only has it in its last-applied-configuration annotation
+func (rcc *CassandraClusterReconciler) lastAppliedCluster(cc *api.CassandraCluster) (*api.CassandraCluster, error) {
+	history, err := rcc.getSpecHistory(cc)
+	if err != nil {
+		return nil, err
+	}
+	data := ""
+	if history != nil {
+		data = acceptedSpec(history, cc.Status.AppliedRevision)
+	}
+	if data == "" && cc.Status.AppliedRevision != 0 {
+		return nil, fmt.Errorf("revision %d not found in spec history %s", cc.Status.AppliedRevision,
+			specHistoryName(cc))
+	}
+	if data == "" {
+		data = cc.Annotations[api.AnnotationLastApplied]
+	}
+	if data == "" {
+		return nil, nil
+	}
+	var applied api.CassandraCluster
+	if err := json.Unmarshal([]byte(data), &applied); err != nil {
+		return nil, err
+	}
+	return &applied, nil
+}
+
+// specChanged returns true when the spec of a cluster differs from the last one accepted
+func (rcc *CassandraClusterReconciler) specChanged(cc *api.CassandraCluster) bool {
+	history, err := rcc.getSpecHistory(cc)
+	if err != nil || history == nil {
+		return true
+	}
+	accepted := acceptedSpec(history, cc.Status.AppliedRevision)
+	applied, _ := appliedConfiguration(cc)
+	return accepted == "" || specHash(accepted) != specHash(applied)
+}
+
+// saveSpecHistory creates or updates the spec history of a cluster
+func (rcc *CassandraClusterReconciler) saveSpecHistory(history *v1.ConfigMap) error {
+	if history.ResourceVersion == "" {
+		return rcc.Client.Create(context.TODO(), history)
+	}
+	return rcc.Client.Update(context.TODO(), history)
+}
+
+// acceptSpec keeps the spec of a cluster in its spec history once the operator has accepted its changes, the next
+// changes are compared to it while the statefulsets apply it
+func (rcc *CassandraClusterReconciler) acceptSpec(cc *api.CassandraCluster) error {
+	applied, err := appliedConfiguration(cc)
+	if err != nil {
+		return err
+	}
+
+	history, err := rcc.getSpecHistory(cc)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		history = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: specHistoryName(cc), Namespace: cc.Namespace,
+			Labels: k8s.LabelsForCassandra(cc)}}
+		k8s.AddOwnerRefToObject(history, k8s.AsOwner(cc))
+	}
+	if history.Data == nil {
+		history.Data = map[string]string{}
+	}
+	if accepted := acceptedSpec(history, cc.Status.AppliedRevision); accepted != "" &&
+		specHash(accepted) == specHash(applied) {
+		return nil
+	}
+	history.Data[specHistoryAccepted] = applied
+	return rcc.saveSpecHistory(history)
+}
+
+// recordAppliedSpec adds the spec accepted for a cluster to its spec history under a new revision once the
+// statefulsets have applied it, keeping the specHistoryLimit most recent ones, and sets the revision in the status.
+// A spec whose hash equals the one of the latest revision is not recorded again
+func (rcc *CassandraClusterReconciler) recordAppliedSpec(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	if status.Phase != api.ClusterPhaseRunning.Name || status.LastClusterActionStatus != api.StatusDone {
+		return nil
+	}
+
+	history, err := rcc.getSpecHistory(cc)
+	if err != nil || history == nil {
+		return err
+	}
+	accepted := history.Data[specHistoryAccepted]
+	if accepted == "" {
+		return nil
+	}
+	delete(history.Data, specHistoryAccepted)
+
+	revisions := specHistoryRevisions(history)
+	revision := int64(1)
+	if len(revisions) > 0 {
+		revision = revisions[len(revisions)-1]
+	}
+	if len(revisions) == 0 || specHash(history.Data[strconv.FormatInt(revision, 10)]) != specHash(accepted) {
+		if len(revisions) > 0 {
+			revision++
+		}
+		history.Data[strconv.FormatInt(revision, 10)] = accepted
+		pruneSpecHistory(history)
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "revision": revision}).
+			Info("Spec added to the spec history")
+	}
+
+	if err := rcc.saveSpecHistory(history); err != nil {
+		return err
+	}
+	status.AppliedRevision = revision
+	return nil
+}
+
+// specHistoryRevisions returns the revisions of a spec history, the oldest first
+func specHistoryRevisions(history *v1.ConfigMap) []int64 {
+	var revisions []int64
+	for key := range history.Data {
+		revision, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+	return revisions
+}
+
+// pruneSpecHistory removes the oldest specs of a spec history beyond specHistoryLimit
+func pruneSpecHistory(history *v1.ConfigMap) {
+	revisions := specHistoryRevisions(history)
+	for len(revisions) > specHistoryLimit {
+		delete(history.Data, strconv.FormatInt(revisions[0], 10))
+		revisions = revisions[1:]
+	}
+}
+
+// RollbackSpec restores the spec applied at the revision given by the rollback-to annotation of a cluster.
+// It returns true when the cluster was updated, the restored spec is then applied as any other change
+func (rcc *CassandraClusterReconciler) RollbackSpec(cc *api.CassandraCluster) (bool, error) {
+	value, ok := cc.Annotations[api.AnnotationRollbackTo]
+	if !ok {
+		return false, nil
+	}
+	delete(cc.Annotations, api.AnnotationRollbackTo)
+
+	revision, err := strconv.ParseInt(value, 10, 64)
+	var applied *api.CassandraCluster
+	if err == nil {
+		applied, err = rcc.appliedCluster(cc, revision)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "revision": value}).
+			Errorf("Can't roll back the spec: %v", err)
+		return true, rcc.Client.Update(context.TODO(), cc)
+	}
+
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "revision": revision}).
+		Info("Roll back the spec to the one applied at this revision")
+	cc.Spec = applied.Spec
+	return true, rcc.Client.Update(context.TODO(), cc)
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// helperApplySpec accepts the spec of a cluster and records it once its statefulsets are done applying it
+func helperApplySpec(assert *assert.Assertions, rcc *CassandraClusterReconciler, cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) {
+	assert.Nil(rcc.acceptSpec(cc))
+	status.Phase = api.ClusterPhaseRunning.Name
+	status.LastClusterActionStatus = api.StatusDone
+	assert.Nil(rcc.recordAppliedSpec(cc, status))
+	cc.Status = *status
+}
+
+func TestRecordAppliedSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	image := cc.Spec.CassandraImage
+	status := cc.Status.DeepCopy()
+
+	//The spec accepted is only recorded once the statefulsets have applied it
+	assert.Nil(rcc.acceptSpec(cc))
+	assert.False(rcc.specChanged(cc))
+	status.Phase = api.ClusterPhasePending.Name
+	assert.Nil(rcc.recordAppliedSpec(cc, status))
+	assert.Equal(int64(0), status.AppliedRevision)
+	status.Phase = api.ClusterPhaseRunning.Name
+	status.LastClusterActionStatus = api.StatusOngoing
+	assert.Nil(rcc.recordAppliedSpec(cc, status))
+	assert.Equal(int64(0), status.AppliedRevision)
+
+	status.LastClusterActionStatus = api.StatusDone
+	assert.Nil(rcc.recordAppliedSpec(cc, status))
+	assert.Equal(int64(1), status.AppliedRevision)
+	cc.Status = *status
+	history, _ := rcc.getSpecHistory(cc)
+	assert.Equal(map[string]string{"1": history.Data["1"]}, history.Data)
+
+	//The same spec is not recorded twice
+	helperApplySpec(assert, rcc, cc, status)
+	assert.Equal(int64(1), status.AppliedRevision)
+	assert.False(rcc.specChanged(cc))
+
+	//The changes are compared to the spec accepted while the statefulsets apply it
+	cc.Spec.CassandraImage = "cassandra:3.11.9"
+	assert.True(rcc.specChanged(cc))
+	assert.Nil(rcc.acceptSpec(cc))
+	assert.False(rcc.specChanged(cc))
+	applied, err := rcc.lastAppliedCluster(cc)
+	assert.Nil(err)
+	assert.Equal("cassandra:3.11.9", applied.Spec.CassandraImage)
+
+	helperApplySpec(assert, rcc, cc, status)
+	assert.Equal(int64(2), status.AppliedRevision)
+
+	//A change reverted before being applied has the hash of the latest revision and is not recorded
+	cc.Spec.CassandraImage = "cassandra:3.11.8"
+	assert.Nil(rcc.acceptSpec(cc))
+	cc.Spec.CassandraImage = "cassandra:3.11.9"
+	helperApplySpec(assert, rcc, cc, status)
+	assert.Equal(int64(2), status.AppliedRevision)
+	history, _ = rcc.getSpecHistory(cc)
+	assert.Equal([]int64{1, 2}, specHistoryRevisions(history))
+	assert.Len(history.Data, 2)
+
+	applied, err = rcc.lastAppliedCluster(cc)
+	assert.Nil(err)
+	assert.Equal("cassandra:3.11.9", applied.Spec.CassandraImage)
+	applied, err = rcc.appliedCluster(cc, 1)
+	assert.Nil(err)
+	assert.Equal(image, applied.Spec.CassandraImage)
+
+	//Only the most recent specs are kept
+	for i := 0; i < specHistoryLimit; i++ {
+		cc.Spec.CassandraImage = fmt.Sprintf("cassandra:3.11.%d", 10+i)
+		helperApplySpec(assert, rcc, cc, status)
+	}
+	history, _ = rcc.getSpecHistory(cc)
+	assert.Equal(specHistoryLimit, len(history.Data))
+	assert.Equal([]int64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, specHistoryRevisions(history))
+	_, err = rcc.appliedCluster(cc, 2)
+	assert.NotNil(err)
+}
+
+func TestLastAppliedClusterFromAnnotation(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	lastApplied, _ := cc.ComputeLastAppliedConfiguration()
+	cc.Annotations = map[string]string{api.AnnotationLastApplied: string(lastApplied)}
+	nodesPerRacks := cc.Spec.NodesPerRacks
+	cc.Spec.NodesPerRacks = 0
+
+	//A cluster applied by an older operator is compared to its annotation
+	applied, err := rcc.lastAppliedCluster(cc)
+	assert.Nil(err)
+	assert.Equal(nodesPerRacks, applied.Spec.NodesPerRacks)
+
+	status := cc.Status.DeepCopy()
+	assert.True(rcc.CheckNonAllowedChanges(cc, status))
+	assert.Equal(nodesPerRacks, cc.Spec.NodesPerRacks)
+
+	//The annotation is removed once the spec history holds the spec
+	assert.Nil(rcc.acceptSpec(cc))
+	status.Phase = api.ClusterPhaseRunning.Name
+	status.LastClusterActionStatus = api.StatusDone
+	rcc.updateCassandraStatus(cc, status)
+	assert.Equal(int64(1), cc.Status.AppliedRevision)
+	_, found := cc.Annotations[api.AnnotationLastApplied]
+	assert.False(found)
+}
+
+func TestRollbackSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	image := cc.Spec.CassandraImage
+	helperApplySpec(assert, rcc, cc, cc.Status.DeepCopy())
+
+	rolledBack, err := rcc.RollbackSpec(cc)
+	assert.False(rolledBack)
+	assert.Nil(err)
+
+	cc.Spec.CassandraImage = "cassandra:3.11.9"
+	helperApplySpec(assert, rcc, cc, cc.Status.DeepCopy())
+	assert.Equal(int64(2), cc.Status.AppliedRevision)
+
+	cc.Annotations = map[string]string{api.AnnotationRollbackTo: "1"}
+	rolledBack, err = rcc.RollbackSpec(cc)
+	assert.True(rolledBack)
+	assert.Nil(err)
+
+	storedCC := &api.CassandraCluster{}
+	rcc.Client.Get(context.TODO(), types.NamespacedName{Name: cc.Name, Namespace: cc.Namespace}, storedCC)
+	assert.Equal(image, storedCC.Spec.CassandraImage)
+	_, found := storedCC.Annotations[api.AnnotationRollbackTo]
+	assert.False(found)
+
+	//An unknown revision only removes the annotation
+	storedCC.Annotations = map[string]string{api.AnnotationRollbackTo: "12"}
+	storedCC.Spec.CassandraImage = "cassandra:3.11.9"
+	rolledBack, err = rcc.RollbackSpec(storedCC)
+	assert.True(rolledBack)
+	assert.Nil(err)
+	assert.Equal("cassandra:3.11.9", storedCC.Spec.CassandraImage)
+	_, found = storedCC.Annotations[api.AnnotationRollbackTo]
+	assert.False(found)
+}
@@ -34,6 +34,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var (
@@ -57,7 +58,8 @@ func (rcc *CassandraClusterReconciler) GetStatefulSet(namespace, name string) (*
 	return ss, rcc.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, ss)
 }
 
-func (rcc *CassandraClusterReconciler) DeleteStatefulSet(namespace, name string) error {
+func (rcc *CassandraClusterReconciler) DeleteStatefulSet(namespace, name string,
+	opts ...client.DeleteOption) error {
 
 	ss := &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
@@ -69,7 +71,7 @@ func (rcc *CassandraClusterReconciler) DeleteStatefulSet(namespace, name string)
 			Namespace: namespace,
 		},
 	}
-	return rcc.Client.Delete(context.TODO(), ss)
+	return rcc.Client.Delete(context.TODO(), ss, opts...)
 }
 
 //CreateStatefulSet create a new statefulset ss
@@ -265,6 +267,13 @@ func (rcc *CassandraClusterReconciler) CreateOrUpdateStatefulSet(statefulSet *ap
 		dcRackStatus.CassandraLastAction.StartTime = &now
 	}
 
+	//The volumeClaimTemplates of a statefulset can't be updated, so we expand the PVCs ourselves
+	//and recreate the statefulset
+	if dcRackStatus.CassandraLastAction.Name == api.ActionResizeStorage.Name &&
+		dcRackStatus.CassandraLastAction.Status == api.StatusToDo {
+		return api.BreakResyncLoop, rcc.resizeStorage(statefulSet, dcRackStatus, dcRackName)
+	}
+
 	//Except for RollingRestart we check If Statefulset has changed
 	if !rcc.cc.Spec.NoCheckStsAreEqual &&
 		statefulSetsAreEqual(rcc.storedStatefulSet.DeepCopy(), statefulSet.DeepCopy()) {
@@ -293,6 +302,29 @@ func (rcc *CassandraClusterReconciler) CreateOrUpdateStatefulSet(statefulSet *ap
 	return api.BreakResyncLoop, rcc.UpdateStatefulSet(statefulSet)
 }
 
+// resizeStorage expands the PVCs of the rack to the storage requested by the volumeClaimTemplates of statefulSet,
+// then deletes the stored statefulset without its pods. It is created again with the new volumeClaimTemplates
+// during the next reconcile and adopts the running pods
+func (rcc *CassandraClusterReconciler) resizeStorage(statefulSet *appsv1.StatefulSet,
+	dcRackStatus *api.CassandraRackStatus, dcRackName string) error {
+	if err := rcc.expandPVCs(statefulSet, *rcc.storedStatefulSet.Spec.Replicas); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{"cluster": rcc.cc.Name, "dc-rack": dcRackName}).Info(
+		"PVCs expanded, delete statefulset keeping its pods to update its volumeClaimTemplates")
+	if err := rcc.DeleteStatefulSet(rcc.storedStatefulSet.Namespace, rcc.storedStatefulSet.Name,
+		client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	dcRackStatus.CassandraLastAction.Status = api.StatusOngoing
+	dcRackStatus.CassandraLastAction.StartTime = &now
+	dcRackStatus.CassandraLastAction.EndTime = nil
+	return nil
+}
+
 func getBootstrapContainerFromStatefulset(sts *appsv1.StatefulSet) *v1.Container {
 	for _, container := range sts.Spec.Template.Spec.InitContainers {
 		if container.Name == "bootstrap" {
@@ -1,12 +1,17 @@
 package cassandracluster
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
 
 	api "github.com/Orange-OpenSource/casskop/api/v2"
 	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/Orange-OpenSource/casskop/pkg/k8s"
 	"github.com/stretchr/testify/assert"
@@ -72,3 +77,62 @@ func generateNewCC(cc *api.CassandraCluster, field string, newValue int32) *api.
 
 	return nil
 }
+
+func TestCreateOrUpdateStatefulSetResizeStorage(t *testing.T) {
+	assert := assert.New(t)
+	dcName := "dc2"
+	rackName := "rack1"
+	dcRackName := fmt.Sprintf("%s-%s", dcName, rackName)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	cc.CheckDefaults()
+	rcc.cc = cc
+	rcc.storedPdb = &policyv1beta1.PodDisruptionBudget{}
+	labels, nodeSelector := k8s.DCRackLabelsAndNodeSelectorForStatefulSet(cc, 1, 0)
+	sts, _ := generateCassandraStatefulSet(cc, &cc.Status, dcName, dcRackName, labels, nodeSelector, nil)
+	rcc.CreateStatefulSet(sts)
+
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		pvc := template.DeepCopy()
+		pvc.Name = pvcName(sts, template, 0)
+		pvc.Namespace = sts.Namespace
+		pvc.Status.Capacity = template.Spec.Resources.Requests
+		rcc.Client.Create(context.TODO(), pvc)
+	}
+
+	cc.Spec.DataCapacity = "4Gi"
+	status := cc.Status.DeepCopy()
+	status.CassandraRackStatus[dcRackName].CassandraLastAction.Name = api.ActionResizeStorage.Name
+	status.CassandraRackStatus[dcRackName].CassandraLastAction.Status = api.StatusToDo
+	newSts, _ := generateCassandraStatefulSet(cc, status, dcName, dcRackName, labels, nodeSelector, nil)
+
+	breakResyncLoop, err := rcc.CreateOrUpdateStatefulSet(newSts, status, dcRackName)
+	assert.Nil(err)
+	assert.Equal(api.BreakResyncLoop, breakResyncLoop)
+	assert.Equal(api.StatusOngoing, status.CassandraRackStatus[dcRackName].CassandraLastAction.Status)
+
+	pvc, _ := rcc.GetPVC(sts.Namespace, "data-"+sts.Name+"-0")
+	assert.Equal(resource.MustParse("4Gi"), pvc.Spec.Resources.Requests[v1.ResourceStorage])
+
+	//The statefulset is recreated with the new volumeClaimTemplates during the next reconcile
+	_, err = rcc.GetStatefulSet(sts.Namespace, sts.Name)
+	assert.True(apierrors.IsNotFound(err))
+
+	rcc.CreateStatefulSet(newSts)
+	expanded, _, err := rcc.pvcsExpansionStatus(newSts)
+	assert.Nil(err)
+	assert.False(expanded)
+
+	//A PVC not created yet is pending
+	replicas := *newSts.Spec.Replicas + 1
+	scaledSts := newSts.DeepCopy()
+	scaledSts.Spec.Replicas = &replicas
+	pvc.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse("4Gi")}
+	rcc.Client.Update(context.TODO(), pvc)
+	expanded, _, err = rcc.pvcsExpansionStatus(scaledSts)
+	assert.Nil(err)
+	assert.False(expanded)
+
+	expanded, _, _ = rcc.pvcsExpansionStatus(newSts)
+	assert.True(expanded)
+}
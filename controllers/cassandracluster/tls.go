@@ -0,0 +1,74 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// tlsSecretKeys are the keys of the TLS secret, as created by cert-manager
+var tlsSecretKeys = []string{"tls.crt", "tls.key", "ca.crt"}
+
+// tlsSecretKeystoreKeys are the keys of the keystores cert-manager adds to the TLS secret when asked to
+var tlsSecretKeystoreKeys = []string{"keystore.p12", "truststore.p12"}
+
+// UpdateTLSSecretHash stores in the status the hash of the certificates referenced in spec.tls and of the password
+// of their keystores. When cert-manager renews them, the hash changes and the racks are restarted to load the new
+// keystores
+func (rcc *CassandraClusterReconciler) UpdateTLSSecretHash(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	if cc.Spec.TLS == nil {
+		status.TLSSecretHash = ""
+		return nil
+	}
+
+	secret := &v1.Secret{}
+	if err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: cc.Spec.TLS.SecretName,
+		Namespace: cc.Namespace}, secret); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	for _, key := range tlsSecretKeys {
+		data, ok := secret.Data[key]
+		if !ok {
+			return fmt.Errorf("key %s not found in secret %s", key, secret.Name)
+		}
+		hash.Write(data)
+	}
+	for _, key := range tlsSecretKeystoreKeys {
+		hash.Write(secret.Data[key])
+	}
+
+	passwordSecret := &v1.Secret{}
+	if err := rcc.Client.Get(context.TODO(), types.NamespacedName{Name: cc.Spec.TLS.KeystorePasswordSecret,
+		Namespace: cc.Namespace}, passwordSecret); err != nil {
+		return err
+	}
+	password, ok := passwordSecret.Data["password"]
+	if !ok {
+		return fmt.Errorf("key password not found in secret %s", passwordSecret.Name)
+	}
+	hash.Write(password)
+	status.TLSSecretHash = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
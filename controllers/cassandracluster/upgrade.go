@@ -0,0 +1,240 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// unreachableSchemaVersion is the schema version under which the unreachable nodes are listed
+const unreachableSchemaVersion = "UNREACHABLE"
+
+// isMajorUpgrade returns true when the nodes of a cluster are asked to run a new major version of Cassandra
+func isMajorUpgrade(oldCRD, cc *api.CassandraCluster) bool {
+	oldMajor := cassandraMajorVersion(cassandraServerVersion(oldCRD))
+	return oldMajor != 0 && cassandraMajorVersion(cassandraServerVersion(cc)) > oldMajor
+}
+
+// MajorVersionChangeRefusedReason returns why the major version of Cassandra run by the nodes can't be changed,
+// empty if it is not changed or if the cluster can be upgraded
+func (rcc *CassandraClusterReconciler) MajorVersionChangeRefusedReason(cc *api.CassandraCluster,
+	oldCRD *api.CassandraCluster, status *api.CassandraClusterStatus) string {
+	oldMajor := cassandraMajorVersion(cassandraServerVersion(oldCRD))
+	newMajor := cassandraMajorVersion(cassandraServerVersion(cc))
+	if oldMajor == 0 || newMajor == 0 || oldMajor == newMajor {
+		return ""
+	}
+	if newMajor < oldMajor {
+		return "Cassandra can't be downgraded to a previous major version"
+	}
+
+	var racks []string
+	for dcRackName, dcRackStatus := range status.CassandraRackStatus {
+		if dcRackStatus.Phase != api.ClusterPhaseRunning.Name ||
+			dcRackStatus.CassandraLastAction.Status != api.StatusDone {
+			racks = append(racks, dcRackName)
+		}
+	}
+	if len(racks) > 0 {
+		sort.Strings(racks)
+		return fmt.Sprintf("racks %s are not running or have an action ongoing", strings.Join(racks, ","))
+	}
+
+	podsList, err := rcc.ListCassandraClusterPods(cc)
+	if err != nil {
+		return fmt.Sprintf("can't list the pods: %v", err)
+	}
+	pod, err := GetLastOrFirstPodReady(podsList, false)
+	if err != nil {
+		return "no pod is ready"
+	}
+	jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(*pod), JolokiaPort, rcc,
+		cc.Spec.ImageJolokiaSecret, cc.Namespace)
+	if err != nil {
+		return err.Error()
+	}
+	return upgradeRefusedReason(jolokiaClient)
+}
+
+// upgradeRefusedReason returns why the cluster of a node can't be upgraded: all its nodes must be reachable, none
+// of them joining, and they must agree on the schema
+func upgradeRefusedReason(jolokiaClient *JolokiaClient) string {
+	hasJoiningNodes, err := jolokiaClient.hasJoiningNodes()
+	if err != nil {
+		return err.Error()
+	}
+	if hasJoiningNodes {
+		return "some nodes are joining the cluster"
+	}
+
+	unreachableNodes, err := jolokiaClient.unreachableNodes()
+	if err != nil {
+		return err.Error()
+	}
+	if len(unreachableNodes) > 0 {
+		return fmt.Sprintf("nodes %s are unreachable", strings.Join(unreachableNodes, ","))
+	}
+
+	schemaVersions, err := jolokiaClient.schemaVersions()
+	if err != nil {
+		return err.Error()
+	}
+	if nodes := schemaVersions[unreachableSchemaVersion]; len(nodes) > 0 {
+		return fmt.Sprintf("nodes %s are unreachable", strings.Join(nodes, ","))
+	}
+	if len(schemaVersions) > 1 {
+		return fmt.Sprintf("nodes don't agree on the schema, %d schema versions found", len(schemaVersions))
+	}
+	return ""
+}
+
+// setUpgradeStatus flags each rack to be upgraded to the new major version of Cassandra, one node at a time
+func setUpgradeStatus(cc *api.CassandraCluster, status *api.CassandraClusterStatus) {
+	status.UpgradeVersion = cassandraServerVersion(cc)
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+			dcRackName := cc.GetDCRackName(dcName, cc.GetRackName(dc, rack))
+			dcRackStatus, exists := status.CassandraRackStatus[dcRackName]
+			if !exists {
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name,
+				"dc-rack": dcRackName}).Info("Update Rack Status UpgradeCassandra=ToDo")
+			//No pod is updated until it is drained
+			partition := cc.GetNodesPerRacks(dcRackName)
+			dcRackStatus.UpgradePartition = &partition
+			dcRackStatus.CassandraLastAction.Name = api.ActionUpgradeCassandra.Name
+			ClusterActionMetric.set(api.ActionUpgradeCassandra, cc.Name)
+			dcRackStatus.CassandraLastAction.Status = api.StatusToDo
+			dcRackStatus.CassandraLastAction.StartTime = nil
+			dcRackStatus.CassandraLastAction.EndTime = nil
+		}
+	}
+}
+
+// upgradeNextPod drains the next pod of a rack upgraded to a new major version once the previous one runs it, and
+// lowers the partition of the statefulset so that the pod is updated. It returns true when the status has changed
+func (rcc *CassandraClusterReconciler) upgradeNextPod(cc *api.CassandraCluster, dcRackName string,
+	storedStatefulSet *appsv1.StatefulSet, status *api.CassandraClusterStatus) bool {
+	dcRackStatus := status.CassandraRackStatus[dcRackName]
+	var partition int32
+	if dcRackStatus.UpgradePartition != nil {
+		partition = *dcRackStatus.UpgradePartition
+	}
+
+	//The pods already drained must run the new version before the next one is
+	if storedStatefulSet.Status.ObservedGeneration != storedStatefulSet.Generation ||
+		isStatefulSetNotReady(storedStatefulSet) ||
+		storedStatefulSet.Status.UpdatedReplicas < *storedStatefulSet.Spec.Replicas-partition {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName,
+			"partition": partition}).Info("Waiting for the drained pods to run the new version")
+		return false
+	}
+
+	if partition == 0 {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName}).Info("UpgradeCassandra is Done")
+		now := metav1.Now()
+		dcRackStatus.CassandraLastAction.Status = api.StatusDone
+		dcRackStatus.CassandraLastAction.EndTime = &now
+		dcRackStatus.UpgradePartition = nil
+		return true
+	}
+
+	podName := fmt.Sprintf("%s-%d", storedStatefulSet.Name, partition-1)
+	pod, err := rcc.GetPod(cc.Namespace, podName)
+	var jolokiaClient *JolokiaClient
+	if err == nil {
+		jolokiaClient, err = NewJolokiaClient(k8s.PodHostname(*pod), JolokiaPort, rcc,
+			cc.Spec.ImageJolokiaSecret, cc.Namespace)
+	}
+	if err == nil {
+		err = jolokiaClient.NodeDrain()
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName,
+			"pod": podName}).Errorf("Can't drain pod before upgrading it: %v", err)
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "rack": dcRackName,
+		"pod": podName}).Info("Pod drained, it is updated to the new version")
+	partition--
+	dcRackStatus.UpgradePartition = &partition
+	return true
+}
+
+// ReconcileUpgrade queues upgradesstables on every rack once all the nodes run the major version of Cassandra the
+// cluster is upgraded to
+func (rcc *CassandraClusterReconciler) ReconcileUpgrade(cc *api.CassandraCluster,
+	status *api.CassandraClusterStatus) error {
+	if status.UpgradeVersion == "" {
+		return nil
+	}
+	for _, dcRackStatus := range status.CassandraRackStatus {
+		if dcRackStatus.CassandraLastAction.Name == api.ActionUpgradeCassandra.Name &&
+			dcRackStatus.CassandraLastAction.Status != api.StatusDone {
+			return nil
+		}
+	}
+
+	podsList, err := rcc.ListCassandraClusterPods(cc)
+	if err != nil {
+		return err
+	}
+	major := cassandraMajorVersion(status.UpgradeVersion)
+	for _, pod := range podsList {
+		jolokiaClient, err := NewJolokiaClient(k8s.PodHostname(pod), JolokiaPort, rcc,
+			cc.Spec.ImageJolokiaSecret, cc.Namespace)
+		var releaseVersion string
+		if err == nil {
+			releaseVersion, err = jolokiaClient.ReleaseVersion()
+		}
+		if err != nil {
+			return err
+		}
+		if cassandraMajorVersion(releaseVersion) != major {
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "pod": pod.Name,
+				"releaseVersion": releaseVersion}).Info("Waiting for all the nodes to run the new version")
+			return nil
+		}
+	}
+
+	labels := map[string]string{"operation-name": api.OperationUpgradeSSTables}
+	if cc.Spec.AutoPilot {
+		labels["operation-status"] = api.StatusToDo
+	} else {
+		labels["operation-status"] = api.StatusManual
+	}
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+			rcc.addPodOperationLabels(cc, dcName, cc.GetRackName(dc, rack), labels)
+		}
+	}
+	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "version": status.UpgradeVersion}).
+		Info("All the nodes run the new version, their sstables are upgraded")
+	status.UpgradeVersion = ""
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandracluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// helperCreateUpgradePod creates a ready pod of the rack dc1-rack1 of cluster cassandra-demo
+func helperCreateUpgradePod(rcc *CassandraClusterReconciler, ordinal int) *v1.Pod {
+	pod := &v1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("cassandra-demo-dc1-rack1-%d", ordinal),
+			Namespace: "ns",
+			Labels: map[string]string{
+				"app":                                  "cassandracluster",
+				"cassandracluster":                     "cassandra-demo",
+				"cassandraclusters.db.orange.com.dc":   "dc1",
+				"cassandraclusters.db.orange.com.rack": "rack1",
+				"cluster":                              "k8s.pic",
+				"dc-rack":                              "dc1-rack1",
+			},
+		},
+	}
+	pod.Spec.Hostname = pod.Name
+	pod.Spec.Subdomain = "cassandra-demo"
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: cassandraContainerName, Ready: true}}
+	rcc.CreatePod(pod)
+	return pod
+}
+
+// helperMockUpgradeJolokia answers the jolokia requests sent to a node during an upgrade
+func helperMockUpgradeJolokia(t *testing.T, hostName, unreachableNodes, schemaVersions, releaseVersion string,
+	drained *[]string) {
+	httpmock.RegisterResponder("POST", JolokiaURL(hostName, jolokiaPort),
+		func(req *http.Request) (*http.Response, error) {
+			var execrequestdata execRequestData
+			if err := json.NewDecoder(req.Body).Decode(&execrequestdata); err != nil {
+				t.Error("Can't decode request received")
+			}
+			value := "null"
+			switch execrequestdata.Attribute {
+			case "JoiningNodes":
+				value = "[]"
+			case "UnreachableNodes":
+				value = unreachableNodes
+			case "SchemaVersions":
+				value = schemaVersions
+			case "ReleaseVersion":
+				value = `"` + releaseVersion + `"`
+			case "":
+				*drained = append(*drained, hostName)
+			}
+			return httpmock.NewStringResponse(200, fmt.Sprintf(`{"value": %s, "timestamp": 1528848808,
+				"status": 200}`, value)), nil
+		})
+}
+
+// helperRunningStatus returns the status of a cluster whose racks are running without action ongoing
+func helperRunningStatus(rcc *CassandraClusterReconciler, cc *api.CassandraCluster) *api.CassandraClusterStatus {
+	rcc.updateCassandraStatus(cc, cc.Status.DeepCopy())
+	status := cc.Status.DeepCopy()
+	for _, dcRackStatus := range status.CassandraRackStatus {
+		dcRackStatus.Phase = api.ClusterPhaseRunning.Name
+		dcRackStatus.CassandraLastAction.Status = api.StatusDone
+	}
+	return status
+}
+
+func TestCheckNonAllowedChangesMajorUpgrade(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := helperRunningStatus(rcc, cc)
+	pod := helperCreateUpgradePod(rcc, 0)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var drained []string
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `["10.244.3.8"]`,
+		`{"59adb24e-f3cd-3e02-97f0-5b395827453f": ["10.244.2.5"], "UNREACHABLE": ["10.244.3.8"]}`, "3.11.7",
+		&drained)
+
+	//The upgrade is refused while a node is unreachable
+	cc.Spec.CassandraImage = "cassandra:4.0.1"
+	assert.True(rcc.CheckNonAllowedChanges(cc, status))
+	rcc.updateCassandraStatus(cc, status)
+	assert.Equal("cassandra:3.11.7", cc.Spec.CassandraImage)
+	assert.Equal("", status.UpgradeVersion)
+
+	//A major version can't be downgraded
+	cc.Spec.CassandraImage = "cassandra:2.2.19"
+	assert.True(rcc.CheckNonAllowedChanges(cc, status))
+	rcc.updateCassandraStatus(cc, status)
+	assert.Equal("cassandra:3.11.7", cc.Spec.CassandraImage)
+
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `[]`,
+		`{"59adb24e-f3cd-3e02-97f0-5b395827453f": ["10.244.2.5", "10.244.3.8"]}`, "3.11.7", &drained)
+	cc.Spec.CassandraImage = "cassandra:4.0.1"
+	assert.False(rcc.CheckNonAllowedChanges(cc, status))
+	assert.Equal("cassandra:4.0.1", cc.Spec.CassandraImage)
+	assert.Equal("4.0.1", status.UpgradeVersion)
+	for _, dcRackName := range []string{"dc1-rack1", "dc1-rack2", "dc2-rack1"} {
+		assert.Equal(api.ActionUpgradeCassandra.Name, status.CassandraRackStatus[dcRackName].CassandraLastAction.Name)
+		assert.Equal(api.StatusToDo, status.CassandraRackStatus[dcRackName].CassandraLastAction.Status)
+		assert.Equal(cc.Spec.NodesPerRacks, *status.CassandraRackStatus[dcRackName].UpgradePartition)
+	}
+	assert.Empty(drained)
+}
+
+func TestUpgradeNextPod(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := helperRunningStatus(rcc, cc)
+	setUpgradeStatus(cc, status)
+	dcRackName := "dc1-rack1"
+	dcRackStatus := status.CassandraRackStatus[dcRackName]
+	dcRackStatus.CassandraLastAction.Status = api.StatusOngoing
+	pod := helperCreateUpgradePod(rcc, 0)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var drained []string
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `[]`, `{}`, "4.0.1", &drained)
+
+	//The statefulset only updates the pods once drained
+	sts, _ := generateCassandraStatefulSet(cc, status, "dc1", dcRackName, map[string]string{}, nil, nil)
+	assert.Equal(int32(1), *sts.Spec.UpdateStrategy.RollingUpdate.Partition)
+	sts.Status = appsv1.StatefulSetStatus{Replicas: 1, ReadyReplicas: 1}
+
+	assert.True(rcc.upgradeNextPod(cc, dcRackName, sts, status))
+	assert.Equal([]string{k8s.PodHostname(*pod)}, drained)
+	assert.Equal(int32(0), *dcRackStatus.UpgradePartition)
+	sts, _ = generateCassandraStatefulSet(cc, status, "dc1", dcRackName, map[string]string{}, nil, nil)
+	assert.Equal(int32(0), *sts.Spec.UpdateStrategy.RollingUpdate.Partition)
+
+	//The rack is upgraded once the drained pod runs the new version
+	sts.Status = appsv1.StatefulSetStatus{Replicas: 1, ReadyReplicas: 0}
+	assert.False(rcc.upgradeNextPod(cc, dcRackName, sts, status))
+	sts.Status = appsv1.StatefulSetStatus{Replicas: 1, ReadyReplicas: 1, UpdatedReplicas: 1}
+	assert.True(rcc.upgradeNextPod(cc, dcRackName, sts, status))
+	assert.Equal(api.StatusDone, dcRackStatus.CassandraLastAction.Status)
+	assert.Nil(dcRackStatus.UpgradePartition)
+	assert.Equal(1, len(drained))
+}
+
+func TestReconcileUpgrade(t *testing.T) {
+	assert := assert.New(t)
+
+	rcc, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	status := helperRunningStatus(rcc, cc)
+	cc.Spec.AutoPilot = true
+	cc.Spec.CassandraImage = "cassandra:4.0.1"
+	setUpgradeStatus(cc, status)
+	pod := helperCreateUpgradePod(rcc, 0)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var drained []string
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `[]`, `{}`, "3.11.7", &drained)
+
+	//The sstables are not upgraded while the racks are upgraded
+	assert.Nil(rcc.ReconcileUpgrade(cc, status))
+	assert.Equal("4.0.1", status.UpgradeVersion)
+
+	for _, dcRackStatus := range status.CassandraRackStatus {
+		dcRackStatus.CassandraLastAction.Status = api.StatusDone
+	}
+	//Nor while a node still runs the previous version
+	assert.Nil(rcc.ReconcileUpgrade(cc, status))
+	assert.Equal("4.0.1", status.UpgradeVersion)
+
+	helperMockUpgradeJolokia(t, k8s.PodHostname(*pod), `[]`, `{}`, "4.0.1", &drained)
+	assert.Nil(rcc.ReconcileUpgrade(cc, status))
+	assert.Equal("", status.UpgradeVersion)
+	pod, _ = rcc.GetPod(pod.Namespace, pod.Name)
+	assert.Equal(api.OperationUpgradeSSTables, pod.Labels["operation-name"])
+	assert.Equal(api.StatusToDo, pod.Labels["operation-status"])
+}
+
+func TestJvmOptionNameFollowsMajorVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	_, cc := HelperInitCluster(t, "cassandracluster-2DC.yaml")
+	assert.Equal("jvm-options", jvmOptionName(cc))
+	cc.Spec.CassandraImage = "cassandra:4.0.1"
+	assert.Equal("jvm-server-options", jvmOptionName(cc))
+	cc.Spec.ServerVersion = "3.11.9"
+	assert.Equal("jvm-options", jvmOptionName(cc))
+}
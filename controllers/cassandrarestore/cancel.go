@@ -0,0 +1,128 @@
+package cassandrarestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/controllers/common"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/Orange-OpenSource/casskop/pkg/util"
+	"github.com/sirupsen/logrus"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newProvider returns the provider aborting the operations of a restore. It is a variable so that tests can replace
+// it
+var newProvider = backrest.NewProvider
+
+// cancellation returns why the operations of a restore must be aborted, empty while they can go on
+func cancellation(restore *v2.CassandraRestore, timeout time.Duration) string {
+	switch {
+	case restore.Spec.Cancel:
+		return "restore was cancelled"
+	case timeout > 0 && time.Since(restore.CreationTimestamp.Time) > timeout:
+		return fmt.Sprintf("restore timed out after %s", timeout)
+	}
+	return ""
+}
+
+// cancelRestore aborts the running operations of a restore and records it as cancelled
+func (r *CassandraRestoreReconciler) cancelRestore(restore *v2.CassandraRestore, cc *v2.CassandraCluster,
+	backup *v2.CassandraBackup, reason string, reqLogger *logrus.Entry) error {
+	r.abortOperations(restore, cc, backup, reqLogger)
+
+	status := restore.Status.DeepCopy()
+	status.Condition = &v2.BackRestCondition{
+		Type:               string(v2.RestoreCancelled),
+		LastTransitionTime: v12.Now().Format(util.TimeStampLayout),
+		FailureCause:       []v2.FailureCause{{Source: restore.Status.CoordinatorMember, Message: reason}},
+	}
+	if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+		return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+	}
+	return r.setFinalizer(restore, false)
+}
+
+// abortOperations aborts the restore operation of the coordinator, or the commitlog-restore operations of each node
+// when the commitlogs are replayed. Operations which already ended can't be aborted, errors are only logged
+func (r *CassandraRestoreReconciler) abortOperations(restore *v2.CassandraRestore, cc *v2.CassandraCluster,
+	backup *v2.CassandraBackup, reqLogger *logrus.Entry) {
+	if restore.Status.Condition == nil {
+		return
+	}
+
+	pods, err := r.listPods(restore.Namespace, k8s.LabelsForCassandraDC(cc, backup.Spec.Datacenter))
+	if err != nil {
+		reqLogger.Error(err, "Unable to list pods to abort restore operations")
+		return
+	}
+
+	operations := map[string]string{}
+	conditionType := v2.RestoreConditionType(restore.Status.Condition.Type)
+	if conditionType.IsInProgress() && restore.Status.ID != "" {
+		operations[restore.Status.CoordinatorMember] = restore.Status.ID
+	} else if conditionType.IsReplayingCommitlogs() {
+		operations = restore.Status.CommitlogRestores
+	}
+
+	for podName, operationID := range operations {
+		logging := reqLogger.WithFields(logrus.Fields{"pod": podName, "operationId": operationID})
+		pod := k8s.PodByName(pods, podName)
+		if pod == nil {
+			logging.Info("Pod running restore operation not found")
+			continue
+		}
+		provider, err := newProvider(r.Client, cc, pod)
+		if err != nil {
+			logging.Error(err, "Unable to reach backrest sidecar")
+			continue
+		}
+		if err := provider.Abort(operationID); err != nil {
+			logging.Error(err, "Unable to abort restore operation")
+			continue
+		}
+		logging.Info("Restore operation aborted")
+	}
+}
+
+// deleteRestore aborts the running operations of a deleted restore before releasing it
+func (r *CassandraRestoreReconciler) deleteRestore(restore *v2.CassandraRestore,
+	reqLogger *logrus.Entry) (reconcile.Result, error) {
+	if len(restore.Finalizers) == 0 {
+		return common.Reconciled()
+	}
+
+	cc, err := k8s.LookupCassandraCluster(r.Client, restore.Spec.CassandraCluster, restore.Namespace)
+	if err == nil {
+		var backup *v2.CassandraBackup
+		if backup, err = k8s.LookupCassandraBackup(r.Client, restore.Spec.CassandraBackup,
+			restore.Namespace); err == nil {
+			r.abortOperations(restore, cc, backup, reqLogger)
+		}
+	}
+	if err != nil {
+		reqLogger.Info("Cluster or backup of the restore is gone, its operations can't be aborted")
+	}
+
+	if err := r.setFinalizer(restore, false); err != nil {
+		return common.RequeueWithError(reqLogger, err.Error(), err)
+	}
+	return common.Reconciled()
+}
+
+// setFinalizer adds or removes the finalizer keeping a restore until its running operations are aborted
+func (r *CassandraRestoreReconciler) setFinalizer(restore *v2.CassandraRestore, value bool) error {
+	if (len(restore.Finalizers) > 0) == value {
+		return nil
+	}
+	restore.PreventRestoreDeletion(value)
+	if _, err := r.updateAndFetchLatest(context.TODO(), restore); err != nil {
+		return errors.WrapIfWithDetails(err, "could not update finalizers of restore", "restore", restore)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cassandrarestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// abortProvider records the operations aborted
+type abortProvider struct {
+	backrest.BackupProvider
+	aborted *[]string
+}
+
+func (p abortProvider) Abort(id string) error {
+	*p.aborted = append(*p.aborted, id)
+	return nil
+}
+
+func TestCancellation(t *testing.T) {
+	assert := assert.New(t)
+
+	restore := &api.CassandraRestore{ObjectMeta: metav1.ObjectMeta{
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))}}
+	assert.Empty(cancellation(restore, 0))
+	assert.Empty(cancellation(restore, 2*time.Hour))
+	assert.Equal("restore timed out after 30m0s", cancellation(restore, 30*time.Minute))
+
+	restore.Spec.Cancel = true
+	assert.Equal("restore was cancelled", cancellation(restore, 2*time.Hour))
+}
+
+func TestCancelRestore(t *testing.T) {
+	assert := assert.New(t)
+	cassandraRestoreReconciler, cassandraRestore, _ := helperInitCassandraRestoreController(cassandraRestoreYaml)
+
+	var aborted []string
+	newProvider = func(client.Client, *api.CassandraCluster, *v1.Pod) (backrest.BackupProvider, error) {
+		return abortProvider{aborted: &aborted}, nil
+	}
+	defer func() { newProvider = backrest.NewProvider }()
+
+	cc := &api.CassandraCluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-dc1"}}
+	backup := &api.CassandraBackup{Spec: api.CassandraBackupSpec{Datacenter: "dc1"}}
+	for _, podName := range []string{"test-cluster-dc1-dc1-rack1-0", "test-cluster-dc1-dc1-rack1-1"} {
+		assert.Nil(cassandraRestoreReconciler.Client.Create(context.TODO(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: podName, Labels: k8s.LabelsForCassandraDC(cc, "dc1")}}))
+	}
+
+	cassandraRestore.Status = api.BackRestStatus{
+		CoordinatorMember: "test-cluster-dc1-dc1-rack1-0",
+		ID:                "restore-1",
+		Condition:         &api.BackRestCondition{Type: string(api.RestoreRunning)},
+	}
+	cassandraRestore.PreventRestoreDeletion(true)
+	reqLogger := logrus.WithFields(logrus.Fields{"restore": cassandraRestore.Name})
+
+	assert.Nil(cassandraRestoreReconciler.cancelRestore(cassandraRestore, cc, backup, "restore was cancelled",
+		reqLogger))
+	assert.Equal([]string{"restore-1"}, aborted)
+	assert.Equal(string(api.RestoreCancelled), cassandraRestore.Status.Condition.Type)
+	assert.Equal("restore was cancelled", cassandraRestore.Status.Condition.FailureCause[0].Message)
+	assert.Empty(cassandraRestore.Finalizers)
+
+	// While the commitlogs are replayed, the commitlog-restore operation of each node is aborted
+	aborted = nil
+	cassandraRestore.Status.Condition = &api.BackRestCondition{Type: string(api.RestoreReplayingCommitlogs)}
+	cassandraRestore.Status.CommitlogRestores = map[string]string{"test-cluster-dc1-dc1-rack1-0": "commitlogs-0",
+		"test-cluster-dc1-dc1-rack1-1": "commitlogs-1", "test-cluster-dc1-dc1-rack1-2": "commitlogs-2"}
+	cassandraRestoreReconciler.abortOperations(cassandraRestore, cc, backup, reqLogger)
+	assert.ElementsMatch([]string{"commitlogs-0", "commitlogs-1"}, aborted)
+}
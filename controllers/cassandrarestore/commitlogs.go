@@ -0,0 +1,218 @@
+package cassandrarestore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/backrest"
+	"github.com/Orange-OpenSource/casskop/pkg/errorfactory"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/Orange-OpenSource/casskop/pkg/util"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// restartCassandra stops Cassandra so that its container is restarted and replays the commitlogs downloaded. It is a
+// variable so that tests can replace it
+var restartCassandra = restartCassandraOnPod
+
+func restartCassandraOnPod(pod *v1.Pod) error {
+	k8s.InitClient()
+	_, stderr, err := k8s.ExecPod(pod.Namespace, pod, []string{"nodetool", "stopdaemon"})
+	if err != nil {
+		return fmt.Errorf("nodetool stopdaemon failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	return nil
+}
+
+// nodeMode returns the operation mode of the Cassandra node of a pod, e.g. NORMAL. It is a variable so that tests can
+// replace it
+var nodeMode = nodeModeOnPod
+
+func nodeModeOnPod(pod *v1.Pod) (string, error) {
+	k8s.InitClient()
+	stdout, stderr, err := k8s.ExecPod(pod.Namespace, pod, []string{"nodetool", "netstats"})
+	if err != nil {
+		return "", fmt.Errorf("nodetool netstats failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		if mode := strings.TrimPrefix(line, "Mode: "); mode != line {
+			return strings.TrimSpace(mode), nil
+		}
+	}
+	return "", fmt.Errorf("no mode in the output of nodetool netstats on pod %s", pod.Name)
+}
+
+// cassandraRestartCount returns the restart count of the cassandra container of a pod
+func cassandraRestartCount(pod *v1.Pod) (int32, bool) {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name == "cassandra" {
+			return containerStatus.RestartCount, containerStatus.Ready
+		}
+	}
+	return 0, false
+}
+
+// checkCommitlogReplay replays the commitlogs of each node up to the restore timestamp once the snapshot is restored.
+// A commitlog-restore operation first downloads the commitlogs of each node and configures their replay, then
+// Cassandra is restarted on the nodes one at a time to replay them
+func (r *CassandraRestoreReconciler) checkCommitlogReplay(restore *v2.CassandraRestore, cc *v2.CassandraCluster,
+	backup *v2.CassandraBackup, pods *v1.PodList, reqLogger *logrus.Entry) error {
+
+	status := restore.Status.DeepCopy()
+
+	if len(status.CommitlogRestores) == 0 {
+		restoreTime, _, err := restore.RestoreTime()
+		if err != nil {
+			return err
+		}
+
+		status.CommitlogRestores = map[string]string{}
+		for i := range pods.Items {
+			sr, err := backrest.NewCommitlogProvider(r.Client, cc, &pods.Items[i])
+			if err != nil {
+				return sidecarError(reqLogger, err)
+			}
+			operationID, err := sr.RestoreCommitlogs(restore, backup, restoreTime)
+			if err != nil {
+				return sidecarError(reqLogger, err)
+			}
+			status.CommitlogRestores[pods.Items[i].Name] = operationID
+		}
+
+		if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+			return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+		}
+		return errorfactory.New(errorfactory.CassandraBackupOperationRunning{},
+			errors.New("commitlog-restore operations submitted"), "commitlogs are being downloaded")
+	}
+
+	// The downloads are checked until the first node is restarted
+	if len(status.CommitlogReplays) == 0 {
+		for podName, operationID := range status.CommitlogRestores {
+			pod := k8s.PodByName(pods, podName)
+			if pod == nil {
+				return errorfactory.New(errorfactory.ResourceNotReady{}, errors.New("pod not found"),
+					fmt.Sprintf("pod %s replaying commitlogs not found", podName))
+			}
+
+			sr, err := backrest.NewProvider(r.Client, cc, pod)
+			if err != nil {
+				return sidecarError(reqLogger, err)
+			}
+			operationStatus, err := sr.RestoreStatus(operationID)
+			if err != nil {
+				return sidecarError(reqLogger, err)
+			}
+
+			conditionType := v2.RestoreConditionType(operationStatus.Condition.Type)
+			if conditionType.IsInError() {
+				status.Condition = operationStatus.Condition
+				if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+					return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+				}
+				errorMessage := ""
+				if len(operationStatus.Condition.FailureCause) > 0 {
+					errorMessage = operationStatus.Condition.FailureCause[0].Message
+				}
+				return errorfactory.New(errorfactory.CassandraBackupOperationFailure{}, errors.New(errorMessage),
+					fmt.Sprintf("Commitlog restore operation failed on pod %s", podName))
+			}
+
+			if !conditionType.IsCompleted() {
+				reqLogger.WithFields(logrus.Fields{"pod": podName, "operationId": operationID}).Info(
+					"Commitlogs are still being downloaded")
+				return errorfactory.New(errorfactory.CassandraBackupOperationRunning{},
+					errors.New("commitlog-restore operation still running"),
+					fmt.Sprintf("commitlog restore operation id : %s", operationID))
+			}
+		}
+	}
+
+	if err := r.replayCommitlogs(restore, status, pods, reqLogger); err != nil {
+		return err
+	}
+
+	status.Condition = &v2.BackRestCondition{
+		Type:               string(v2.RestoreCompleted),
+		LastTransitionTime: v12.Now().Format(util.TimeStampLayout),
+	}
+	if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+		return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+	}
+	return nil
+}
+
+// replayCommitlogs restarts Cassandra on the nodes one at a time so that they replay the commitlogs downloaded. The
+// progress of each node is recorded in the status of the restore: the restart count of its cassandra container when
+// Cassandra is stopped, then that it has replayed the commitlogs. Cassandra replays the commitlogs on start before
+// joining the ring, so a node has replayed them once its container was restarted, is ready and in NORMAL mode.
+// It returns nil once all the nodes have replayed their commitlogs
+func (r *CassandraRestoreReconciler) replayCommitlogs(restore *v2.CassandraRestore, status *v2.BackRestStatus,
+	pods *v1.PodList, reqLogger *logrus.Entry) error {
+	podNames := make([]string, 0, len(status.CommitlogRestores))
+	for podName := range status.CommitlogRestores {
+		podNames = append(podNames, podName)
+	}
+	sort.Strings(podNames)
+
+	if status.CommitlogReplays == nil {
+		status.CommitlogReplays = map[string]v2.CommitlogReplay{}
+	}
+	for _, podName := range podNames {
+		replay, restarted := status.CommitlogReplays[podName]
+		if replay.Replayed {
+			continue
+		}
+		pod := k8s.PodByName(pods, podName)
+		if pod == nil {
+			return errorfactory.New(errorfactory.ResourceNotReady{}, errors.New("pod not found"),
+				fmt.Sprintf("pod %s replaying commitlogs not found", podName))
+		}
+		restartCount, ready := cassandraRestartCount(pod)
+
+		if !restarted {
+			// The restart is recorded first so that Cassandra is not stopped again if the status can't be updated
+			status.CommitlogReplays[podName] = v2.CommitlogReplay{RestartCount: restartCount}
+			if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+				return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+			}
+			if err := restartCassandra(pod); err != nil {
+				delete(status.CommitlogReplays, podName)
+				if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+					reqLogger.WithFields(logrus.Fields{"pod": podName}).Error(err)
+				}
+				return err
+			}
+			reqLogger.WithFields(logrus.Fields{"pod": podName}).Info("Cassandra restarted to replay the commitlogs")
+			return errorfactory.New(errorfactory.CassandraBackupOperationRunning{},
+				errors.New("cassandra restarted"), fmt.Sprintf("pod %s is replaying its commitlogs", podName))
+		}
+
+		if restartCount <= replay.RestartCount || !ready {
+			return errorfactory.New(errorfactory.CassandraBackupOperationRunning{},
+				errors.New("cassandra not restarted yet"), fmt.Sprintf("pod %s is replaying its commitlogs", podName))
+		}
+		mode, err := nodeMode(pod)
+		if err != nil {
+			return errorfactory.New(errorfactory.ResourceNotReady{}, err,
+				fmt.Sprintf("mode of pod %s replaying commitlogs unknown", podName))
+		}
+		if mode != "NORMAL" {
+			return errorfactory.New(errorfactory.CassandraBackupOperationRunning{},
+				fmt.Errorf("cassandra in mode %s", mode), fmt.Sprintf("pod %s is replaying its commitlogs", podName))
+		}
+
+		replay.Replayed = true
+		status.CommitlogReplays[podName] = replay
+		if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+			return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+		}
+		reqLogger.WithFields(logrus.Fields{"pod": podName}).Info("Commitlogs replayed")
+	}
+	return nil
+}
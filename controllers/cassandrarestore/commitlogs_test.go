@@ -0,0 +1,84 @@
+package cassandrarestore
+
+import (
+	"errors"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/errorfactory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pkgerrors "emperror.dev/errors"
+)
+
+func cassandraPod(name string, restartCount int32, ready bool) v1.Pod {
+	return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: v1.PodStatus{
+		ContainerStatuses: []v1.ContainerStatus{{Name: "cassandra", RestartCount: restartCount, Ready: ready}}}}
+}
+
+func TestReplayCommitlogs(t *testing.T) {
+	assert := assert.New(t)
+	cassandraRestoreReconciler, cassandraRestore, _ := helperInitCassandraRestoreController(cassandraRestoreYaml)
+	reqLogger := logrus.WithFields(logrus.Fields{"restore": cassandraRestore.Name})
+
+	var restarted []string
+	restartCassandra = func(pod *v1.Pod) error {
+		restarted = append(restarted, pod.Name)
+		return nil
+	}
+	modes := map[string]string{}
+	nodeMode = func(pod *v1.Pod) (string, error) { return modes[pod.Name], nil }
+	defer func() {
+		restartCassandra = restartCassandraOnPod
+		nodeMode = nodeModeOnPod
+	}()
+
+	pods := &v1.PodList{Items: []v1.Pod{cassandraPod("cassandra-dc1-rack1-1", 0, true),
+		cassandraPod("cassandra-dc1-rack1-0", 2, true)}}
+	status := &api.BackRestStatus{CommitlogRestores: map[string]string{"cassandra-dc1-rack1-0": "commitlogs-0",
+		"cassandra-dc1-rack1-1": "commitlogs-1"}}
+	replayCommitlogs := func() error {
+		return cassandraRestoreReconciler.replayCommitlogs(cassandraRestore, status, pods, reqLogger)
+	}
+	assertRunning := func(err error) {
+		assert.IsType(errorfactory.CassandraBackupOperationRunning{}, pkgerrors.Cause(err))
+	}
+
+	// Cassandra is restarted on a single node
+	assertRunning(replayCommitlogs())
+	assert.Equal([]string{"cassandra-dc1-rack1-0"}, restarted)
+	assert.Equal(map[string]api.CommitlogReplay{"cassandra-dc1-rack1-0": {RestartCount: 2}},
+		cassandraRestore.Status.CommitlogReplays)
+
+	// It is not restarted again while its container has not been restarted or is not back in NORMAL mode
+	assertRunning(replayCommitlogs())
+	pods.Items[1] = cassandraPod("cassandra-dc1-rack1-0", 3, false)
+	assertRunning(replayCommitlogs())
+	pods.Items[1] = cassandraPod("cassandra-dc1-rack1-0", 3, true)
+	modes["cassandra-dc1-rack1-0"] = "STARTING"
+	assertRunning(replayCommitlogs())
+	assert.Equal([]string{"cassandra-dc1-rack1-0"}, restarted)
+
+	// The next node is restarted once the previous one has replayed its commitlogs
+	modes["cassandra-dc1-rack1-0"] = "NORMAL"
+	assertRunning(replayCommitlogs())
+	assert.Equal([]string{"cassandra-dc1-rack1-0", "cassandra-dc1-rack1-1"}, restarted)
+	assert.True(cassandraRestore.Status.CommitlogReplays["cassandra-dc1-rack1-0"].Replayed)
+
+	pods.Items[0] = cassandraPod("cassandra-dc1-rack1-1", 1, true)
+	modes["cassandra-dc1-rack1-1"] = "NORMAL"
+	assert.Nil(replayCommitlogs())
+	assert.Equal(map[string]api.CommitlogReplay{
+		"cassandra-dc1-rack1-0": {RestartCount: 2, Replayed: true},
+		"cassandra-dc1-rack1-1": {RestartCount: 0, Replayed: true}}, cassandraRestore.Status.CommitlogReplays)
+	assert.Len(restarted, 2)
+
+	// A node which could not be stopped is restarted on the next reconcile
+	restartCassandra = func(pod *v1.Pod) error { return errors.New("nodetool stopdaemon failed") }
+	status = &api.BackRestStatus{CommitlogRestores: map[string]string{"cassandra-dc1-rack1-0": "commitlogs-0"}}
+	assert.EqualError(replayCommitlogs(), "nodetool stopdaemon failed")
+	assert.Empty(cassandraRestore.Status.CommitlogReplays)
+}
@@ -61,6 +61,11 @@ func (r CassandraRestoreReconciler) Reconcile(request reconcile.Request) (reconc
 		return common.RequeueWithError(reqLogger, err.Error(), err)
 	}
 
+	// A restore deleted while its operations run is kept until they are aborted
+	if k8s.IsMarkedForDeletion(cassandraRestore.ObjectMeta) {
+		return r.deleteRestore(cassandraRestore, reqLogger)
+	}
+
 	// Check the referenced Cluster exists.
 	cassandraCluster := &v2.CassandraCluster{}
 	if cassandraCluster, err = k8s.LookupCassandraCluster(r.Client, cassandraRestore.Spec.CassandraCluster,
@@ -90,6 +95,25 @@ func (r CassandraRestoreReconciler) Reconcile(request reconcile.Request) (reconc
 		return common.RequeueWithError(reqLogger, "failed to lookup referenced cassandraBackup", err)
 	}
 
+	// Validate the restore timestamp if it's set
+	if _, replay, err := cassandraRestore.RestoreTime(); err != nil || replay && !cassandraCluster.Spec.CommitlogArchiving {
+		message := fmt.Sprintf("Restore timestamp %s can't be parsed", cassandraRestore.Spec.RestoreTimestamp)
+		if err == nil {
+			message = fmt.Sprintf("Commitlogs can't be replayed as cluster %s does not archive them",
+				cassandraCluster.Name)
+		}
+		r.Recorder.Event(cassandraRestore, v1.EventTypeWarning, "RestoreInvalidTimestamp", message)
+		return common.Reconciled()
+	}
+
+	// Validate the timeout if it's set
+	timeout, err := cassandraRestore.OperationTimeout()
+	if err != nil {
+		r.Recorder.Event(cassandraRestore, v1.EventTypeWarning, "RestoreInvalidTimeout",
+			fmt.Sprintf("Timeout %s can't be parsed", cassandraRestore.Spec.Timeout))
+		return common.Reconciled()
+	}
+
 	// Require restore
 	if len(cassandraRestore.Status.CoordinatorMember) == 0 {
 		err = r.requiredRestore(cassandraRestore, cassandraCluster, cassandraBackup, reqLogger)
@@ -112,6 +136,23 @@ func (r CassandraRestoreReconciler) Reconcile(request reconcile.Request) (reconc
 
 	restoreConditionType := v2.RestoreConditionType(cassandraRestore.Status.Condition.Type)
 
+	// Abort the restore when it is cancelled or timed out
+	if restoreConditionType.IsRequired() || restoreConditionType.IsInProgress() ||
+		restoreConditionType.IsReplayingCommitlogs() {
+		if reason := cancellation(cassandraRestore, timeout); reason != "" {
+			if err := r.cancelRestore(cassandraRestore, cassandraCluster, cassandraBackup, reason,
+				reqLogger); err != nil {
+				return common.RequeueWithError(reqLogger, err.Error(), err)
+			}
+			r.Recorder.Event(cassandraRestore,
+				v1.EventTypeWarning,
+				"RestoreCancelled",
+				r.restoreEventMessage(cassandraBackup, cassandraRestore.Spec.Datacenter,
+					fmt.Sprintf("Aborted as the %s", reason)))
+			return common.Reconciled()
+		}
+	}
+
 	if restoreConditionType.IsRequired() {
 		err = r.handleRequiredRestore(cassandraRestore, cassandraCluster, cassandraBackup, reqLogger)
 		if err != nil {
@@ -137,7 +178,7 @@ func (r CassandraRestoreReconciler) Reconcile(request reconcile.Request) (reconc
 		return common.Reconciled()
 	}
 
-	if restoreConditionType.IsInProgress() {
+	if restoreConditionType.IsInProgress() || restoreConditionType.IsReplayingCommitlogs() {
 		err = r.checkRestoreOperationState(cassandraRestore, cassandraCluster, cassandraBackup, reqLogger)
 		if err != nil {
 			switch errors.Cause(err).(type) {
@@ -150,6 +191,9 @@ func (r CassandraRestoreReconciler) Reconcile(request reconcile.Request) (reconc
 					RequeueAfter: time.Duration(20) * time.Second,
 				}, nil
 			case errorfactory.CassandraBackupOperationFailure:
+				if err := r.setFinalizer(cassandraRestore, false); err != nil {
+					return common.RequeueWithError(reqLogger, err.Error(), err)
+				}
 				r.Recorder.Event(cassandraRestore,
 					v1.EventTypeNormal,
 					"RestoreFailed",
@@ -159,6 +203,9 @@ func (r CassandraRestoreReconciler) Reconcile(request reconcile.Request) (reconc
 				return common.RequeueWithError(reqLogger, err.Error(), err)
 			}
 		}
+		if err := r.setFinalizer(cassandraRestore, false); err != nil {
+			return common.RequeueWithError(reqLogger, err.Error(), err)
+		}
 		r.Recorder.Event(cassandraRestore,
 			v1.EventTypeNormal,
 			"RestoreCompleted",
@@ -212,12 +259,17 @@ func (r *CassandraRestoreReconciler) handleRequiredRestore(restore *v2.Cassandra
 		return errorfactory.New(errorfactory.ResourceNotReady{}, err, "no pods founds for this dc")
 	}
 
-	sr, err := backrest.NewClient(r.Client, cc, k8s.PodByName(pods, restore.Status.CoordinatorMember))
+	sr, err := backrest.NewProvider(r.Client, cc, k8s.PodByName(pods, restore.Status.CoordinatorMember))
 	if err != nil {
 		return sidecarError(reqLogger, err)
 	}
 
-	restoreStatus, err := sr.PerformRestore(restore, backup)
+	snapshotTag, err := r.snapshotTag(restore, backup)
+	if err != nil {
+		return err
+	}
+
+	restoreStatus, err := sr.PerformRestore(restore, backup, snapshotTag)
 	if err != nil {
 		return sidecarError(reqLogger, err)
 	}
@@ -227,7 +279,36 @@ func (r *CassandraRestoreReconciler) handleRequiredRestore(restore *v2.Cassandra
 		return errors.WrapIfWithDetails(err, "Could not update status for restore", "restore", restore)
 	}
 
-	return nil
+	// The operation is aborted if the restore is deleted before it ends
+	return r.setFinalizer(restore, true)
+}
+
+// snapshotTag returns the snapshot to restore. For a scheduled backup, it is the one of the referenced run or of its
+// most recent completed run
+func (r *CassandraRestoreReconciler) snapshotTag(restore *v2.CassandraRestore,
+	backup *v2.CassandraBackup) (string, error) {
+	if restore.Spec.CassandraBackupRun != "" {
+		run, err := k8s.LookupCassandraBackupRun(r.Client, restore.Spec.CassandraBackupRun, restore.Namespace)
+		if err != nil {
+			return "", errors.WrapIfWithDetails(err, "Could not find backup run to restore",
+				"run", restore.Spec.CassandraBackupRun)
+		}
+		if run.Spec.CassandraBackup != backup.Name {
+			return "", fmt.Errorf("backup run %s does not belong to backup %s", run.Name, backup.Name)
+		}
+		return run.Spec.SnapshotTag, nil
+	}
+
+	if backup.IsScheduled() {
+		run, err := k8s.LatestCompletedCassandraBackupRun(r.Client, backup)
+		if err != nil {
+			return "", err
+		}
+		if run != nil {
+			return run.Spec.SnapshotTag, nil
+		}
+	}
+	return backup.LatestSnapshotTag(), nil
 }
 
 func sidecarError(reqLogger *logrus.Entry, err error) error {
@@ -244,13 +325,17 @@ func (r *CassandraRestoreReconciler) checkRestoreOperationState(restore *v2.Cass
 		return errorfactory.New(errorfactory.ResourceNotReady{}, err, "no pods founds for this dc")
 	}
 
+	if v2.RestoreConditionType(restore.Status.Condition.Type).IsReplayingCommitlogs() {
+		return r.checkCommitlogReplay(restore, cc, backup, pods, reqLogger)
+	}
+
 	restoreId := restore.Status.ID
 	if restoreId == "" {
 		return errors.New("no Restore operation id provided to be checked")
 	}
 
 	// Check Restore operation status
-	sr, err := backrest.NewClient(r.Client, cc, k8s.PodByName(pods, restore.Status.CoordinatorMember))
+	sr, err := backrest.NewProvider(r.Client, cc, k8s.PodByName(pods, restore.Status.CoordinatorMember))
 	if err != nil {
 		reqLogger.Info("cassandra backup sidecar communication error checking running Operation", "OperationId",
 			restoreId)
@@ -258,7 +343,7 @@ func (r *CassandraRestoreReconciler) checkRestoreOperationState(restore *v2.Cass
 			"Icarus sidecar communication error")
 	}
 
-	status, err := sr.RestoreStatusByID(restoreId)
+	status, err := sr.RestoreStatus(restoreId)
 	status.CoordinatorMember = restore.Status.CoordinatorMember
 
 	if err != nil {
@@ -268,6 +353,16 @@ func (r *CassandraRestoreReconciler) checkRestoreOperationState(restore *v2.Cass
 			"Icarus sidecar communication error")
 	}
 
+	// The commitlogs are replayed once the snapshot is restored
+	if _, replay, _ := restore.RestoreTime(); replay &&
+		v2.RestoreConditionType(status.Condition.Type).IsCompleted() {
+		status.Condition.Type = string(v2.RestoreReplayingCommitlogs)
+		if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
+			return errors.WrapIfWithDetails(err, "could not update status for restore", "restore", restore)
+		}
+		return r.checkCommitlogReplay(restore, cc, backup, pods, reqLogger)
+	}
+
 	if err := UpdateRestoreStatus(r.Client, restore, *status, reqLogger); err != nil {
 		return errors.WrapIfWithDetails(err, "could not update status for restore",
 			"restore", restore)
@@ -6,6 +6,7 @@ import (
 	"github.com/Orange-OpenSource/casskop/controllers/common"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -83,6 +84,8 @@ func helperInitCassandraRestoreController(cassandraRestoreYaml string) (*Cassand
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraClusterList{})
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraBackup{})
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraBackupList{})
+	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraBackupRun{})
+	fakeClientScheme.AddKnownTypes(api.GroupVersion, &api.CassandraBackupRunList{})
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &cassandraRestore)
 	fakeClientScheme.AddKnownTypes(api.GroupVersion, &cassandraRestoreList)
 
@@ -157,6 +160,33 @@ func TestCassandraRestoreWithUnknownCassandraBackup(t *testing.T) {
 			cassandraRestore.Spec.CassandraBackup))
 }
 
+func TestCassandraRestoreCanceledByPreviousVersion(t *testing.T) {
+	assert := assert.New(t)
+	cassandraRestoreReconciler, cassandraRestore, _ := helperInitCassandraRestoreController(cassandraRestoreYaml)
+
+	cassandraCluster := &api.CassandraCluster{ObjectMeta: metav1.ObjectMeta{
+		Name: cassandraRestore.Spec.CassandraCluster, Namespace: cassandraRestore.Namespace}}
+	cassandraBackup := common.HelperInitCassandraBackup(cassandraBackupYaml)
+	cassandraBackup.Namespace = cassandraRestore.Namespace
+	assert.Nil(cassandraRestoreReconciler.Client.Create(context.TODO(), cassandraCluster))
+	assert.Nil(cassandraRestoreReconciler.Client.Create(context.TODO(), &cassandraBackup))
+
+	// A restore cancelled by a previous version of the operator stays cancelled
+	cassandraRestore.Status = api.BackRestStatus{CoordinatorMember: "test-cluster-dc1-dc1-rack1-0", ID: "restore-1",
+		Condition: &api.BackRestCondition{Type: string(api.RestoreCanceled)}}
+	assert.Nil(cassandraRestoreReconciler.Client.Update(context.TODO(), cassandraRestore))
+
+	namespacedName := types.NamespacedName{Name: cassandraRestore.Name, Namespace: cassandraRestore.Namespace}
+	res, err := cassandraRestoreReconciler.Reconcile(reconcile.Request{NamespacedName: namespacedName})
+	assert.Nil(err)
+	assert.Equal(reconcile.Result{}, res)
+
+	restore := &api.CassandraRestore{}
+	assert.Nil(cassandraRestoreReconciler.Client.Get(context.TODO(), namespacedName, restore))
+	assert.Equal(string(api.RestoreCanceled), restore.Status.Condition.Type)
+	assert.True(api.RestoreConditionType(restore.Status.Condition.Type).IsInError())
+}
+
 func TestCassandraRestoreWithNilStatusCondition(t *testing.T) {
 	assert := assert.New(t)
 	assert.True(true)
@@ -181,3 +211,43 @@ func TestCassandraRestorePhaseInProgress(t *testing.T) {
 	assert := assert.New(t)
 	assert.True(true)
 }
+
+func TestCassandraRestoreSnapshotTag(t *testing.T) {
+	assert := assert.New(t)
+	cassandraRestoreReconciler, cassandraRestore, _ := helperInitCassandraRestoreController(cassandraRestoreYaml)
+
+	cassandraBackup := common.HelperInitCassandraBackup(cassandraBackupYaml)
+	snapshotTag, err := cassandraRestoreReconciler.snapshotTag(cassandraRestore, &cassandraBackup)
+	assert.Nil(err)
+	assert.Equal("SnapshotTag2", snapshotTag)
+
+	// Without a completed run, the snapshot tag of the backup is used
+	cassandraBackup.Spec.Schedule = "@daily"
+	snapshotTag, err = cassandraRestoreReconciler.snapshotTag(cassandraRestore, &cassandraBackup)
+	assert.Nil(err)
+	assert.Equal("SnapshotTag2", snapshotTag)
+
+	cassandraBackup.Spec.Retention = &api.BackupRetention{KeepLast: 3}
+	for day := 13; day <= 15; day++ {
+		runTime := time.Date(2021, 3, day, 0, 0, 0, 0, time.UTC)
+		run := api.NewCassandraBackupRun(&cassandraBackup, cassandraBackup.RunSnapshotTag(runTime), runTime)
+		if day != 15 {
+			run.Status.Condition = &api.BackRestCondition{Type: string(api.BackupCompleted)}
+		}
+		assert.Nil(cassandraRestoreReconciler.Client.Create(context.TODO(), run))
+	}
+
+	snapshotTag, err = cassandraRestoreReconciler.snapshotTag(cassandraRestore, &cassandraBackup)
+	assert.Nil(err)
+	assert.Equal("SnapshotTag2-20210314-000000", snapshotTag)
+
+	cassandraRestore.Namespace = cassandraBackup.Namespace
+	cassandraRestore.Spec.CassandraBackupRun = "test-cassandra-backup-20210313-000000"
+	snapshotTag, err = cassandraRestoreReconciler.snapshotTag(cassandraRestore, &cassandraBackup)
+	assert.Nil(err)
+	assert.Equal("SnapshotTag2-20210313-000000", snapshotTag)
+
+	cassandraRestore.Spec.CassandraBackupRun = "unknown-run"
+	_, err = cassandraRestoreReconciler.snapshotTag(cassandraRestore, &cassandraBackup)
+	assert.NotNil(err)
+}
@@ -11,7 +11,7 @@ import (
 func UpdateRestoreStatus(c client.Client, restore *api.CassandraRestore, status api.BackRestStatus,
 	reqLogger *logrus.Entry) error {
 	patch := client.MergeFrom(restore.DeepCopy())
-	restore.Status = status
+	restore.Status = *status.DeepCopy()
 
 	if err := c.Patch(context.Background(), restore, patch); err != nil {
 			return errors.WrapIfWithDetails(err, "could not update status for restore",
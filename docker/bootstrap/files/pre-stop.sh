@@ -0,0 +1,43 @@
+#!/bin/bash
+#
+# Copyright 2019 Orange
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Add Jolokia credentials, if defined in environment.
+if [[ -n $JOLOKIA_USER ]] && [[ -n $JOLOKIA_PASSWORD ]]; then
+    USER_OPT="--user $JOLOKIA_USER:$JOLOKIA_PASSWORD"
+fi
+
+# We stop serving clients and leave gossip before flushing the memtables, so that the node does not have to replay
+# its commitlog when it restarts
+CURL="/opt/bin/curl $USER_OPT -s --connect-timeout 0.5"
+BASE_CMD="http://$POD_IP:8778/jolokia"
+MBEAN="org.apache.cassandra.db:type=StorageService"
+
+for operation in stopNativeTransport stopGossiping drain; do
+  [[ $DEBUG ]] && echo $operation
+  $CURL ${BASE_CMD}/exec/${MBEAN}/$operation > /dev/null
+done
+
+# The drain may still run if the request was cut
+for i in $(seq 1 60); do
+  if $CURL ${BASE_CMD}/read/${MBEAN}/OperationMode | grep -q DRAINED; then
+    [[ $DEBUG ]] && echo Drained
+    exit 0
+  fi
+  sleep 1
+done
+
+[[ $DEBUG ]] && echo Not Drained
+exit 1
@@ -69,13 +69,30 @@ echo Starting Cassandra on ${CASSANDRA_LISTEN_ADDRESS}
 echo Configuration used :
 set|grep CASSANDRA
 
+# CassKop gives the pod which replaces a dead node its address in a file named after the pod. A node already
+# bootstrapped ignores the address, only the pod which lost its data replaces the node
+REPLACE_NODE_FILE=/replace-nodes/$(hostname)
+if [ -z "$CASSANDRA_REPLACE_NODE" ] && [ -f "$REPLACE_NODE_FILE" ]
+then
+   CASSANDRA_REPLACE_NODE=$(cat "$REPLACE_NODE_FILE")
+fi
+
 if [ -n "$CASSANDRA_REPLACE_NODE" ]
 then
-   echo "-Dcassandra.replace_address=$CASSANDRA_REPLACE_NODE/" >> "$CASSANDRA_CONF/jvm.options"
+   echo "JVM_OPTS=\"\$JVM_OPTS -Dcassandra.replace_address_first_boot=$CASSANDRA_REPLACE_NODE\"" >> "$CASSANDRA_CONF/cassandra-env.sh"
 fi
 
 sed -ri 's/- class_name: .*/- class_name: '"$CASSANDRA_SEED_PROVIDER"'/' $CASSANDRA_CFG
 
+# CassKop puts a placeholder in place of the password of the TLS keystores, it is read from a Secret. It is written
+# as a single quoted YAML string, where a quote is doubled
+if [ -n "$KEYSTORE_PASSWORD" ]
+then
+   KEYSTORE_PASSWORD_YAML="'$(printf '%s' "$KEYSTORE_PASSWORD" | sed "s/'/''/g")'"
+   KEYSTORE_PASSWORD_SED=$(printf '%s' "$KEYSTORE_PASSWORD_YAML" | sed 's/[&|\\]/\\&/g')
+   sed -i "s|[\"']\?__KEYSTORE_PASSWORD__[\"']\?|$KEYSTORE_PASSWORD_SED|g" $CASSANDRA_CFG
+fi
+
 if [[ $CASSANDRA_ENABLE_JOLOKIA == 'true' ]]
 then
   JAVA_AGENT="-javaagent:/extra-lib/jolokia-agent.jar=host=0.0.0.0,executor=fixed"
@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"strconv"
 	"strings"
 
@@ -50,8 +51,9 @@ var (
 )
 
 const (
-	logLevelEnvVar     = "LOG_LEVEL"
-	resyncPeriodEnvVar = "RESYNC_PERIOD"
+	logLevelEnvVar       = "LOG_LEVEL"
+	resyncPeriodEnvVar   = "RESYNC_PERIOD"
+	enableWebhooksEnvVar = "ENABLE_WEBHOOKS"
 )
 
 //to be set by compilator with -ldflags "-X main.compileDate=`date -u +.%Y%m%d.%H%M%S`"
@@ -64,6 +66,7 @@ func printVersion() {
 	logrus.Infof("casskop Compilation Date: %s", compileDate)
 	logrus.Infof("casskop LogLevel: %v", getLogLevel())
 	logrus.Infof("casskop ResyncPeriod: %v", getResyncPeriod())
+	logrus.Infof("casskop Webhooks enabled: %v", webhooksEnabled())
 }
 
 func getLogLevel() logrus.Level {
@@ -99,6 +102,11 @@ func getResyncPeriod() int {
 	return resyncPeriod
 }
 
+func webhooksEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(enableWebhooksEnvVar))
+	return enabled
+}
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
@@ -169,9 +177,10 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&cassandracluster.CassandraClusterReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("CassandraCluster"),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("CassandraCluster"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("cassandracluster-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CassandraCluster")
 		os.Exit(1)
@@ -184,6 +193,15 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "CassandraRestore")
 		os.Exit(1)
 	}
+	// Webhooks need a certificate in /tmp/k8s-webhook-server/serving-certs, see config/certmanager
+	if webhooksEnabled() {
+		if err = (&api.CassandraCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CassandraCluster")
+			os.Exit(1)
+		}
+		mgr.GetWebhookServer().Register(cassandracluster.ValidatingWebhookPath, &webhook.Admission{
+			Handler: &cassandracluster.CassandraClusterValidator{Client: mgr.GetClient()}})
+	}
 	// +kubebuilder:scaffold:builder
 
 	logrus.Info("Starting the Cmd.")
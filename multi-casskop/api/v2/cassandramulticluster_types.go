@@ -1,6 +1,8 @@
 package v2
 
 import (
+	"time"
+
 	apicc "github.com/Orange-OpenSource/casskop/api/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -14,17 +16,180 @@ type MultiCasskopSpec struct {
 	DeleteCassandraCluster *bool                             `json:"deleteCassandraCluster,omitempty"`
 	Base                   apicc.CassandraCluster            `json:"base,omitempty"`
 	Override               map[string]apicc.CassandraCluster `json:"override,omitempty"`
+	// RolloutStrategy defines the order and the pace at which the CassandraClusters of the kubernetes contexts are
+	// created and updated
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+	// AutoUpdateSeedList makes MultiCasskop compute the seed list of all the CassandraClusters from the seeds of each
+	// of them, and keep it up to date when their topology changes
+	AutoUpdateSeedList bool `json:"autoUpdateSeedList,omitempty"`
+	// Maximum number of seeds of each DC in the seed list computed with autoUpdateSeedList, 3 by default
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3
+	MaxSeedsPerDC int32 `json:"maxSeedsPerDC,omitempty"`
+}
+
+// DefaultMaxSeedsPerDC is the number of seeds of each DC in the seed list when none is set, the one of CassKop
+const DefaultMaxSeedsPerDC = 3
+
+// SeedsPerDC returns the maximum number of seeds of each DC in the seed list
+func (spec *MultiCasskopSpec) SeedsPerDC() int {
+	if spec.MaxSeedsPerDC < 1 {
+		return DefaultMaxSeedsPerDC
+	}
+	return int(spec.MaxSeedsPerDC)
+}
+
+// AnnotationRolloutPaused is the annotation of a MultiCasskop which, when set to "true", closes the gate of its
+// rollout: no other CassandraCluster is created or updated until it is removed
+const AnnotationRolloutPaused = "multicasskop.db.orange.com/rollout-paused"
+
+// DefaultMaxConcurrentClusters is the number of CassandraClusters rolled out at the same time when none is set
+const DefaultMaxConcurrentClusters = 1
+
+// RolloutStrategy defines how the changes of a MultiCasskop are rolled out to its kubernetes contexts
+type RolloutStrategy struct {
+	// Kubernetes contexts in the order their CassandraCluster is rolled out. The contexts of the override section
+	// not listed are rolled out afterwards, in the order of the clients of multi-casskop
+	Order []string `json:"order,omitempty"`
+	// Maximum number of CassandraClusters created, updated or not ready at the same time, 1 by default
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrentClusters int32 `json:"maxConcurrentClusters,omitempty"`
+	// Duration to wait once a CassandraCluster is rolled out before rolling out the next one, e.g. 10m. See
+	// https://golang.org/pkg/time/#ParseDuration for the supported units
+	PauseBetweenClusters string `json:"pauseBetweenClusters,omitempty"`
+}
+
+// MaxConcurrent returns the maximum number of CassandraClusters rolled out at the same time
+func (strategy *RolloutStrategy) MaxConcurrent() int {
+	if strategy == nil || strategy.MaxConcurrentClusters < 1 {
+		return DefaultMaxConcurrentClusters
+	}
+	return int(strategy.MaxConcurrentClusters)
+}
+
+// Pause returns the duration to wait between the rollout of two CassandraClusters
+func (strategy *RolloutStrategy) Pause() (time.Duration, error) {
+	if strategy == nil || strategy.PauseBetweenClusters == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(strategy.PauseBetweenClusters)
+}
+
+// OrderContexts returns the kubernetes contexts in the order of the strategy, followed by the other ones in their
+// given order
+func (strategy *RolloutStrategy) OrderContexts(contexts []string) []string {
+	var ordered []string
+	if strategy != nil {
+		for _, name := range strategy.Order {
+			for _, context := range contexts {
+				if context == name {
+					ordered = append(ordered, context)
+					break
+				}
+			}
+		}
+	}
+	for _, context := range contexts {
+		if !containsString(ordered, context) {
+			ordered = append(ordered, context)
+		}
+	}
+	return ordered
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RolloutPaused returns true when the gate of the rollout is closed by its annotation
+func (cmc *MultiCasskop) RolloutPaused() bool {
+	return cmc.Annotations[AnnotationRolloutPaused] == "true"
+}
+
+// RolloutState is the state of the rollout of the CassandraCluster of a kubernetes context
+type RolloutState string
+
+const (
+	// RolloutPending means the CassandraCluster waits for its turn to be created or updated
+	RolloutPending RolloutState = "Pending"
+	// RolloutInProgress means the CassandraCluster was created or updated and is not ready yet
+	RolloutInProgress RolloutState = "RollingOut"
+	// RolloutDone means the CassandraCluster is up to date and ready
+	RolloutDone RolloutState = "Done"
+)
+
+const (
+	//List of Conditions of the MultiCasskop status
+	ConditionReady       string = "Ready"       // The CassandraClusters of all the contexts are ready and up to date
+	ConditionProgressing string = "Progressing" // A CassandraCluster is rolling out or waits for its rollout
+	ConditionDegraded    string = "Degraded"    // A CassandraCluster misses ready nodes or can't be reconciled
+)
+
 // MultiCasskopStatus defines the observed state of MultiCasskop
 // +k8s:openapi-gen=true
 type MultiCasskopStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
-	// Add custom validation using kubebuilder tags: https://book.kubebuilder.io/beyond_basics/generating_crd.html
+	// Status of the CassandraCluster of each kubernetes context, in rollout order
+	Contexts []ContextStatus `json:"contexts,omitempty"`
+	// Number of ready Cassandra nodes in all the contexts
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+	// Number of Cassandra nodes in all the contexts
+	Nodes int32 `json:"nodes,omitempty"`
+	// Conditions of the global ring, computed from the status of each context
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// Generation of the MultiCasskop the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// ContextStatus defines the observed state of the CassandraCluster of a kubernetes context
+type ContextStatus struct {
+	// Name of the kubernetes context
+	Name string `json:"name"`
+	// State of the rollout of the CassandraCluster: Pending, RollingOut or Done
+	Rollout RolloutState `json:"rollout,omitempty"`
+	// Last time the rollout of the CassandraCluster ended
+	RolledOutTime *metav1.Time `json:"rolledOutTime,omitempty"`
+	// Last time the rollout state changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Phase of the CassandraCluster
+	Phase string `json:"phase,omitempty"`
+	// Last action of the CassandraCluster and its status
+	LastClusterAction       string `json:"lastClusterAction,omitempty"`
+	LastClusterActionStatus string `json:"lastClusterActionStatus,omitempty"`
+	// Number of ready Cassandra nodes of the CassandraCluster
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+	// Number of Cassandra nodes of the CassandraCluster
+	Nodes int32 `json:"nodes,omitempty"`
+	// Hash of the last spec applied to the CassandraCluster
+	AppliedSpecHash string `json:"appliedSpecHash,omitempty"`
+	// Last error met creating or updating the CassandraCluster, empty once it succeeds
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ContextStatus returns the status of a kubernetes context, nil if it has none
+func (status *MultiCasskopStatus) ContextStatus(name string) *ContextStatus {
+	for i := range status.Contexts {
+		if status.Contexts[i].Name == name {
+			return &status.Contexts[i]
+		}
+	}
+	return nil
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Progressing",type="string",JSONPath=".status.conditions[?(@.type==\"Progressing\")].status"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+// +kubebuilder:printcolumn:name="Ready Nodes",type="integer",JSONPath=".status.readyNodes"
+// +kubebuilder:printcolumn:name="Nodes",type="integer",JSONPath=".status.nodes"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // MultiCasskop is the Schema for the MultiCasskops API
 // +k8s:openapi-gen=true
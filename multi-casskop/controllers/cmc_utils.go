@@ -22,12 +22,16 @@ func (r *reconciler) ReadyCassandraCluster(cc *ccv1.CassandraCluster) bool {
 // CreateOrUpdateCassandraCluster
 // create CassandraCluster object in target kubernetes cluster if not exists
 // update it if it already exist
+// with dryRun, it only returns whether the CassandraCluster would be created or updated
 func (r *reconciler) CreateOrUpdateCassandraCluster(client *Client,
-	cc *ccv1.CassandraCluster) (bool, *ccv1.CassandraCluster, error) {
+	cc *ccv1.CassandraCluster, dryRun bool) (bool, *ccv1.CassandraCluster, error) {
 	storedCC := &ccv1.CassandraCluster{}
 
 	if err := client.Client.Get(context.TODO(), r.namespacedName(cc.Name, cc.Namespace), storedCC); err != nil {
 		if errors.IsNotFound(err) {
+			if dryRun {
+				return true, cc, nil
+			}
 			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
 				"kubernetes": client.Name}).Debug("CassandraCluster does not exist, we create it ")
 			newCC, err := r.CreateCassandraCluster(client, cc)
@@ -36,7 +40,7 @@ func (r *reconciler) CreateOrUpdateCassandraCluster(client *Client,
 		return false, storedCC, err
 	}
 
-	needUpdate := false
+	needUpdate, needStatusUpdate := false, false
 
 	UnsetRollingRestart(storedCC)
 
@@ -63,16 +67,24 @@ func (r *reconciler) CreateOrUpdateCassandraCluster(client *Client,
 	//Multi-CassKop manages the Seedlist, we ensure that managed Casskop won't deal with it
 	cc.Spec.AutoUpdateSeedList = false
 
+	if r.cmc.Spec.AutoUpdateSeedList {
+		cc.Status.SeedList = mergeSeedList(storedCC.Status.SeedList, cc.Status.SeedList)
+	}
+
 	if cc.Status.SeedList != nil &&
 		!apiequality.Semantic.DeepEqual(storedCC.Status.SeedList, cc.Status.SeedList) {
 		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace, "kubernetes": client.Name}).
 			Info("SeedList is different: " + pretty.Compare(storedCC.Status.SeedList, cc.Status.SeedList))
 		storedCC.Status.SeedList = cc.Status.SeedList
-		needUpdate = true
+		needStatusUpdate = true
 	}
 
-	if needUpdate {
-		newCC, err := r.UpdateCassandraCluster(client, storedCC)
+	if (needUpdate || needStatusUpdate) && dryRun {
+		return true, storedCC, nil
+	}
+
+	if needUpdate || needStatusUpdate {
+		newCC, err := r.UpdateCassandraCluster(client, storedCC, needUpdate, needStatusUpdate)
 		return true, newCC, err
 	}
 	return false, storedCC, nil
@@ -102,15 +114,25 @@ func (r *reconciler) CreateCassandraCluster(client *Client,
 	return cc, err
 }
 
+// UpdateCassandraCluster
+// update the spec of a CassandraCluster when it changed and its status, which is a subresource ignored by Update,
+// when it changed
 func (r *reconciler) UpdateCassandraCluster(client *Client,
-	cc *ccv1.CassandraCluster) (*ccv1.CassandraCluster, error) {
-	var err error
+	cc *ccv1.CassandraCluster, specChanged, statusChanged bool) (*ccv1.CassandraCluster, error) {
 	logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace, "kubernetes": client.Name}).Debug(
 		"Update CassandraCluster")
-	if err = client.Client.Update(context.TODO(), cc); err != nil {
-		if errors.IsAlreadyExists(err) {
-			return cc, nil
+	if specChanged {
+		status := cc.Status.DeepCopy()
+		if err := client.Client.Update(context.TODO(), cc); err != nil {
+			if errors.IsAlreadyExists(err) {
+				return cc, nil
+			}
+			return cc, err
 		}
+		cc.Status = *status
 	}
-	return cc, err
+	if statusChanged {
+		return cc, client.Client.Status().Update(context.TODO(), cc)
+	}
+	return cc, nil
 }
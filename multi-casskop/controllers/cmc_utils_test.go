@@ -0,0 +1,122 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	ccv1 "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// statusSubresourceClient is a fake client which, as the API server, ignores the status of a CassandraCluster on
+// Update and only saves it through Status().Update
+type statusSubresourceClient struct {
+	client.Client
+}
+
+func (c statusSubresourceClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	cc, ok := obj.(*ccv1.CassandraCluster)
+	if !ok {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+	storedCC := &ccv1.CassandraCluster{}
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: cc.Name, Namespace: cc.Namespace}, storedCC); err != nil {
+		return err
+	}
+	cc.Status = storedCC.Status
+	return c.Client.Update(ctx, cc, opts...)
+}
+
+func (c statusSubresourceClient) Status() client.StatusWriter {
+	return statusSubresourceWriter{c.Client}
+}
+
+type statusSubresourceWriter struct {
+	client.Client
+}
+
+func (w statusSubresourceWriter) Update(ctx context.Context, obj runtime.Object,
+	opts ...client.UpdateOption) error {
+	cc, ok := obj.(*ccv1.CassandraCluster)
+	if !ok {
+		return w.Client.Status().Update(ctx, obj, opts...)
+	}
+	storedCC := &ccv1.CassandraCluster{}
+	if err := w.Client.Get(ctx, types.NamespacedName{Name: cc.Name, Namespace: cc.Namespace}, storedCC); err != nil {
+		return err
+	}
+	storedCC.Status = cc.Status
+	if err := w.Client.Update(ctx, storedCC, opts...); err != nil {
+		return err
+	}
+	cc.ResourceVersion = storedCC.ResourceVersion
+	return nil
+}
+
+func (w statusSubresourceWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	return w.Client.Status().Patch(ctx, obj, patch, opts...)
+}
+
+func TestCreateOrUpdateCassandraClusterSeedList(t *testing.T) {
+	assert := assert.New(t)
+
+	fakeClientScheme := scheme.Scheme
+	fakeClientScheme.AddKnownTypes(ccv1.GroupVersion, &ccv1.CassandraCluster{})
+	fakeClientScheme.AddKnownTypes(ccv1.GroupVersion, &ccv1.CassandraClusterList{})
+
+	dc := ccv1.DC{Name: "dc-paris", Rack: ccv1.RackSlice{{Name: "rack1"}}}
+	storedCC := seedListCluster(dc)
+	storedCC.Status.SeedList = []string{"cassandra-e2e-dc-paris-rack1-0.cassandra-e2e.ns"}
+	r := helperInitReconciler(nil, "paris")
+	r.clients.Local.Client = statusSubresourceClient{
+		fake.NewFakeClientWithScheme(fakeClientScheme, []runtime.Object{storedCC}...)}
+	seedList := []string{"cassandra-e2e-dc-lyon-rack1-0.cassandra-e2e.ns",
+		"cassandra-e2e-dc-paris-rack1-0.cassandra-e2e.ns"}
+
+	tests := []struct {
+		name       string
+		nodes      int32
+		seedList   []string
+		update     bool
+		wantedSeed []string
+	}{
+		{"Only the seed list changes", 0, seedList, true, seedList},
+		{"Nothing changes", 0, seedList, false, seedList},
+		{"The spec and the seed list change", 3, seedList[1:], true, seedList[1:]},
+		{"Only the spec changes", 1, nil, true, seedList[1:]},
+	}
+	for _, tt := range tests {
+		cc := seedListCluster(dc)
+		cc.Spec.NodesPerRacks = tt.nodes
+		cc.Status.SeedList = tt.seedList
+		update, _, err := r.CreateOrUpdateCassandraCluster(r.clients.Local, cc, false)
+		assert.Nil(err, tt.name)
+		assert.Equal(tt.update, update, tt.name)
+
+		storedCC := &ccv1.CassandraCluster{}
+		assert.Nil(r.clients.Local.Client.Get(context.TODO(), r.namespacedName(cc.Name, cc.Namespace), storedCC),
+			tt.name)
+		assert.Equal(tt.nodes, storedCC.Spec.NodesPerRacks, tt.name)
+		assert.Equal(tt.wantedSeed, storedCC.Status.SeedList, tt.name)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	cmcv1 "github.com/Orange-OpenSource/casskop/multi-casskop/api/v2"
 	"github.com/imdario/mergo"
 	"github.com/sirupsen/logrus"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -129,13 +130,29 @@ func (r *reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 		return requeue, err
 	}
 
-	// For all clients (local & remotes)
-	clients := r.clients.FlatClients()
-	for _, client := range clients {
+	pause, err := r.cmc.Spec.RolloutStrategy.Pause()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"multicasskop": r.cmc.Name}).Errorf(
+			"pauseBetweenClusters %s can't be parsed: %v", r.cmc.Spec.RolloutStrategy.PauseBetweenClusters, err)
+		return forget, nil
+	}
+
+	// For all clients (local & remotes), in rollout order. A CassandraCluster is created or updated once the ones of
+	// the previous contexts are, as long as the rollout strategy lets it
+	status := &cmcv1.MultiCasskopStatus{}
+	lastRolledOut := lastRolledOutTime(&r.cmc.Status)
+	rollingOut := 0
+	blocked := false
+	var reconcileErr error
+	var seedList []string
+	if r.cmc.Spec.AutoUpdateSeedList {
+		seedList = r.globalSeedList()
+	}
+	for _, client := range r.rolloutClients() {
 		var cc *ccv1.CassandraCluster
 		var found bool
 		if found, cc = r.computeCassandraClusterForContext(client); !found {
-            continue
+			continue
 		}
 
 		// If deletion is asked
@@ -144,25 +161,59 @@ func (r *reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 			continue
 		}
 
-		update, storedCC, err := r.CreateOrUpdateCassandraCluster(client, cc)
+		// Multi-CassKop computes the seed list of all the CassandraClusters, their CassKop must not compute theirs
+		if r.cmc.Spec.AutoUpdateSeedList {
+			cc.Spec.AutoUpdateSeedList = false
+			cc.Status.SeedList = seedList
+		}
+
+		blocker := r.rolloutBlocker(rollingOut, lastRolledOut, pause)
+		if blocked && blocker == "" {
+			blocker = "the CassandraCluster of a previous context waits for its rollout"
+		}
+
+		previous := r.cmc.Status.ContextStatus(client.Name)
+		update, storedCC, err := r.CreateOrUpdateCassandraCluster(client, cc, blocker != "")
 		if err != nil {
 			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
 				"kubernetes": client.Name}).Errorf("error on CassandraCluster %v", err)
-			return requeue5, err
-		}
-		if update {
-			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
-				"kubernetes": client.Name}).Infof("CassandraCluster created/updated, returning for now..")
-			return requeue30, err
+			// The next contexts wait until the CassandraCluster of this one can be reconciled
+			state := cmcv1.RolloutPending
+			if previous != nil {
+				state = previous.Rollout
+			}
+			setRollout(status, previous, client.Name, state).LastError = err.Error()
+			blocked = true
+			reconcileErr = err
+			continue
 		}
 
-		if !r.ReadyCassandraCluster(storedCC) {
+		var contextStatus *cmcv1.ContextStatus
+		switch {
+		case update && blocker != "":
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
+				"kubernetes": client.Name}).Infof("CassandraCluster waits for its rollout as %s", blocker)
+			contextStatus = setRollout(status, previous, client.Name, cmcv1.RolloutPending)
+			blocked = true
+		case update:
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
+				"kubernetes": client.Name}).Infof("CassandraCluster created/updated")
+			contextStatus = setRollout(status, previous, client.Name, cmcv1.RolloutInProgress)
+			rollingOut++
+		case !r.ReadyCassandraCluster(storedCC):
 			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
 				"kubernetes": client.Name}).Infof("Cluster is not Ready, "+
 				"we requeue [phase=%s / action=%s / status=%s]", storedCC.Status.Phase,
 				storedCC.Status.LastClusterAction, storedCC.Status.LastClusterActionStatus)
-			return requeue30, err
+			contextStatus = setRollout(status, previous, client.Name, cmcv1.RolloutInProgress)
+			rollingOut++
+		default:
+			contextStatus = setRollout(status, previous, client.Name, cmcv1.RolloutDone)
+			if contextStatus.RolledOutTime != nil && contextStatus.RolledOutTime.After(lastRolledOut) {
+				lastRolledOut = contextStatus.RolledOutTime.Time
+			}
 		}
+		r.observeCassandraCluster(client, contextStatus, storedCC, !(update && blocker != ""))
 	}
 
 	if r.cmc.DeletionTimestamp != nil {
@@ -172,7 +223,20 @@ func (r *reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 		return forget, err
 	}
 
-	return requeue30, err
+	status.Conditions = append(status.Conditions, r.cmc.Status.Conditions...)
+	updateConditions(r.cmc, status)
+	if !apiequality.Semantic.DeepEqual(r.cmc.Status, *status) {
+		r.cmc.Status = *status
+		if err := localClient.Status().Update(context.TODO(), r.cmc); err != nil {
+			logrus.WithFields(logrus.Fields{"multicasskop": r.cmc.Name}).Errorf("error on status update %v", err)
+			return requeue5, err
+		}
+	}
+
+	if reconcileErr != nil {
+		return requeue5, reconcileErr
+	}
+	return requeue30, nil
 }
 
 func (r *reconciler) namespacedName(name, namespace string) types.NamespacedName {
@@ -0,0 +1,88 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	cmcv1 "github.com/Orange-OpenSource/casskop/multi-casskop/api/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rolloutClients returns the clients in the rollout order of the MultiCasskop
+func (r *reconciler) rolloutClients() []*Client {
+	var names []string
+	clients := map[string]*Client{}
+	for _, client := range r.clients.FlatClients() {
+		names = append(names, client.Name)
+		clients[client.Name] = client
+	}
+
+	var ordered []*Client
+	for _, name := range r.cmc.Spec.RolloutStrategy.OrderContexts(names) {
+		ordered = append(ordered, clients[name])
+	}
+	return ordered
+}
+
+// rolloutBlocker returns why the CassandraCluster of the next context can't be created or updated yet, empty if it
+// can
+func (r *reconciler) rolloutBlocker(rollingOut int, lastRolledOut time.Time, pause time.Duration) string {
+	if r.cmc.RolloutPaused() {
+		return fmt.Sprintf("rollout is paused by annotation %s", cmcv1.AnnotationRolloutPaused)
+	}
+
+	if rollingOut >= r.cmc.Spec.RolloutStrategy.MaxConcurrent() {
+		return fmt.Sprintf("%d clusters are already rolling out", rollingOut)
+	}
+
+	if pauseEnd := lastRolledOut.Add(pause); time.Now().Before(pauseEnd) {
+		return fmt.Sprintf("rollout is paused between clusters until %s", pauseEnd.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// lastRolledOutTime returns the last time the rollout of a context ended
+func lastRolledOutTime(status *cmcv1.MultiCasskopStatus) time.Time {
+	var last time.Time
+	for _, contextStatus := range status.Contexts {
+		if contextStatus.RolledOutTime != nil && contextStatus.RolledOutTime.After(last) {
+			last = contextStatus.RolledOutTime.Time
+		}
+	}
+	return last
+}
+
+// setRollout appends the status of a context to a status with its rollout state, carrying over the rest of its
+// previous status. It records when the rollout of the context ends
+func setRollout(status *cmcv1.MultiCasskopStatus, previous *cmcv1.ContextStatus, name string,
+	state cmcv1.RolloutState) *cmcv1.ContextStatus {
+	contextStatus := cmcv1.ContextStatus{Name: name}
+	if previous != nil {
+		previous.DeepCopyInto(&contextStatus)
+		contextStatus.LastError = ""
+	}
+	if contextStatus.Rollout != state {
+		if contextStatus.Rollout == cmcv1.RolloutInProgress && state == cmcv1.RolloutDone {
+			rolledOutTime := metav1.Now()
+			contextStatus.RolledOutTime = &rolledOutTime
+		}
+		contextStatus.Rollout = state
+		contextStatus.LastTransitionTime = metav1.Now()
+	}
+	status.Contexts = append(status.Contexts, contextStatus)
+	return &status.Contexts[len(status.Contexts)-1]
+}
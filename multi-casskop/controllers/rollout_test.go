@@ -0,0 +1,155 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cmcv1 "github.com/Orange-OpenSource/casskop/multi-casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// helperInitReconciler returns a reconciler of a MultiCasskop with a local client and remote clients
+func helperInitReconciler(strategy *cmcv1.RolloutStrategy, local string, remotes ...string) *reconciler {
+	clients := &Clients{Local: &Client{Name: local}}
+	for _, remote := range remotes {
+		clients.Remotes = append(clients.Remotes, &Client{Name: remote})
+	}
+	cmc := &cmcv1.MultiCasskop{ObjectMeta: metav1.ObjectMeta{Name: "multi-casskop", Namespace: "ns"},
+		Spec: cmcv1.MultiCasskopSpec{RolloutStrategy: strategy}}
+	return &reconciler{clients: clients, cmc: cmc, namespace: "ns"}
+}
+
+func clientNames(clients []*Client) []string {
+	var names []string
+	for _, client := range clients {
+		names = append(names, client.Name)
+	}
+	return names
+}
+
+func TestRolloutClients(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		name     string
+		strategy *cmcv1.RolloutStrategy
+		order    []string
+	}{
+		{"no strategy", nil, []string{"dc1", "dc2", "dc3"}},
+		{"no order", &cmcv1.RolloutStrategy{}, []string{"dc1", "dc2", "dc3"}},
+		{"full order", &cmcv1.RolloutStrategy{Order: []string{"dc3", "dc1", "dc2"}}, []string{"dc3", "dc1", "dc2"}},
+		{"partial order", &cmcv1.RolloutStrategy{Order: []string{"dc3"}}, []string{"dc3", "dc1", "dc2"}},
+		{"unknown contexts", &cmcv1.RolloutStrategy{Order: []string{"dc4", "dc2"}}, []string{"dc2", "dc1", "dc3"}},
+	} {
+		r := helperInitReconciler(tt.strategy, "dc1", "dc2", "dc3")
+		assert.Equal(tt.order, clientNames(r.rolloutClients()), tt.name)
+	}
+}
+
+func TestRolloutBlocker(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		name          string
+		strategy      *cmcv1.RolloutStrategy
+		paused        bool
+		rollingOut    int
+		lastRolledOut time.Time
+		pause         time.Duration
+		blocker       string
+	}{
+		{name: "first rollout"},
+		{name: "one cluster at a time", rollingOut: 1, blocker: "1 clusters are already rolling out"},
+		{name: "concurrent clusters", strategy: &cmcv1.RolloutStrategy{MaxConcurrentClusters: 2}, rollingOut: 1},
+		{name: "too many concurrent clusters", strategy: &cmcv1.RolloutStrategy{MaxConcurrentClusters: 2},
+			rollingOut: 2, blocker: "2 clusters are already rolling out"},
+		{name: "paused", paused: true,
+			blocker: "rollout is paused by annotation " + cmcv1.AnnotationRolloutPaused},
+		{name: "paused while rolling out", paused: true, rollingOut: 1,
+			blocker: "rollout is paused by annotation " + cmcv1.AnnotationRolloutPaused},
+		{name: "pause between clusters", lastRolledOut: time.Now().Add(-5 * time.Minute), pause: 10 * time.Minute,
+			blocker: "rollout is paused between clusters until "},
+		{name: "pause between clusters over", lastRolledOut: time.Now().Add(-15 * time.Minute),
+			pause: 10 * time.Minute},
+	} {
+		r := helperInitReconciler(tt.strategy, "dc1", "dc2")
+		if tt.paused {
+			r.cmc.Annotations = map[string]string{cmcv1.AnnotationRolloutPaused: "true"}
+		}
+		blocker := r.rolloutBlocker(tt.rollingOut, tt.lastRolledOut, tt.pause)
+		if strings.HasSuffix(tt.blocker, " ") {
+			assert.True(strings.HasPrefix(blocker, tt.blocker), "%s: %s", tt.name, blocker)
+			continue
+		}
+		assert.Equal(tt.blocker, blocker, tt.name)
+	}
+}
+
+func TestLastRolledOutTime(t *testing.T) {
+	assert := assert.New(t)
+
+	status := &cmcv1.MultiCasskopStatus{}
+	assert.True(lastRolledOutTime(status).IsZero())
+
+	first := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	last := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	status.Contexts = []cmcv1.ContextStatus{{Name: "dc1", RolledOutTime: &last}, {Name: "dc2"},
+		{Name: "dc3", RolledOutTime: &first}}
+	assert.Equal(last.Time, lastRolledOutTime(status))
+}
+
+func TestSetRollout(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		name       string
+		previous   *cmcv1.ContextStatus
+		state      cmcv1.RolloutState
+		rolledOut  bool
+		transition bool
+	}{
+		{"new context", nil, cmcv1.RolloutPending, false, true},
+		{"rollout started", &cmcv1.ContextStatus{Rollout: cmcv1.RolloutPending}, cmcv1.RolloutInProgress, false,
+			true},
+		{"rollout ended", &cmcv1.ContextStatus{Rollout: cmcv1.RolloutInProgress}, cmcv1.RolloutDone, true, true},
+		{"ready without rollout", &cmcv1.ContextStatus{Rollout: cmcv1.RolloutPending}, cmcv1.RolloutDone, false,
+			true},
+		{"unchanged", &cmcv1.ContextStatus{Rollout: cmcv1.RolloutDone}, cmcv1.RolloutDone, false, false},
+	} {
+		if tt.previous != nil {
+			tt.previous.Name = "dc1"
+			tt.previous.Phase = "Running"
+			tt.previous.LastError = "can't get CassandraCluster"
+		}
+		status := &cmcv1.MultiCasskopStatus{Contexts: []cmcv1.ContextStatus{{Name: "dc0"}}}
+		contextStatus := setRollout(status, tt.previous, "dc1", tt.state)
+
+		assert.Len(status.Contexts, 2, tt.name)
+		assert.Equal(&status.Contexts[1], contextStatus, tt.name)
+		assert.Equal("dc1", contextStatus.Name, tt.name)
+		assert.Equal(tt.state, contextStatus.Rollout, tt.name)
+		assert.Empty(contextStatus.LastError, tt.name)
+		assert.Equal(tt.rolledOut, contextStatus.RolledOutTime != nil, tt.name)
+		assert.Equal(tt.transition, !contextStatus.LastTransitionTime.IsZero(), tt.name)
+		if tt.previous != nil {
+			// The rest of the previous status is carried over
+			assert.Equal("Running", contextStatus.Phase, tt.name)
+		}
+	}
+}
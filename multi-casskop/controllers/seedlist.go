@@ -0,0 +1,125 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	ccv1 "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// externalDNSHostnameAnnotation is the annotation of the headless service of a CassandraCluster giving the domain
+// external-dns publishes its pods in, reachable from the other kubernetes clusters
+const externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+
+// globalSeedList returns the seed list shared by the CassandraClusters of all the contexts. Each CassandraCluster
+// brings the seeds of its own topology found in its status, completed with the ones CassKop picks like with its
+// autoUpdateSeedList, bounded by maxSeedsPerDC
+func (r *reconciler) globalSeedList() []string {
+	var seedList []string
+	for _, client := range r.rolloutClients() {
+		found, cc := r.computeCassandraClusterForContext(client)
+		if !found {
+			continue
+		}
+		var storedSeedList []string
+		storedCC := &ccv1.CassandraCluster{}
+		err := client.Client.Get(context.TODO(), r.namespacedName(cc.Name, cc.Namespace), storedCC)
+		switch {
+		case err == nil:
+			storedSeedList = storedCC.Status.SeedList
+		case !errors.IsNotFound(err):
+			logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
+				"kubernetes": client.Name}).Warningf("Can't get the seed list of CassandraCluster: %v", err)
+		}
+		seeds := clusterSeeds(cc, storedSeedList, r.cmc.Spec.SeedsPerDC())
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
+			"kubernetes": client.Name}).Debugf("Seeds of CassandraCluster: %v", seeds)
+		seedList = append(seedList, seeds...)
+	}
+	return seedList
+}
+
+// clusterSeeds returns the seeds of the DCs of a CassandraCluster, at most seedsPerDC of each. The seeds of its stored
+// seed list which are nodes of its topology are kept first, then the seeds CassKop picks complete each DC. They use
+// the domain of its headless service when external-dns publishes it
+func clusterSeeds(cc *ccv1.CassandraCluster, storedSeedList []string, seedsPerDC int) []string {
+	var domain string
+	if cc.Spec.Service != nil {
+		domain = strings.TrimSuffix(cc.Spec.Service.Annotations[externalDNSHostnameAnnotation], ".")
+	}
+
+	var seeds []string
+	seedsOfDC := map[string]int{}
+	for _, seed := range append(append([]string{}, storedSeedList...), cc.InitSeedList()...) {
+		podName := strings.SplitN(seed, ".", 2)[0]
+		dcName := seedDCName(cc, podName)
+		if dcName == "" || seedsOfDC[dcName] >= seedsPerDC {
+			continue
+		}
+		seed = podName + "." + cc.Name + "." + cc.Namespace
+		if domain != "" {
+			seed = podName + "." + domain
+		}
+		if k8s.Contains(seeds, seed) {
+			continue
+		}
+		seedsOfDC[dcName]++
+		seeds = append(seeds, seed)
+	}
+	return seeds
+}
+
+// seedDCName returns the DC of a seed pod, named <cluster>-<dc>-<rack>-<index>, from the topology of a
+// CassandraCluster. It is empty when the pod is not one of its nodes, e.g. a seed of another CassandraCluster
+func seedDCName(cc *ccv1.CassandraCluster, podName string) string {
+	dcSize := cc.GetDCSize()
+	if dcSize < 1 {
+		dcSize = 1
+	}
+	for dc := 0; dc < dcSize; dc++ {
+		dcName := cc.GetDCName(dc)
+		rackSize := cc.GetRackSize(dc)
+		if rackSize < 1 {
+			rackSize = 1
+		}
+		for rack := 0; rack < rackSize; rack++ {
+			dcRackName := cc.GetDCRackName(dcName, cc.GetRackName(dc, rack))
+			prefix := cc.Name + "-" + dcRackName + "-"
+			if !strings.HasPrefix(podName, prefix) {
+				continue
+			}
+			index, err := strconv.Atoi(strings.TrimPrefix(podName, prefix))
+			if err == nil && index >= 0 && int32(index) < cc.GetNodesPerRacks(dcRackName) {
+				return dcName
+			}
+		}
+	}
+	return ""
+}
+
+// mergeSeedList keeps the seeds of the stored seed list of a CassandraCluster in their order, so that only a change
+// of seeds rolls out its racks
+func mergeSeedList(storedSeedList, seedList []string) []string {
+	if len(storedSeedList) == 0 {
+		return seedList
+	}
+	return k8s.MergeSlice(storedSeedList, seedList)
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+
+	ccv1 "github.com/Orange-OpenSource/casskop/api/v2"
+	cmcv1 "github.com/Orange-OpenSource/casskop/multi-casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func seedListCluster(dcs ...ccv1.DC) *ccv1.CassandraCluster {
+	return &ccv1.CassandraCluster{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-e2e", Namespace: "ns"},
+		Spec: ccv1.CassandraClusterSpec{NodesPerRacks: 2, Topology: ccv1.Topology{DC: dcs}}}
+}
+
+func TestSeedDCName(t *testing.T) {
+	assert := assert.New(t)
+
+	three := int32(3)
+	cc := seedListCluster(
+		ccv1.DC{Name: "dc-paris", Rack: ccv1.RackSlice{{Name: "rack1"}, {Name: "rack-b"}}},
+		ccv1.DC{Name: "dc2", NodesPerRacks: &three, Rack: ccv1.RackSlice{{Name: "rack1"}}})
+
+	for _, tt := range []struct {
+		podName string
+		dcName  string
+	}{
+		{"cassandra-e2e-dc-paris-rack1-0", "dc-paris"},
+		{"cassandra-e2e-dc-paris-rack-b-1", "dc-paris"},
+		{"cassandra-e2e-dc2-rack1-2", "dc2"},
+		// Nodes missing from the topology
+		{"cassandra-e2e-dc-paris-rack1-2", ""},
+		{"cassandra-e2e-dc-paris-rack2-0", ""},
+		{"cassandra-e2e-dc3-rack1-0", ""},
+		{"other-dc2-rack1-0", ""},
+		{"cassandra-e2e-dc2-rack1-x", ""},
+	} {
+		assert.Equal(tt.dcName, seedDCName(cc, tt.podName), tt.podName)
+	}
+
+	// Without topology, the nodes are in the default dc and rack
+	cc = seedListCluster()
+	assert.Equal(ccv1.DefaultCassandraDC, seedDCName(cc, "cassandra-e2e-dc1-rack1-1"))
+	assert.Equal("", seedDCName(cc, "cassandra-e2e-dc1-rack1-2"))
+}
+
+func TestClusterSeeds(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := seedListCluster(ccv1.DC{Name: "dc1", Rack: ccv1.RackSlice{{Name: "rack1"}, {Name: "rack2"}}},
+		ccv1.DC{Name: "dc2", Rack: ccv1.RackSlice{{Name: "rack1"}}})
+
+	for _, tt := range []struct {
+		name           string
+		storedSeedList []string
+		seedsPerDC     int
+		domain         string
+		seeds          []string
+	}{
+		{name: "seeds picked by CassKop", seedsPerDC: 3, seeds: []string{
+			"cassandra-e2e-dc1-rack1-0.cassandra-e2e.ns", "cassandra-e2e-dc1-rack1-1.cassandra-e2e.ns",
+			"cassandra-e2e-dc1-rack2-0.cassandra-e2e.ns", "cassandra-e2e-dc2-rack1-0.cassandra-e2e.ns",
+			"cassandra-e2e-dc2-rack1-1.cassandra-e2e.ns"}},
+		{name: "seeds per dc", seedsPerDC: 1, seeds: []string{
+			"cassandra-e2e-dc1-rack1-0.cassandra-e2e.ns", "cassandra-e2e-dc2-rack1-0.cassandra-e2e.ns"}},
+		{name: "stored seeds first", seedsPerDC: 2, storedSeedList: []string{
+			"cassandra-e2e-dc1-rack2-1.cassandra-e2e.ns", "cassandra-e2e-dc1-rack1-0.cassandra-e2e.ns"},
+			seeds: []string{"cassandra-e2e-dc1-rack2-1.cassandra-e2e.ns",
+				"cassandra-e2e-dc1-rack1-0.cassandra-e2e.ns", "cassandra-e2e-dc2-rack1-0.cassandra-e2e.ns",
+				"cassandra-e2e-dc2-rack1-1.cassandra-e2e.ns"}},
+		{name: "stored seeds of other clusters or removed nodes", seedsPerDC: 1, storedSeedList: []string{
+			"cassandra-e2e-dc3-rack1-0.cassandra-e2e.ns", "cassandra-e2e-dc2-rack1-5.cassandra-e2e.ns",
+			"cassandra-e2e-dc2-rack1-1.cassandra-e2e.ns"},
+			seeds: []string{"cassandra-e2e-dc2-rack1-1.cassandra-e2e.ns",
+				"cassandra-e2e-dc1-rack1-0.cassandra-e2e.ns"}},
+		{name: "external-dns domain", seedsPerDC: 1, domain: "dc.example.com.",
+			storedSeedList: []string{"cassandra-e2e-dc2-rack1-1.cassandra-e2e.ns"},
+			seeds: []string{"cassandra-e2e-dc2-rack1-1.dc.example.com",
+				"cassandra-e2e-dc1-rack1-0.dc.example.com"}},
+	} {
+		cc.Spec.Service = nil
+		if tt.domain != "" {
+			cc.Spec.Service = &ccv1.ServicePolicy{Annotations: map[string]string{
+				externalDNSHostnameAnnotation: tt.domain}}
+		}
+		assert.Equal(tt.seeds, clusterSeeds(cc, tt.storedSeedList, tt.seedsPerDC), tt.name)
+	}
+}
+
+func TestGlobalSeedList(t *testing.T) {
+	assert := assert.New(t)
+
+	fakeClientScheme := scheme.Scheme
+	fakeClientScheme.AddKnownTypes(ccv1.GroupVersion, &ccv1.CassandraCluster{})
+	fakeClientScheme.AddKnownTypes(ccv1.GroupVersion, &ccv1.CassandraClusterList{})
+
+	// The CassandraCluster of the first context has a seed list, the one of the second context is not created yet
+	storedCC := seedListCluster()
+	storedCC.Status.SeedList = []string{"cassandra-e2e-dc-lyon-rack1-0.cassandra-e2e.ns",
+		"cassandra-e2e-dc-paris-rack1-1.cassandra-e2e.ns"}
+	r := helperInitReconciler(&cmcv1.RolloutStrategy{Order: []string{"lyon", "paris"}}, "paris", "lyon")
+	r.clients.Local.Client = fake.NewFakeClientWithScheme(fakeClientScheme, []runtime.Object{storedCC}...)
+	r.clients.Remotes[0].Client = fake.NewFakeClientWithScheme(fakeClientScheme)
+
+	r.cmc.Spec.MaxSeedsPerDC = 1
+	r.cmc.Spec.Base = *seedListCluster()
+	r.cmc.Spec.Override = map[string]ccv1.CassandraCluster{
+		"paris": {Spec: ccv1.CassandraClusterSpec{Topology: ccv1.Topology{DC: ccv1.DCSlice{
+			{Name: "dc-paris", Rack: ccv1.RackSlice{{Name: "rack1"}}}}}}},
+		"lyon": {Spec: ccv1.CassandraClusterSpec{Topology: ccv1.Topology{DC: ccv1.DCSlice{
+			{Name: "dc-lyon", Rack: ccv1.RackSlice{{Name: "rack1"}}}}}}},
+	}
+
+	// Each CassandraCluster brings the seeds of its own topology, in the rollout order
+	assert.Equal([]string{"cassandra-e2e-dc-lyon-rack1-0.cassandra-e2e.ns",
+		"cassandra-e2e-dc-paris-rack1-1.cassandra-e2e.ns"}, r.globalSeedList())
+
+	// A context without override has no CassandraCluster
+	delete(r.cmc.Spec.Override, "lyon")
+	assert.Equal([]string{"cassandra-e2e-dc-paris-rack1-1.cassandra-e2e.ns"}, r.globalSeedList())
+}
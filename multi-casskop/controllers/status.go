@@ -0,0 +1,134 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	ccv1 "github.com/Orange-OpenSource/casskop/api/v2"
+	cmcv1 "github.com/Orange-OpenSource/casskop/multi-casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// specHash returns the hash of the spec of a CassandraCluster
+func specHash(spec ccv1.CassandraClusterSpec) string {
+	data, _ := json.Marshal(spec)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// observeCassandraCluster fills the status of a context with the state of its CassandraCluster. The applied spec
+// hash only changes when the spec was applied, not while the CassandraCluster waits for its rollout
+func (r *reconciler) observeCassandraCluster(client *Client, contextStatus *cmcv1.ContextStatus,
+	cc *ccv1.CassandraCluster, applied bool) {
+	contextStatus.Phase = cc.Status.Phase
+	contextStatus.LastClusterAction = cc.Status.LastClusterAction
+	contextStatus.LastClusterActionStatus = cc.Status.LastClusterActionStatus
+	if applied {
+		contextStatus.AppliedSpecHash = specHash(cc.Spec)
+	}
+
+	readyNodes, nodes, err := r.countNodes(client, cc)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"cluster": cc.Name, "namespace": cc.Namespace,
+			"kubernetes": client.Name}).Warningf("Can't count nodes of CassandraCluster: %v", err)
+		return
+	}
+	contextStatus.ReadyNodes = readyNodes
+	contextStatus.Nodes = nodes
+}
+
+// countNodes returns the number of ready nodes and the number of nodes of the statefulsets of a CassandraCluster
+func (r *reconciler) countNodes(client *Client, cc *ccv1.CassandraCluster) (int32, int32, error) {
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := client.Client.List(context.TODO(), statefulSets, controllerclient.InNamespace(cc.Namespace),
+		controllerclient.MatchingLabels(k8s.LabelsForCassandra(cc))); err != nil {
+		return 0, 0, err
+	}
+
+	var readyNodes, nodes int32
+	for _, statefulSet := range statefulSets.Items {
+		readyNodes += statefulSet.Status.ReadyReplicas
+		if statefulSet.Spec.Replicas != nil {
+			nodes += *statefulSet.Spec.Replicas
+		}
+	}
+	return readyNodes, nodes, nil
+}
+
+// setCondition sets a condition of the status, true when some contexts match, its transition time only changes
+// with its status
+func setCondition(cmc *cmcv1.MultiCasskop, status *cmcv1.MultiCasskopStatus, conditionType string,
+	contexts []string, trueReason, falseReason, format string) {
+	condition := metav1.Condition{Type: conditionType, Status: metav1.ConditionFalse, Reason: falseReason,
+		ObservedGeneration: cmc.Generation}
+	if len(contexts) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = trueReason
+		condition.Message = fmt.Sprintf(format, strings.Join(contexts, ","))
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// updateConditions computes the node counts and the conditions of the global ring from the status of each context
+func updateConditions(cmc *cmcv1.MultiCasskop, status *cmcv1.MultiCasskopStatus) {
+	var notReady, progressing, degraded []string
+	status.ReadyNodes, status.Nodes = 0, 0
+	for _, contextStatus := range status.Contexts {
+		status.ReadyNodes += contextStatus.ReadyNodes
+		status.Nodes += contextStatus.Nodes
+		if contextStatus.Rollout != cmcv1.RolloutDone || contextStatus.LastError != "" {
+			notReady = append(notReady, contextStatus.Name)
+		}
+		if contextStatus.Rollout != cmcv1.RolloutDone {
+			progressing = append(progressing, contextStatus.Name)
+		}
+		//A CassandraCluster missing ready nodes while it is not rolled out explains it
+		if contextStatus.LastError != "" || (contextStatus.Rollout != cmcv1.RolloutInProgress &&
+			contextStatus.ReadyNodes < contextStatus.Nodes) {
+			degraded = append(degraded, contextStatus.Name)
+		}
+	}
+
+	ready := metav1.Condition{Type: cmcv1.ConditionReady, Status: metav1.ConditionTrue, Reason: "ClustersReady",
+		Message: "All the CassandraClusters are ready and up to date", ObservedGeneration: cmc.Generation}
+	if len(notReady) > 0 || len(status.Contexts) == 0 {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "ClustersNotReady"
+		ready.Message = fmt.Sprintf("CassandraClusters of contexts %s are not ready or not up to date",
+			strings.Join(notReady, ","))
+		if len(status.Contexts) == 0 {
+			ready.Message = "No CassandraCluster is managed yet"
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	setCondition(cmc, status, cmcv1.ConditionProgressing, progressing, "RolloutOngoing", "NoRolloutOngoing",
+		"CassandraClusters of contexts %s are rolling out or wait for their rollout")
+	setCondition(cmc, status, cmcv1.ConditionDegraded, degraded, "ClustersDegraded", "ClustersHealthy",
+		"CassandraClusters of contexts %s miss ready nodes or can't be reconciled")
+
+	status.ObservedGeneration = cmc.Generation
+}
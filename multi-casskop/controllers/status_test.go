@@ -0,0 +1,117 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	cmcv1 "github.com/Orange-OpenSource/casskop/multi-casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func contextStatus(name string, rollout cmcv1.RolloutState, readyNodes, nodes int32,
+	lastError string) cmcv1.ContextStatus {
+	return cmcv1.ContextStatus{Name: name, Rollout: rollout, ReadyNodes: readyNodes, Nodes: nodes,
+		LastError: lastError}
+}
+
+func TestUpdateConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		name        string
+		contexts    []cmcv1.ContextStatus
+		ready       metav1.ConditionStatus
+		progressing metav1.ConditionStatus
+		degraded    metav1.ConditionStatus
+		message     string
+	}{
+		{name: "no context", ready: metav1.ConditionFalse, progressing: metav1.ConditionFalse,
+			degraded: metav1.ConditionFalse, message: "No CassandraCluster is managed yet"},
+		{name: "all rolled out", contexts: []cmcv1.ContextStatus{
+			contextStatus("dc1", cmcv1.RolloutDone, 3, 3, ""), contextStatus("dc2", cmcv1.RolloutDone, 3, 3, "")},
+			ready: metav1.ConditionTrue, progressing: metav1.ConditionFalse, degraded: metav1.ConditionFalse,
+			message: "All the CassandraClusters are ready and up to date"},
+		{name: "waiting for rollout", contexts: []cmcv1.ContextStatus{
+			contextStatus("dc1", cmcv1.RolloutInProgress, 2, 3, ""),
+			contextStatus("dc2", cmcv1.RolloutPending, 3, 3, "")},
+			ready: metav1.ConditionFalse, progressing: metav1.ConditionTrue, degraded: metav1.ConditionFalse,
+			message: "CassandraClusters of contexts dc1,dc2 are not ready or not up to date"},
+		{name: "node down", contexts: []cmcv1.ContextStatus{
+			contextStatus("dc1", cmcv1.RolloutDone, 3, 3, ""), contextStatus("dc2", cmcv1.RolloutDone, 2, 3, "")},
+			ready: metav1.ConditionTrue, progressing: metav1.ConditionFalse, degraded: metav1.ConditionTrue,
+			message: "All the CassandraClusters are ready and up to date"},
+		{name: "node down while waiting for rollout", contexts: []cmcv1.ContextStatus{
+			contextStatus("dc1", cmcv1.RolloutInProgress, 3, 3, ""),
+			contextStatus("dc2", cmcv1.RolloutPending, 2, 3, "")},
+			ready: metav1.ConditionFalse, progressing: metav1.ConditionTrue, degraded: metav1.ConditionTrue,
+			message: "CassandraClusters of contexts dc1,dc2 are not ready or not up to date"},
+		{name: "reconcile error", contexts: []cmcv1.ContextStatus{
+			contextStatus("dc1", cmcv1.RolloutDone, 3, 3, "can't update CassandraCluster"),
+			contextStatus("dc2", cmcv1.RolloutDone, 3, 3, "")},
+			ready: metav1.ConditionFalse, progressing: metav1.ConditionFalse, degraded: metav1.ConditionTrue,
+			message: "CassandraClusters of contexts dc1 are not ready or not up to date"},
+	} {
+		cmc := &cmcv1.MultiCasskop{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+		status := &cmcv1.MultiCasskopStatus{Contexts: tt.contexts, ReadyNodes: 10, Nodes: 10}
+		updateConditions(cmc, status)
+
+		var readyNodes, nodes int32
+		for _, contextStatus := range tt.contexts {
+			readyNodes += contextStatus.ReadyNodes
+			nodes += contextStatus.Nodes
+		}
+		assert.Equal(readyNodes, status.ReadyNodes, tt.name)
+		assert.Equal(nodes, status.Nodes, tt.name)
+		assert.Equal(int64(2), status.ObservedGeneration, tt.name)
+
+		ready := meta.FindStatusCondition(status.Conditions, cmcv1.ConditionReady)
+		assert.Equal(tt.ready, ready.Status, tt.name)
+		assert.Equal(tt.message, ready.Message, tt.name)
+		assert.Equal(int64(2), ready.ObservedGeneration, tt.name)
+		assert.Equal(tt.progressing, meta.FindStatusCondition(status.Conditions,
+			cmcv1.ConditionProgressing).Status, tt.name)
+		assert.Equal(tt.degraded, meta.FindStatusCondition(status.Conditions, cmcv1.ConditionDegraded).Status,
+			tt.name)
+	}
+}
+
+func TestUpdateConditionsTransitionTime(t *testing.T) {
+	assert := assert.New(t)
+
+	cmc := &cmcv1.MultiCasskop{}
+	lastTransitionTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	status := &cmcv1.MultiCasskopStatus{
+		Contexts: []cmcv1.ContextStatus{contextStatus("dc1", cmcv1.RolloutInProgress, 2, 3, "")},
+		Conditions: []metav1.Condition{{Type: cmcv1.ConditionProgressing, Status: metav1.ConditionTrue,
+			Reason: "RolloutOngoing", LastTransitionTime: lastTransitionTime}},
+	}
+
+	// The transition time of a condition only changes with its status
+	updateConditions(cmc, status)
+	progressing := meta.FindStatusCondition(status.Conditions, cmcv1.ConditionProgressing)
+	assert.Equal(lastTransitionTime, progressing.LastTransitionTime)
+	assert.Equal("CassandraClusters of contexts dc1 are rolling out or wait for their rollout", progressing.Message)
+
+	status.Contexts[0] = contextStatus("dc1", cmcv1.RolloutDone, 3, 3, "")
+	updateConditions(cmc, status)
+	progressing = meta.FindStatusCondition(status.Conditions, cmcv1.ConditionProgressing)
+	assert.Equal(metav1.ConditionFalse, progressing.Status)
+	assert.Equal("NoRolloutOngoing", progressing.Reason)
+	assert.True(progressing.LastTransitionTime.After(lastTransitionTime.Time))
+}
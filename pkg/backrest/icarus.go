@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	api "github.com/Orange-OpenSource/casskop/api/v2"
 	"github.com/Orange-OpenSource/casskop/pkg/cassandrabackup"
@@ -19,19 +20,21 @@ var (
 	regexSpaceOrComma = regexp.MustCompile("[\\s,]+")
 )
 
-
-type Client struct {
+// icarusProvider is the BackupProvider of the Icarus sidecar, the default one. It also ships and replays commitlogs
+type icarusProvider struct {
 	client            cassandrabackup.Client
 	CoordinatorMember string
 }
 
-func NewClient(client client.Client, cc *api.CassandraCluster, pod *corev1.Pod) (*Client, error) {
+var _ CommitlogProvider = &icarusProvider{}
+
+func newIcarusProvider(client client.Client, cc *api.CassandraCluster, pod *corev1.Pod) (BackupProvider, error) {
 	csClient, err := common.NewCassandraBackupConnection(client, cc, pod)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{client: csClient, CoordinatorMember: pod.Name}, nil
+	return &icarusProvider{client: csClient, CoordinatorMember: pod.Name}, nil
 }
 
 func filterEmptyStrings(input []string) []string {
@@ -50,13 +53,13 @@ func formatEntities(entities string) string{
 	return strings.Join(filterEmptyStrings(regexSpaceOrComma.Split(strings.TrimSpace(entities), -1)), ",")
 }
 
-func (c *Client) PerformRestore(restore *api.CassandraRestore,
-	backup *api.CassandraBackup) (*api.BackRestStatus, error) {
+func (c *icarusProvider) PerformRestore(restore *api.CassandraRestore, backup *api.CassandraBackup,
+	snapshotTag string) (*api.BackRestStatus, error) {
 	restoreOperationRequest := &icarus.RestoreOperationRequest {
 		Type_: "restore",
 		Dc: restore.Spec.Datacenter,
 		StorageLocation: backup.Spec.StorageLocation,
-		SnapshotTag: backup.Spec.SnapshotTag,
+		SnapshotTag: snapshotTag,
 		NoDeleteTruncates: restore.Spec.NoDeleteTruncates,
 		ExactSchemaVersion: restore.Spec.ExactSchemaVersion,
 		RestorationPhase: "INIT",
@@ -99,14 +102,14 @@ func (c *Client) PerformRestore(restore *api.CassandraRestore,
 	return &restoreStatus, nil
 }
 
-func (c *Client) PerformBackup(backup *api.CassandraBackup) (string, error) {
+func (c *icarusProvider) PerformBackup(backup *api.CassandraBackup, snapshotTag string) (string, error) {
 	bandwidth := strings.Replace(backup.Spec.Bandwidth, " ", "", -1)
 	bandwidthDataRate, err := dataRateFromBandwidth(bandwidth)
 
 	backupOperationRequest := &icarus.BackupOperationRequest{
 		Type_:                 "backup",
 		StorageLocation:       backup.Spec.StorageLocation,
-		SnapshotTag:           backup.Spec.SnapshotTag,
+		SnapshotTag:           snapshotTag,
 		Duration:              backup.Spec.Duration,
 		Bandwidth:             bandwidthDataRate,
 		ConcurrentConnections: backup.Spec.ConcurrentConnections,
@@ -125,7 +128,89 @@ func (c *Client) PerformBackup(backup *api.CassandraBackup) (string, error) {
 	return backupOperation.Id, nil
 }
 
-func (c *Client) RestoreStatusByID(id string) (*api.BackRestStatus, error) {
+// ListBackups returns the backup operations of the sidecar, the global requests coordinating the operations run on
+// each node included
+func (c *icarusProvider) ListBackups() ([]Backup, error) {
+	backupOperations, err := c.client.BackupOperations()
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, backupOperation := range backupOperations {
+		backups = append(backups, Backup{
+			SnapshotTag: backupOperation.SnapshotTag,
+			Global:      backupOperation.GlobalRequest,
+			Progress:    api.ProgressPercentage(backupOperation.Progress),
+		})
+	}
+	return backups, nil
+}
+
+// DeleteBackup deletes a snapshot of a backup from its storage location, for all the nodes of its datacenter
+func (c *icarusProvider) DeleteBackup(backup *api.CassandraBackup, snapshotTag string) (string, error) {
+	removeBackupOperation, err := c.client.PerformRemoveBackupOperation(cassandrabackup.RemoveBackupOperationRequest{
+		Type_:           "remove-backup",
+		StorageLocation: backup.Spec.StorageLocation,
+		BackupName:      snapshotTag,
+		Dc:              backup.Spec.Datacenter,
+		K8sNamespace:    backup.Namespace,
+		K8sSecretName:   backup.Spec.Secret,
+		GlobalRequest:   true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return removeBackupOperation.Id, nil
+}
+
+// ShipCommitlogs uploads the commitlog segments of a directory of the node to the storage location of a backup
+func (c *icarusProvider) ShipCommitlogs(backup *api.CassandraBackup, directory string) (string, error) {
+	commitlogBackupOperation, err := c.client.PerformCommitlogBackupOperation(
+		cassandrabackup.CommitlogBackupOperationRequest{
+			Type_:                    "commitlog-backup",
+			StorageLocation:          backup.Spec.StorageLocation,
+			CommitLogArchiveOverride: directory,
+			ConcurrentConnections:    backup.Spec.ConcurrentConnections,
+			K8sNamespace:             backup.Namespace,
+			K8sSecretName:            backup.Spec.Secret,
+		})
+	if err != nil {
+		return "", err
+	}
+
+	return commitlogBackupOperation.Id, nil
+}
+
+// RestoreCommitlogs downloads the commitlogs of the node shipped by a backup and configures Cassandra to replay them
+// up to a timestamp on its next start. Replaying the mutations already in the restored snapshot does not change it
+func (c *icarusProvider) RestoreCommitlogs(restore *api.CassandraRestore, backup *api.CassandraBackup,
+	until time.Time) (string, error) {
+	request := cassandrabackup.CommitlogRestoreOperationRequest{
+		Type_:                    "commitlog-restore",
+		StorageLocation:          backup.Spec.StorageLocation,
+		CommitlogDownloadDir:     api.CommitlogRestoreDirectory,
+		CassandraConfigDirectory: "/etc/cassandra",
+		CassandraDirectory:       restore.Spec.CassandraDirectory,
+		TimestampEnd:             until.UnixNano() / int64(time.Millisecond),
+		K8sNamespace:             restore.Namespace,
+		K8sSecretName:            restore.Spec.Secret,
+	}
+
+	if len(restore.Spec.Secret) == 0 {
+		request.K8sSecretName = backup.Spec.Secret
+	}
+
+	commitlogRestoreOperation, err := c.client.PerformCommitlogRestoreOperation(request)
+	if err != nil {
+		return "", err
+	}
+
+	return commitlogRestoreOperation.Id, nil
+}
+
+func (c *icarusProvider) RestoreStatus(id string) (*api.BackRestStatus, error) {
 
 	restoreOperation, err := c.client.RestoreOperationByID(id)
 	if err != nil  {
@@ -137,7 +222,7 @@ func (c *Client) RestoreStatusByID(id string) (*api.BackRestStatus, error) {
 	return &status, nil
 }
 
-func (c *Client) BackupStatusByID(id string) (api.BackRestStatus, error) {
+func (c *icarusProvider) BackupStatus(id string) (api.BackRestStatus, error) {
 
 	backupOperation, err := c.client.BackupOperationByID(id)
 	if err != nil  {
@@ -149,6 +234,11 @@ func (c *Client) BackupStatusByID(id string) (api.BackRestStatus, error) {
 	return status, nil
 }
 
+// Abort aborts a running operation of the sidecar
+func (c *icarusProvider) Abort(id string) error {
+	return c.client.AbortOperation(id)
+}
+
 var regexBandwidthSupportedFormat = regexp.MustCompile(`(?i)^(?P<Value>\d+)(?P<Unit>[kmg]?)$`)
 
 func dataRateFromBandwidth(value string) (*icarus.DataRate, error) {
@@ -2,6 +2,7 @@ package backrest
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Orange-OpenSource/casskop/api/v2"
 	"github.com/Orange-OpenSource/casskop/pkg/cassandrabackup"
@@ -13,7 +14,7 @@ func TestPerformRestore(t *testing.T) {
 	assert := assert.New(t)
 
 	test := cassandrabackup.NewMockCassandraBackupClient()
-	sr := Client{
+	sr := icarusProvider{
 		CoordinatorMember: "podA",
 		client:            test,
 	}
@@ -39,7 +40,7 @@ func TestPerformRestore(t *testing.T) {
 		},
 	}
 
-	cs, err := sr.PerformRestore(cr, cb)
+	cs, err := sr.PerformRestore(cr, cb, cb.Spec.SnapshotTag)
 
 	assert.Nil(err)
 	assert.NotNil(cs)
@@ -55,12 +56,12 @@ func TestPerformRestore(t *testing.T) {
 		ID:            cs.ID,
 	}, cs)
 
-	sr = Client{
+	sr = icarusProvider{
 		CoordinatorMember: "podA",
 		client:            cassandrabackup.NewMockCassandraBackupClientFailOps(),
 	}
 
-	cs, err = sr.PerformRestore(cr, cb)
+	cs, err = sr.PerformRestore(cr, cb, cb.Spec.SnapshotTag)
 	assert.Equal(cassandrabackup.ErrCassandraSidecarNotReturned201, err)
 	assert.Nil(cs)
 }
@@ -68,13 +69,13 @@ func TestPerformRestore(t *testing.T) {
 func TestGetRestorebyId(t *testing.T) {
 	assert := assert.New(t)
 
-	c := Client{
+	c := icarusProvider{
 		CoordinatorMember: "podA",
 		client:            cassandrabackup.NewMockCassandraBackupClient(),
 	}
 
 	operationId := "d3262073-8101-450f-9a11-c851760abd57"
-	cs, err := c.RestoreStatusByID(operationId)
+	cs, err := c.RestoreStatus(operationId)
 
 	assert.Nil(err)
 	assert.NotNil(cs)
@@ -90,17 +91,16 @@ func TestGetRestorebyId(t *testing.T) {
 		ID:            operationId,
 	}, cs)
 
-	c = Client{
+	c = icarusProvider{
 		CoordinatorMember: "podA",
 		client:            cassandrabackup.NewMockCassandraBackupClientFailOps(),
 	}
 
-	cs, err = c.RestoreStatusByID(operationId)
+	cs, err = c.RestoreStatus(operationId)
 	assert.Equal(cassandrabackup.ErrCassandraSidecarNotReturned200, err)
 	assert.Nil(cs)
 }
 
-
 func TestParseBandwidth(t *testing.T) {
 	assert := assert.New(t)
 
@@ -139,4 +139,82 @@ func TestFormatEntities(t *testing.T) {
 	assert.Equal(expected, formatEntities(" k1,k2 "))
 	assert.Equal(expected, formatEntities(" k1,   k2 "))
 	assert.Equal(expected, formatEntities(" k1,,   k2, "))
-}
\ No newline at end of file
+}
+
+func TestDeleteBackup(t *testing.T) {
+	assert := assert.New(t)
+
+	cb := &v2.CassandraBackup{
+		Spec: v2.CassandraBackupSpec{
+			CassandraCluster: "cassandra-bgl",
+			StorageLocation:  "s3://cassie",
+			SnapshotTag:      "daily",
+			Secret:           "cloud-backup-secrets",
+		},
+	}
+
+	c := icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClient()}
+	operationID, err := c.DeleteBackup(cb, "daily-20210315-000000")
+	assert.Nil(err)
+	assert.Equal("d3262073-8101-450f-9a11-c851760abd57", operationID)
+
+	c = icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClientFailOps()}
+	_, err = c.DeleteBackup(cb, "daily-20210315-000000")
+	assert.Equal(cassandrabackup.ErrCassandraSidecarNotReturned201, err)
+}
+
+func TestNodeBackupProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	c := icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClient()}
+	progress, err := NodeBackupProgress(&c, "SnapshotTag1")
+	assert.Nil(err)
+	assert.Equal("50%", progress)
+
+	progress, err = NodeBackupProgress(&c, "SnapshotTag2")
+	assert.Nil(err)
+	assert.Equal("", progress)
+
+	c = icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClientFailOps()}
+	_, err = NodeBackupProgress(&c, "SnapshotTag1")
+	assert.Equal(cassandrabackup.ErrCassandraSidecarNotReturned200, err)
+}
+
+func TestShipCommitlogs(t *testing.T) {
+	assert := assert.New(t)
+
+	cb := &v2.CassandraBackup{
+		Spec: v2.CassandraBackupSpec{
+			CassandraCluster: "cassandra-bgl",
+			StorageLocation:  "s3://cassie",
+			SnapshotTag:      "daily",
+			Secret:           "cloud-backup-secrets",
+		},
+	}
+
+	c := icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClient()}
+	operationID, err := c.ShipCommitlogs(cb, v2.CommitlogArchiveDirectory)
+	assert.Nil(err)
+	assert.Equal("d3262073-8101-450f-9a11-c851760abd57", operationID)
+
+	c = icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClientFailOps()}
+	_, err = c.ShipCommitlogs(cb, v2.CommitlogArchiveDirectory)
+	assert.Equal(cassandrabackup.ErrCassandraSidecarNotReturned201, err)
+}
+
+func TestRestoreCommitlogs(t *testing.T) {
+	assert := assert.New(t)
+
+	cb := &v2.CassandraBackup{Spec: v2.CassandraBackupSpec{StorageLocation: "s3://cassie", SnapshotTag: "daily"}}
+	cr := &v2.CassandraRestore{Spec: v2.CassandraRestoreSpec{RestoreTimestamp: "2021-03-15T10:04:05Z"}}
+	restoreTime, _, _ := cr.RestoreTime()
+
+	c := icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClient()}
+	operationID, err := c.RestoreCommitlogs(cr, cb, restoreTime)
+	assert.Nil(err)
+	assert.Equal("d3262073-8101-450f-9a11-c851760abd57", operationID)
+
+	c = icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClientFailOps()}
+	_, err = c.RestoreCommitlogs(cr, cb, time.Now())
+	assert.Equal(cassandrabackup.ErrCassandraSidecarNotReturned201, err)
+}
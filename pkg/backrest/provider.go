@@ -0,0 +1,102 @@
+package backrest
+
+import (
+	"fmt"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BackupProvider backs up and restores the nodes of a cluster through the backRestSidecar of one of its pods.
+// Operations are asynchronous, they return an id whose status is then polled
+type BackupProvider interface {
+	// PerformBackup uploads a snapshot of the nodes of the datacenter of a backup to its storage location
+	PerformBackup(backup *api.CassandraBackup, snapshotTag string) (string, error)
+	BackupStatus(id string) (api.BackRestStatus, error)
+	// PerformRestore downloads and loads a snapshot of a backup in the nodes of the datacenter of a restore
+	PerformRestore(restore *api.CassandraRestore, backup *api.CassandraBackup,
+		snapshotTag string) (*api.BackRestStatus, error)
+	RestoreStatus(id string) (*api.BackRestStatus, error)
+	// ListBackups returns the backups run or running on the node of the pod
+	ListBackups() ([]Backup, error)
+	// DeleteBackup deletes a snapshot of a backup from its storage location, for all the nodes of its datacenter
+	DeleteBackup(backup *api.CassandraBackup, snapshotTag string) (string, error)
+	// Abort aborts a running operation, e.g. a backup or a restore
+	Abort(id string) error
+}
+
+// CommitlogProvider is implemented by the providers able to ship and replay the commitlogs archived by the nodes
+type CommitlogProvider interface {
+	BackupProvider
+	// ShipCommitlogs uploads the commitlog segments of a directory of the node to the storage location of a backup
+	ShipCommitlogs(backup *api.CassandraBackup, directory string) (string, error)
+	// RestoreCommitlogs downloads the commitlogs of the node shipped by a backup and configures Cassandra to replay
+	// them up to a timestamp on its next start
+	RestoreCommitlogs(restore *api.CassandraRestore, backup *api.CassandraBackup, until time.Time) (string, error)
+}
+
+// Backup is a backup known by the provider of a node
+type Backup struct {
+	SnapshotTag string
+	// Global is true for the backup coordinating the backups of all the nodes of a datacenter
+	Global bool
+	// Progress of the upload of the snapshot, e.g. 50%
+	Progress string
+}
+
+// providers are the constructors of the backup providers which can be set in the backRestSidecar of a cluster
+var providers = map[string]func(client.Client, *api.CassandraCluster, *corev1.Pod) (BackupProvider, error){
+	api.BackupProviderIcarus:   newIcarusProvider,
+	api.BackupProviderSnapshot: newSnapshotProvider,
+}
+
+// NewProvider returns the backup provider of the cluster talking to the sidecar of a pod
+func NewProvider(client client.Client, cc *api.CassandraCluster, pod *corev1.Pod) (BackupProvider, error) {
+	name := providerName(cc)
+	newProvider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("backup provider %s is not supported", name)
+	}
+	return newProvider(client, cc, pod)
+}
+
+// NewCommitlogProvider returns the backup provider of the cluster talking to the sidecar of a pod, as long as it can
+// ship and replay commitlogs
+func NewCommitlogProvider(client client.Client, cc *api.CassandraCluster,
+	pod *corev1.Pod) (CommitlogProvider, error) {
+	provider, err := NewProvider(client, cc, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	commitlogProvider, ok := provider.(CommitlogProvider)
+	if !ok {
+		return nil, fmt.Errorf("backup provider %s does not support commitlogs", providerName(cc))
+	}
+	return commitlogProvider, nil
+}
+
+func providerName(cc *api.CassandraCluster) string {
+	if cc.Spec.BackRestSidecar == nil || cc.Spec.BackRestSidecar.Provider == "" {
+		return api.BackupProviderIcarus
+	}
+	return cc.Spec.BackRestSidecar.Provider
+}
+
+// NodeBackupProgress returns the progress on the node of the backup uploading a snapshot, empty if the node has not
+// started it yet
+func NodeBackupProgress(provider BackupProvider, snapshotTag string) (string, error) {
+	backups, err := provider.ListBackups()
+	if err != nil {
+		return "", err
+	}
+
+	for _, backup := range backups {
+		if backup.SnapshotTag == snapshotTag && !backup.Global {
+			return backup.Progress, nil
+		}
+	}
+	return "", nil
+}
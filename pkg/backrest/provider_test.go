@@ -0,0 +1,49 @@
+package backrest
+
+import (
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/cassandrabackup"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := &api.CassandraCluster{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-bgl", Namespace: "ns"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-bgl-dc1-rack1-0", Namespace: "ns"}}
+
+	// Icarus is the provider by default
+	provider, err := NewProvider(nil, cc, pod)
+	assert.Nil(err)
+	assert.Equal("cassandra-bgl-dc1-rack1-0", provider.(*icarusProvider).CoordinatorMember)
+	_, err = NewCommitlogProvider(nil, cc, pod)
+	assert.Nil(err)
+
+	cc.Spec.BackRestSidecar = &api.BackRestSidecar{Provider: "medusa"}
+	_, err = NewProvider(nil, cc, pod)
+	assert.EqualError(err, "backup provider medusa is not supported")
+
+	// The snapshot provider does not support commitlogs
+	cc.Spec.BackRestSidecar.Provider = api.BackupProviderSnapshot
+	provider, err = NewProvider(nil, cc, pod)
+	assert.Nil(err)
+	assert.IsType(&snapshotProvider{}, provider)
+	_, err = NewCommitlogProvider(nil, cc, pod)
+	assert.EqualError(err, "backup provider snapshot does not support commitlogs")
+}
+
+func TestListBackups(t *testing.T) {
+	assert := assert.New(t)
+
+	c := icarusProvider{CoordinatorMember: "podA", client: cassandrabackup.NewMockCassandraBackupClient()}
+	backups, err := c.ListBackups()
+	assert.Nil(err)
+	assert.Equal([]Backup{
+		{SnapshotTag: "SnapshotTag1", Global: true, Progress: "20%"},
+		{SnapshotTag: "SnapshotTag1", Progress: "50%"},
+	}, backups)
+}
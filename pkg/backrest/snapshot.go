@@ -0,0 +1,349 @@
+package backrest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/Orange-OpenSource/casskop/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// snapshotSidecarContainer is the backrest sidecar, whose image must provide nodetool and rclone
+	snapshotSidecarContainer = "backrest-sidecar"
+	// snapshotOperationsDir holds the state of the operations run by the sidecar, one directory per operation id
+	snapshotOperationsDir = "/var/lib/cassandra/.backrest/operations"
+)
+
+// startScript runs an operation script in the background, in its own session so that it can be aborted, and records
+// its state: RUNNING, then COMPLETED or FAILED when the script ends, or CANCELLED when it is aborted
+const startScript = `set -e
+dir=` + snapshotOperationsDir + `/$1
+mkdir -p "$dir"
+echo "$2" > "$dir/type"
+echo "$3" > "$dir/tag"
+echo RUNNING > "$dir/state"
+shift 3
+setsid sh -c 'dir=$1; shift; echo $$ > "$dir/pid"; if sh -c "$@" > "$dir/log" 2>&1; then echo COMPLETED > "$dir/state"; else echo FAILED > "$dir/state"; fi' backrest "$dir" "$@" < /dev/null > /dev/null 2>&1 &`
+
+// statusScript prints the state of an operation and the last line of its output, nothing if the node did not run it
+const statusScript = `dir=` + snapshotOperationsDir + `/$1
+if [ -f "$dir/state" ]; then
+  cat "$dir/state"
+  tail -n 1 "$dir/log" 2>/dev/null || true
+fi`
+
+// abortScript kills the processes of an operation still running
+const abortScript = `dir=` + snapshotOperationsDir + `/$1
+if [ -f "$dir/pid" ] && [ "$(cat "$dir/state")" = RUNNING ]; then
+  kill -TERM -"$(cat "$dir/pid")" 2>/dev/null || true
+  echo CANCELLED > "$dir/state"
+fi`
+
+// listScript prints the snapshot tag and the state of the backups of the node
+const listScript = `for dir in ` + snapshotOperationsDir + `/*; do
+  if [ "$(cat "$dir/type" 2>/dev/null)" = backup ]; then
+    echo "$(cat "$dir/tag") $(cat "$dir/state")"
+  fi
+done`
+
+// uploadScript uploads the files of a snapshot of the node in a location, table by table, with an optional bandwidth
+const uploadScript = `set -e
+cd /var/lib/cassandra/data
+for snapshot in */*/snapshots/"$2"; do
+  [ -d "$snapshot" ] || continue
+  rclone copy ${3:+--bwlimit "$3"} "$snapshot" "$1/${snapshot%/snapshots/*}"
+done`
+
+// downloadScript downloads the snapshot of a node, moves its files in the tables of the node and loads them. The
+// system keyspaces are not restored, and only the keyspaces or tables of the comma separated entities when set
+const downloadScript = `set -e
+tmp=/var/lib/cassandra/.backrest/download/$$
+mkdir -p "$tmp"
+trap 'rm -rf "$tmp"' EXIT
+rclone copy "$1" "$tmp"
+cd "$tmp"
+for table in */*; do
+  [ -d "$table" ] || continue
+  keyspace=${table%%/*}
+  name=${table#*/}
+  name=${name%-*}
+  case $keyspace in system*) continue ;; esac
+  if [ -n "$2" ]; then
+    case ",$2," in *",$keyspace,"*|*",$keyspace.$name,"*) ;; *) continue ;; esac
+  fi
+  for target in /var/lib/cassandra/data/"$keyspace"/"$name"-*; do
+    if [ ! -d "$target" ]; then
+      echo "Table $keyspace.$name not found"
+      exit 1
+    fi
+    mv "$table"/* "$target"/
+    nodetool refresh "$keyspace" "$name"
+    break
+  done
+done`
+
+// removeScript deletes the snapshot of a node from the storage location
+const removeScript = `exec rclone purge "$1"`
+
+var execSidecar = execSidecarOnPod
+
+// execSidecarOnPod runs a command in the backrest sidecar of a pod
+func execSidecarOnPod(pod *corev1.Pod, cmd []string) (string, string, error) {
+	k8s.InitClient()
+	return k8s.ExecPodContainer(pod.Namespace, pod, snapshotSidecarContainer, cmd, nil)
+}
+
+// snapshotProvider is the BackupProvider of a sidecar providing nodetool and rclone: each node takes a snapshot with
+// nodetool and uploads it with rclone in <storageLocation>/<cluster>/<dc>/<pod>/<snapshotTag>, a restore downloads
+// the snapshot of each node and loads it with nodetool refresh. The remote of rclone is named after the protocol of
+// the storage location, e.g. s3 for s3://bucket. The operations run on all the nodes of a datacenter, the state of
+// an operation is the aggregate of its state on the nodes
+type snapshotProvider struct {
+	client client.Client
+	cc     *api.CassandraCluster
+	pod    *corev1.Pod
+}
+
+var _ BackupProvider = &snapshotProvider{}
+
+func newSnapshotProvider(client client.Client, cc *api.CassandraCluster, pod *corev1.Pod) (BackupProvider, error) {
+	if pod == nil {
+		return nil, fmt.Errorf("no pod to run the operations of the snapshot provider")
+	}
+	return &snapshotProvider{client: client, cc: cc, pod: pod}, nil
+}
+
+// rcloneLocation returns the rclone path of a directory of a storage location, e.g. s3:bucket/dir for s3://bucket
+func rcloneLocation(storageLocation string, dirs ...string) string {
+	location := strings.TrimSuffix(strings.Replace(storageLocation, "://", ":", 1), "/")
+	return strings.Join(append([]string{location}, dirs...), "/")
+}
+
+// snapshotArgs returns the arguments of nodetool snapshot for the entities of a backup
+func snapshotArgs(snapshotTag, entities string) ([]string, error) {
+	args := []string{"-t", snapshotTag}
+	formatted := formatEntities(entities)
+	if formatted == "" {
+		return args, nil
+	}
+	tables := strings.Count(formatted, ".")
+	keyspaces := strings.Split(formatted, ",")
+	switch tables {
+	case 0:
+		return append(args, keyspaces...), nil
+	case len(keyspaces):
+		return append(args, "-kt", formatted), nil
+	}
+	return nil, fmt.Errorf("entities %s mix keyspaces and tables", entities)
+}
+
+func (p *snapshotProvider) runScript(pod *corev1.Pod, script string, args ...string) (string, error) {
+	stdout, stderr, err := execSidecar(pod, append([]string{"sh", "-c", script, "backrest"}, args...))
+	if err != nil {
+		return "", fmt.Errorf("script failed on pod %s: %v %s", pod.Name, err, stderr)
+	}
+	return stdout, nil
+}
+
+// pods returns the pods of a datacenter of the cluster, of all its datacenters when dc is empty
+func (p *snapshotProvider) pods(dc string) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := p.client.List(context.TODO(), pods, client.InNamespace(p.cc.Namespace),
+		client.MatchingLabels(k8s.LabelsForCassandraDC(p.cc, dc))); err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pods found in datacenter %s", dc)
+	}
+	return pods.Items, nil
+}
+
+// start starts an operation on each pod. The first argument of the script on each pod is returned by podArg
+func (p *snapshotProvider) start(pods []corev1.Pod, operationType, snapshotTag, script string,
+	podArg func(pod *corev1.Pod) string, args ...string) (string, error) {
+	id := string(uuid.NewUUID())
+	for i := range pods {
+		startArgs := append([]string{id, operationType, snapshotTag, script, operationType, podArg(&pods[i])},
+			args...)
+		if _, err := p.runScript(&pods[i], startScript, startArgs...); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// status returns the aggregate state of an operation on the nodes which run it. It is running as long as one of the
+// nodes runs it, it has failed or has been cancelled if it has on one of them
+func (p *snapshotProvider) status(id string) (api.BackRestStatus, error) {
+	pods, err := p.pods("")
+	if err != nil {
+		return api.BackRestStatus{}, err
+	}
+
+	var nodes, completed, running, cancelled int
+	var failureCauses []api.FailureCause
+	for i := range pods {
+		output, err := p.runScript(&pods[i], statusScript, id)
+		if err != nil {
+			return api.BackRestStatus{}, err
+		}
+		lines := strings.SplitN(strings.TrimSpace(output), "\n", 2)
+		if lines[0] == "" {
+			continue
+		}
+		nodes++
+		switch lines[0] {
+		case string(api.BackupCompleted):
+			completed++
+		case string(api.BackupRunning):
+			running++
+		case string(api.BackupCancelled):
+			cancelled++
+		default:
+			failureCause := api.FailureCause{Source: pods[i].Name}
+			if len(lines) > 1 {
+				failureCause.Message = lines[1]
+			}
+			failureCauses = append(failureCauses, failureCause)
+		}
+	}
+	if nodes == 0 {
+		return api.BackRestStatus{}, fmt.Errorf("operation %s not found", id)
+	}
+
+	state := api.BackupCompleted
+	switch {
+	case running > 0:
+		state = api.BackupRunning
+	case len(failureCauses) > 0:
+		state = api.BackupFailed
+	case cancelled > 0:
+		state = api.BackupCancelled
+	}
+	return api.BackRestStatus{
+		ID:                id,
+		CoordinatorMember: p.pod.Name,
+		Progress:          fmt.Sprintf("%d%%", completed*100/nodes),
+		Condition: &api.BackRestCondition{
+			Type:               string(state),
+			LastTransitionTime: metav1.Now().Format(util.TimeStampLayout),
+			FailureCause:       failureCauses,
+		},
+	}, nil
+}
+
+// PerformBackup takes the snapshot on each node of the datacenter, then uploads it in the background
+func (p *snapshotProvider) PerformBackup(backup *api.CassandraBackup, snapshotTag string) (string, error) {
+	args, err := snapshotArgs(snapshotTag, backup.Spec.Entities)
+	if err != nil {
+		return "", err
+	}
+	pods, err := p.pods(backup.Spec.Datacenter)
+	if err != nil {
+		return "", err
+	}
+	for i := range pods {
+		if _, err := p.runScript(&pods[i], `exec nodetool snapshot "$@"`, args...); err != nil {
+			return "", err
+		}
+	}
+	return p.start(pods, "backup", snapshotTag, uploadScript, func(pod *corev1.Pod) string {
+		return rcloneLocation(backup.Spec.StorageLocation, backup.Spec.CassandraCluster, backup.Spec.Datacenter,
+			pod.Name, snapshotTag)
+	}, snapshotTag, backup.Spec.Bandwidth)
+}
+
+func (p *snapshotProvider) BackupStatus(id string) (api.BackRestStatus, error) {
+	return p.status(id)
+}
+
+// PerformRestore restores in each node of the datacenter of the restore the snapshot of the node of the backup with
+// the same name, with the name of the cluster backed up as prefix
+func (p *snapshotProvider) PerformRestore(restore *api.CassandraRestore, backup *api.CassandraBackup,
+	snapshotTag string) (*api.BackRestStatus, error) {
+	dc := restore.Spec.Datacenter
+	if dc == "" {
+		dc = backup.Spec.Datacenter
+	}
+	pods, err := p.pods(dc)
+	if err != nil {
+		return nil, err
+	}
+	id, err := p.start(pods, "restore", snapshotTag, downloadScript, func(pod *corev1.Pod) string {
+		return rcloneLocation(backup.Spec.StorageLocation, backup.Spec.CassandraCluster, backup.Spec.Datacenter,
+			backup.Spec.CassandraCluster+strings.TrimPrefix(pod.Name, p.cc.Name), snapshotTag)
+	}, formatEntities(restore.Spec.Entities))
+	if err != nil {
+		return nil, err
+	}
+	return &api.BackRestStatus{
+		ID:       id,
+		Progress: "0%",
+		Condition: &api.BackRestCondition{
+			Type:               string(api.RestoreRunning),
+			LastTransitionTime: metav1.Now().Format(util.TimeStampLayout),
+		},
+	}, nil
+}
+
+func (p *snapshotProvider) RestoreStatus(id string) (*api.BackRestStatus, error) {
+	status, err := p.status(id)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListBackups returns the backups of the node of the pod, their progress is 100% once uploaded
+func (p *snapshotProvider) ListBackups() ([]Backup, error) {
+	output, err := p.runScript(p.pod, listScript)
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		backup := Backup{SnapshotTag: fields[0], Progress: "0%"}
+		if fields[1] == string(api.BackupCompleted) {
+			backup.Progress = "100%"
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// DeleteBackup deletes the snapshot of each node of the datacenter of the backup from the storage location
+func (p *snapshotProvider) DeleteBackup(backup *api.CassandraBackup, snapshotTag string) (string, error) {
+	pods, err := p.pods(backup.Spec.Datacenter)
+	if err != nil {
+		return "", err
+	}
+	return p.start(pods, "remove-backup", snapshotTag, removeScript, func(pod *corev1.Pod) string {
+		return rcloneLocation(backup.Spec.StorageLocation, backup.Spec.CassandraCluster, backup.Spec.Datacenter,
+			pod.Name, snapshotTag)
+	})
+}
+
+// Abort aborts an operation on all the nodes running it
+func (p *snapshotProvider) Abort(id string) error {
+	pods, err := p.pods("")
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		if _, err := p.runScript(&pods[i], abortScript, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
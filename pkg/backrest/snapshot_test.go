@@ -0,0 +1,169 @@
+package backrest
+
+import (
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// sidecarCall is a script run in the sidecar of a pod
+type sidecarCall struct {
+	pod    string
+	script string
+	args   []string
+}
+
+// helperInitSnapshotProvider returns a snapshot provider of a cluster with 2 pods in dc1 and 1 in dc2, whose
+// sidecars record the scripts they run and print the output of their pod
+func helperInitSnapshotProvider(outputs map[string]string) (*snapshotProvider, *[]sidecarCall, func()) {
+	cc := &api.CassandraCluster{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-bgl", Namespace: "ns"}}
+	var objs []runtime.Object
+	for _, name := range []string{"dc1-rack1-0", "dc1-rack1-1", "dc2-rack1-0"} {
+		objs = append(objs, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: cc.Name + "-" + name,
+			Namespace: cc.Namespace, Labels: k8s.LabelsForCassandraDC(cc, name[:3])}})
+	}
+
+	calls := &[]sidecarCall{}
+	execSidecar = func(pod *corev1.Pod, cmd []string) (string, string, error) {
+		*calls = append(*calls, sidecarCall{pod: pod.Name, script: cmd[2], args: cmd[4:]})
+		return outputs[pod.Name], "", nil
+	}
+	pod := objs[0].(*corev1.Pod)
+	provider, _ := newSnapshotProvider(fake.NewFakeClient(objs...), cc, pod)
+	return provider.(*snapshotProvider), calls, func() { execSidecar = execSidecarOnPod }
+}
+
+func TestRcloneLocation(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("s3:bucket/cluster/dc1", rcloneLocation("s3://bucket", "cluster", "dc1"))
+	assert.Equal("gcp:bucket/dir/cluster", rcloneLocation("gcp://bucket/dir/", "cluster"))
+}
+
+func TestSnapshotArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		entities string
+		args     []string
+	}{
+		{"", []string{"-t", "tag"}},
+		{"ks1, ks2", []string{"-t", "tag", "ks1", "ks2"}},
+		{"ks1.t1,ks2.t2", []string{"-t", "tag", "-kt", "ks1.t1,ks2.t2"}},
+	} {
+		args, err := snapshotArgs("tag", tt.entities)
+		assert.Nil(err)
+		assert.Equal(tt.args, args, tt.entities)
+	}
+
+	_, err := snapshotArgs("tag", "ks1,ks2.t2")
+	assert.EqualError(err, "entities ks1,ks2.t2 mix keyspaces and tables")
+}
+
+func TestSnapshotPerformBackup(t *testing.T) {
+	assert := assert.New(t)
+	provider, calls, reset := helperInitSnapshotProvider(nil)
+	defer reset()
+
+	backup := &api.CassandraBackup{Spec: api.CassandraBackupSpec{CassandraCluster: "cassandra-bgl",
+		Datacenter: "dc1", StorageLocation: "s3://bucket", Entities: "ks1", Bandwidth: "10M"}}
+	id, err := provider.PerformBackup(backup, "tag")
+	assert.Nil(err)
+
+	// The snapshot is taken on all the nodes of the datacenter before being uploaded in the background
+	assert.Len(*calls, 4)
+	for i, pod := range []string{"cassandra-bgl-dc1-rack1-0", "cassandra-bgl-dc1-rack1-1"} {
+		assert.Equal(sidecarCall{pod: pod, script: `exec nodetool snapshot "$@"`,
+			args: []string{"-t", "tag", "ks1"}}, (*calls)[i])
+		assert.Equal(sidecarCall{pod: pod, script: startScript, args: []string{id, "backup", "tag", uploadScript,
+			"backup", "s3:bucket/cassandra-bgl/dc1/" + pod + "/tag", "tag", "10M"}}, (*calls)[i+2])
+	}
+}
+
+func TestSnapshotPerformRestore(t *testing.T) {
+	assert := assert.New(t)
+	provider, calls, reset := helperInitSnapshotProvider(nil)
+	defer reset()
+
+	// Each node restores the snapshot of the node of the same name in the cluster backed up
+	backup := &api.CassandraBackup{Spec: api.CassandraBackupSpec{CassandraCluster: "cassandra-src",
+		Datacenter: "dc1", StorageLocation: "s3://bucket"}}
+	restore := &api.CassandraRestore{Spec: api.CassandraRestoreSpec{Datacenter: "dc2", Entities: "ks1"}}
+	status, err := provider.PerformRestore(restore, backup, "tag")
+	assert.Nil(err)
+	assert.Equal(string(api.RestoreRunning), status.Condition.Type)
+	assert.Equal([]sidecarCall{{pod: "cassandra-bgl-dc2-rack1-0", script: startScript, args: []string{status.ID,
+		"restore", "tag", downloadScript, "restore", "s3:bucket/cassandra-src/dc1/cassandra-src-dc2-rack1-0/tag",
+		"ks1"}}}, *calls)
+}
+
+func TestSnapshotStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		outputs  map[string]string
+		state    api.BackupConditionType
+		progress string
+		failures []api.FailureCause
+	}{
+		{map[string]string{"cassandra-bgl-dc1-rack1-0": "COMPLETED\n", "cassandra-bgl-dc1-rack1-1": "RUNNING\n"},
+			api.BackupRunning, "50%", nil},
+		{map[string]string{"cassandra-bgl-dc1-rack1-0": "COMPLETED\n", "cassandra-bgl-dc1-rack1-1": "COMPLETED\n"},
+			api.BackupCompleted, "100%", nil},
+		{map[string]string{"cassandra-bgl-dc1-rack1-0": "CANCELLED\n", "cassandra-bgl-dc1-rack1-1": "COMPLETED\n"},
+			api.BackupCancelled, "50%", nil},
+		{map[string]string{"cassandra-bgl-dc1-rack1-0": "FAILED\nno space left\n",
+			"cassandra-bgl-dc1-rack1-1": "CANCELLED\n"}, api.BackupFailed, "0%",
+			[]api.FailureCause{{Source: "cassandra-bgl-dc1-rack1-0", Message: "no space left"}}},
+	} {
+		provider, calls, reset := helperInitSnapshotProvider(tt.outputs)
+		status, err := provider.BackupStatus("id")
+		reset()
+		assert.Nil(err)
+		assert.Len(*calls, 3)
+		assert.Equal("id", status.ID)
+		assert.Equal(tt.progress, status.Progress)
+		assert.Equal(string(tt.state), status.Condition.Type)
+		assert.Equal(tt.failures, status.Condition.FailureCause)
+	}
+
+	provider, _, reset := helperInitSnapshotProvider(nil)
+	defer reset()
+	_, err := provider.RestoreStatus("id")
+	assert.EqualError(err, "operation id not found")
+}
+
+func TestSnapshotListBackups(t *testing.T) {
+	assert := assert.New(t)
+	provider, calls, reset := helperInitSnapshotProvider(map[string]string{
+		"cassandra-bgl-dc1-rack1-0": "tag1 COMPLETED\ntag2 RUNNING\n"})
+	defer reset()
+
+	backups, err := provider.ListBackups()
+	assert.Nil(err)
+	assert.Equal([]Backup{{SnapshotTag: "tag1", Progress: "100%"}, {SnapshotTag: "tag2", Progress: "0%"}}, backups)
+	assert.Equal([]sidecarCall{{pod: "cassandra-bgl-dc1-rack1-0", script: listScript, args: []string{}}}, *calls)
+
+	progress, err := NodeBackupProgress(provider, "tag2")
+	assert.Nil(err)
+	assert.Equal("0%", progress)
+}
+
+func TestSnapshotAbort(t *testing.T) {
+	assert := assert.New(t)
+	provider, calls, reset := helperInitSnapshotProvider(nil)
+	defer reset()
+
+	assert.Nil(provider.Abort("id"))
+	assert.Len(*calls, 3)
+	for _, call := range *calls {
+		assert.Equal(abortScript, call.script)
+		assert.Equal([]string{"id"}, call.args)
+	}
+}
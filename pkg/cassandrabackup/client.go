@@ -15,6 +15,11 @@ type Client interface {
 	RestoreOperationByID(operationId string) (*icarus.RestoreOperationResponse, error)
 	PerformBackupOperation(request icarus.BackupOperationRequest) (*icarus.BackupOperationResponse, error)
 	BackupOperationByID(id string) (response *icarus.BackupOperationResponse, err error)
+	BackupOperations() ([]icarus.BackupOperationResponse, error)
+	PerformRemoveBackupOperation(request RemoveBackupOperationRequest) (*icarus.BaseOperation, error)
+	PerformCommitlogBackupOperation(request CommitlogBackupOperationRequest) (*icarus.BaseOperation, error)
+	PerformCommitlogRestoreOperation(request CommitlogRestoreOperationRequest) (*icarus.BaseOperation, error)
+	AbortOperation(operationId string) error
 	Build() error
 }
 
@@ -22,6 +27,7 @@ type client struct {
 	Client
 	config    *Config
 	podClient *icarus.APIClient
+	podConfig *icarus.Configuration
 
 	newClient func(*icarus.Configuration) *icarus.APIClient
 }
@@ -31,7 +37,8 @@ func New(config *Config) Client {
 }
 
 func (cs *client) Build() error {
-	cs.podClient = cs.newClient( cs.cassandraBackupSidecarConfig())
+	cs.podConfig = cs.cassandraBackupSidecarConfig()
+	cs.podClient = cs.newClient(cs.podConfig)
 	return nil
 }
 
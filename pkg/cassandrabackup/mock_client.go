@@ -114,3 +114,49 @@ func (m *mockCassandraBackupClient) RestoreOperationByID(operationId string) (*i
 		schemaVersion), &restoreOperation)
 	return &restoreOperation, nil
 }
+
+func (m *mockCassandraBackupClient) PerformRemoveBackupOperation(request RemoveBackupOperationRequest) (
+	*icarus.BaseOperation, error) {
+	if m.failOpts {
+		return nil, ErrCassandraSidecarNotReturned201
+	}
+
+	return &icarus.BaseOperation{Type_: request.Type_, Id: operationID, State: state}, nil
+}
+
+func (m *mockCassandraBackupClient) PerformCommitlogBackupOperation(request CommitlogBackupOperationRequest) (
+	*icarus.BaseOperation, error) {
+	if m.failOpts {
+		return nil, ErrCassandraSidecarNotReturned201
+	}
+
+	return &icarus.BaseOperation{Type_: request.Type_, Id: operationID, State: state}, nil
+}
+
+func (m *mockCassandraBackupClient) PerformCommitlogRestoreOperation(request CommitlogRestoreOperationRequest) (
+	*icarus.BaseOperation, error) {
+	if m.failOpts {
+		return nil, ErrCassandraSidecarNotReturned201
+	}
+
+	return &icarus.BaseOperation{Type_: request.Type_, Id: operationID, State: state}, nil
+}
+
+func (m *mockCassandraBackupClient) BackupOperations() ([]icarus.BackupOperationResponse, error) {
+	if m.failOpts {
+		return nil, ErrCassandraSidecarNotReturned200
+	}
+
+	return []icarus.BackupOperationResponse{
+		{Id: "global", SnapshotTag: snapshotTag, GlobalRequest: true, State: stateGetById, Progress: 0.2},
+		{Id: operationID, SnapshotTag: snapshotTag, State: stateGetById, Progress: 0.5},
+	}, nil
+}
+
+func (m *mockCassandraBackupClient) AbortOperation(operationId string) error {
+	if m.failOpts {
+		return ErrCassandraSidecarNotReturned200
+	}
+
+	return nil
+}
@@ -3,11 +3,71 @@ package cassandrabackup
 import (
 	"context"
 	"fmt"
+	"net/http"
+
 	"github.com/antihax/optional"
 	icarus "github.com/instaclustr/instaclustr-icarus-go-client/pkg/instaclustr_icarus"
 	"github.com/mitchellh/mapstructure"
 )
 
+// RemoveBackupOperationRequest is the request of the remove-backup operation of the sidecar, which is not part of
+// the icarus client
+type RemoveBackupOperationRequest struct {
+	// type of operation, 'remove-backup'
+	Type_ string `json:"type"`
+	// location the backup was uploaded to
+	StorageLocation string `json:"storageLocation"`
+	// name of the backup to remove, i.e. its snapshot tag
+	BackupName string `json:"backupName"`
+	// name of datacenter whose nodes remove the backup
+	Dc string `json:"dc,omitempty"`
+	// name of Kubernetes namespace to fetch Kubernetes secret for backups from
+	K8sNamespace string `json:"k8sNamespace,omitempty"`
+	// name of Kubernetes secret from which credentials used for the communication to cloud storage providers are read
+	K8sSecretName string `json:"k8sSecretName,omitempty"`
+	// flag saying if the backup is removed for all the nodes of the datacenter
+	GlobalRequest bool `json:"globalRequest,omitempty"`
+}
+
+// CommitlogBackupOperationRequest is the request of the commitlog-backup operation of the sidecar, uploading the
+// commitlog segments archived by its node. It is not part of the icarus client
+type CommitlogBackupOperationRequest struct {
+	// type of operation, 'commitlog-backup'
+	Type_ string `json:"type"`
+	// location the commitlogs are uploaded to
+	StorageLocation string `json:"storageLocation"`
+	// directory holding the commitlog segments to upload
+	CommitLogArchiveOverride string `json:"commitLogArchiveOverride"`
+	// number of threads used to upload the commitlogs
+	ConcurrentConnections int32 `json:"concurrentConnections,omitempty"`
+	// name of Kubernetes namespace to fetch Kubernetes secret for backups from
+	K8sNamespace string `json:"k8sNamespace,omitempty"`
+	// name of Kubernetes secret from which credentials used for the communication to cloud storage providers are read
+	K8sSecretName string `json:"k8sSecretName,omitempty"`
+}
+
+// CommitlogRestoreOperationRequest is the request of the commitlog-restore operation of the sidecar, downloading the
+// commitlogs of its node and configuring Cassandra to replay them on its next start. It is not part of the icarus
+// client
+type CommitlogRestoreOperationRequest struct {
+	// type of operation, 'commitlog-restore'
+	Type_ string `json:"type"`
+	// location the commitlogs were uploaded to
+	StorageLocation string `json:"storageLocation"`
+	// directory the commitlogs are downloaded to
+	CommitlogDownloadDir string `json:"commitlogDownloadDir"`
+	// directory of the configuration of Cassandra where commitlog_archiving.properties is written
+	CassandraConfigDirectory string `json:"cassandraConfigDirectory,omitempty"`
+	// directory of Cassandra where data folder resides
+	CassandraDirectory string `json:"cassandraDirectory,omitempty"`
+	// mutations written after this timestamp, in milliseconds since epoch, are not replayed
+	TimestampEnd int64 `json:"timestampEnd"`
+	// name of Kubernetes namespace to fetch Kubernetes secret for backups from
+	K8sNamespace string `json:"k8sNamespace,omitempty"`
+	// name of Kubernetes secret from which credentials used for the communication to cloud storage providers are read
+	K8sSecretName string `json:"k8sSecretName,omitempty"`
+}
+
 func (client *client) PerformRestoreOperation(restoreOperationReq icarus.RestoreOperationRequest) (
 	*icarus.RestoreOperationResponse, error) {
 	var restoreOperation icarus.RestoreOperationResponse
@@ -72,6 +132,26 @@ func (client *client) BackupOperationByID(operationId string) (response *icarus.
 	return
 }
 
+// BackupOperations returns the backup operations run by the sidecar of the node
+func (client *client) BackupOperations() ([]icarus.BackupOperationResponse, error) {
+	podClient := client.podClient
+	if podClient == nil {
+		return nil, ErrNoCassandraBackupClientAvailable
+	}
+
+	body, _, err := podClient.OperationsApi.OperationsGet(context.Background(), &icarus.OperationsApiOperationsGetOpts{
+		Type_: optional.NewInterface([]string{"backup"}),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var backupOperations []icarus.BackupOperationResponse
+	mapstructure.Decode(body, &backupOperations)
+	return backupOperations, nil
+}
+
 func (client *client) PerformBackupOperation(request icarus.BackupOperationRequest) (
 	*icarus.BackupOperationResponse, error) {
 	var backupOperationResponse icarus.BackupOperationResponse
@@ -91,4 +171,68 @@ func (client *client) PerformBackupOperation(request icarus.BackupOperationReque
 
 	mapstructure.Decode(body, &backupOperationResponse)
 	return &backupOperationResponse, nil
-}
\ No newline at end of file
+}
+
+func (client *client) PerformRemoveBackupOperation(request RemoveBackupOperationRequest) (*icarus.BaseOperation,
+	error) {
+	return client.performOperation(request)
+}
+
+func (client *client) PerformCommitlogBackupOperation(request CommitlogBackupOperationRequest) (
+	*icarus.BaseOperation, error) {
+	return client.performOperation(request)
+}
+
+func (client *client) PerformCommitlogRestoreOperation(request CommitlogRestoreOperationRequest) (
+	*icarus.BaseOperation, error) {
+	return client.performOperation(request)
+}
+
+// performOperation submits an operation which is not part of the icarus client
+func (client *client) performOperation(request interface{}) (*icarus.BaseOperation, error) {
+	var operation icarus.BaseOperation
+
+	podClient := client.podClient
+	if podClient == nil {
+		return nil, ErrNoCassandraBackupClientAvailable
+	}
+
+	body, _, err := podClient.OperationsApi.OperationsPost(context.Background(), &icarus.OperationsApiOperationsPostOpts{
+		Body: optional.NewInterface(request),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	mapstructure.Decode(body, &operation)
+	return &operation, nil
+}
+
+// AbortOperation aborts a running operation of the sidecar. Its abort endpoint is not part of the icarus client
+func (client *client) AbortOperation(operationId string) error {
+	if operationId == "" {
+		return fmt.Errorf("must get a non empty id")
+	}
+
+	if client.podClient == nil {
+		return ErrNoCassandraBackupClientAvailable
+	}
+
+	request, err := http.NewRequest(http.MethodDelete,
+		fmt.Sprintf("%s/operations/%s", client.podConfig.BasePath, operationId), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.podConfig.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("abort of operation %s returned %s", operationId, response.Status)
+	}
+	return nil
+}
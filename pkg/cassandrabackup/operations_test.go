@@ -36,6 +36,15 @@ func TestGetRestoreOperation(t *testing.T) {
 	assert.Nil(restore)
 }
 
+func TestAbortOperation(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(abortMock(http.StatusNoContent))
+	assert.EqualError(abortMock(http.StatusNotFound),
+		fmt.Sprintf("abort of operation %s returned 404", operationID))
+	assert.NotNil(newBuildedMockClient().AbortOperation(""))
+}
+
 func performRestoreMock(codeStatus int) (*icarus.RestoreOperationResponse, error) {
 	client := newBuildedMockClient()
 	defer httpmock.DeactivateAndReset()
@@ -104,3 +113,14 @@ func getRestoreMock(codeStatus int) (*icarus.RestoreOperationResponse, error) {
 
 	return client.RestoreOperationByID(operationID)
 }
+
+func abortMock(codeStatus int) error {
+	client := newBuildedMockClient()
+	defer httpmock.DeactivateAndReset()
+
+	url := fmt.Sprintf("http://%s:%d/operations/%s", hostnamePodA, DefaultCassandraSidecarPort, operationID)
+
+	httpmock.RegisterResponder(http.MethodDelete, url, httpmock.NewStringResponder(codeStatus, ""))
+
+	return client.AbortOperation(operationID)
+}
@@ -26,6 +26,7 @@ import (
 	"bytes"
 	goctx "context"
 	"fmt"
+	"io"
 	"net"
 	"os"
 
@@ -116,9 +117,26 @@ func ExecPodFromName(namespace string, name string, cmd []string) (string, strin
 //https://github.com/kubernetes/kubernetes/blob/master/pkg/kubectl/cmd/exec.go
 //func ExecPod(clientset *kubernetes.Clientset, cfg *rest.Config, namespace string, pod *corev1.Pod, cmd []string) (string, string, error) {
 func ExecPod(namespace string, pod *corev1.Pod, cmd []string) (string, string, error) {
+	return ExecPodWithStdin(namespace, pod, cmd, nil)
+}
+
+// ExecPodWithStdin runs a command in the cassandra container of a pod, stdin is given to the command when not nil
+func ExecPodWithStdin(namespace string, pod *corev1.Pod, cmd []string, stdin io.Reader) (string, string, error) {
+	return ExecPodContainer(namespace, pod, "cassandra", cmd, stdin)
+}
 
-	if len(pod.Spec.Containers) != 1 {
-		return "", "", fmt.Errorf("could not determine which container to use")
+// ExecPodContainer runs a command in a container of a pod, stdin is given to the command when not nil
+func ExecPodContainer(namespace string, pod *corev1.Pod, containerName string, cmd []string,
+	stdin io.Reader) (string, string, error) {
+
+	found := false
+	for _, container := range pod.Spec.Containers {
+		if container.Name == containerName {
+			found = true
+		}
+	}
+	if !found {
+		return "", "", fmt.Errorf("pod %s has no %s container", pod.Name, containerName)
 	}
 
 	// build the remoteexec
@@ -129,9 +147,9 @@ func ExecPod(namespace string, pod *corev1.Pod, cmd []string) (string, string, e
 		SubResource("exec")
 
 	req.VersionedParams(&corev1.PodExecOptions{
-		Container: "cassandra",
+		Container: containerName,
 		Command:   cmd,
-		Stdin:     false,
+		Stdin:     stdin != nil,
 		Stdout:    true,
 		Stderr:    true,
 		TTY:       false,
@@ -144,6 +162,7 @@ func ExecPod(namespace string, pod *corev1.Pod, cmd []string) (string, string, e
 
 	var stdout, stderr bytes.Buffer
 	err = exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
 		Stdout: &stdout,
 		Stderr: &stderr,
 		Tty:    false,
@@ -0,0 +1,61 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// cqlshScript reads the credentials on the first two lines of its input and writes them in a cqlshrc only readable
+// by its user. The rest of the input is the CQL statements, they are run from a file so that cqlsh exits with an
+// error when one of them fails. Neither the credentials nor the statements are in the arguments of a process
+const cqlshScript = `set -e
+IFS= read -r CQL_USERNAME
+IFS= read -r CQL_PASSWORD
+umask 077
+CQL_DIR=$(mktemp -d)
+trap 'rm -rf "$CQL_DIR"' EXIT
+printf '[authentication]\nusername = %s\npassword = %s\n\n[ssl]\nvalidate = false\n' \
+  "$CQL_USERNAME" "$CQL_PASSWORD" > "$CQL_DIR/cqlshrc"
+cat > "$CQL_DIR/statements.cql"
+cqlsh --cqlshrc="$CQL_DIR/cqlshrc" -f "$CQL_DIR/statements.cql" "$@"`
+
+// CqlshCommand returns the command running cqlsh with its arguments, the credentials and the statements are read
+// from the input returned by CqlshInput. The certificate of the node is not validated as cqlsh runs on the node
+func CqlshCommand(cqlshArgs ...string) []string {
+	return append([]string{"sh", "-c", cqlshScript, "cqlsh"}, cqlshArgs...)
+}
+
+// CqlshInput returns the input of the command of CqlshCommand
+func CqlshInput(username, password, statement string) (string, error) {
+	if strings.ContainsAny(username, "\r\n") || strings.ContainsAny(password, "\r\n") {
+		return "", fmt.Errorf("credentials of role %q can't contain a line break", username)
+	}
+	// cqlsh interpolates the username read from the cqlshrc but not the password
+	return strings.Replace(username, "%", "%%", -1) + "\n" + password + "\n" + statement + "\n", nil
+}
+
+// ExecCQL runs CQL statements with cqlsh in the cassandra container of a pod, without passing the credentials in the
+// arguments of any process
+func ExecCQL(pod *corev1.Pod, username, password, statement string, cqlshArgs ...string) (string, string, error) {
+	input, err := CqlshInput(username, password, statement)
+	if err != nil {
+		return "", "", err
+	}
+	return ExecPodWithStdin(pod.Namespace, pod, CqlshCommand(cqlshArgs...), strings.NewReader(input))
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCqlshCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := CqlshCommand("--ssl", "10.0.0.1")
+	assert.Equal([]string{"sh", "-c"}, cmd[:2])
+	assert.Equal([]string{"cqlsh", "--ssl", "10.0.0.1"}, cmd[3:])
+
+	input, err := CqlshInput("admin%1", "secret", "CREATE ROLE r WITH PASSWORD = 'role-secret'")
+	assert.Nil(err)
+	assert.Equal("admin%%1\nsecret\nCREATE ROLE r WITH PASSWORD = 'role-secret'\n", input)
+	for _, arg := range cmd {
+		assert.False(strings.Contains(arg, "secret"))
+	}
+
+	_, err = CqlshInput("admin", "sec\nret", "SELECT now() FROM system.local")
+	assert.NotNil(err)
+}
@@ -201,6 +201,33 @@ func LookupCassandraBackup(client runtimeClient.Client, backupName,
 	return
 }
 
+// LookupCassandraBackupRun returns the run of a scheduled CassandraBackup
+func LookupCassandraBackupRun(client runtimeClient.Client, runName,
+	runNamespace string) (run *api.CassandraBackupRun, err error) {
+	run = &api.CassandraBackupRun{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: runName, Namespace: runNamespace}, run)
+	return
+}
+
+// LatestCompletedCassandraBackupRun returns the most recent run of a scheduled CassandraBackup which has completed,
+// nil if there is none
+func LatestCompletedCassandraBackupRun(client runtimeClient.Client,
+	backup *api.CassandraBackup) (*api.CassandraBackupRun, error) {
+	runs := &api.CassandraBackupRunList{}
+	if err := client.List(context.TODO(), runs, runtimeClient.InNamespace(backup.Namespace),
+		runtimeClient.MatchingLabels{api.LabelCassandraBackup: backup.Name}); err != nil {
+		return nil, err
+	}
+	var latest *api.CassandraBackupRun
+	for i := range runs.Items {
+		// Runs are named after their time so they sort chronologically
+		if runs.Items[i].Completed() && (latest == nil || runs.Items[i].Name > latest.Name) {
+			latest = &runs.Items[i]
+		}
+	}
+	return latest, nil
+}
+
 // IsMarkedForDeletion determines if the object is marked for deletion
 func IsMarkedForDeletion(m metav1.ObjectMeta) bool {
 	return m.GetDeletionTimestamp() != nil
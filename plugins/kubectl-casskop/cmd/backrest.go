@@ -0,0 +1,148 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func conditionType(status api.BackRestStatus) string {
+	if status.Condition == nil {
+		return "-"
+	}
+	return status.Condition.Type
+}
+
+func newBackupCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Create and list backups",
+	}
+
+	var spec api.CassandraBackupSpec
+	create := &cobra.Command{
+		Use:   "create <backup_name> --cluster <crd_name> --storage-location <location>",
+		Short: "Create a CassandraBackup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backup := &api.CassandraBackup{ObjectMeta: metav1.ObjectMeta{Name: args[0], Namespace: o.namespace},
+				Spec: spec}
+			if err := o.client.Create(context.TODO(), backup); err != nil {
+				return err
+			}
+			return o.print(backup, func() {
+				fmt.Fprintf(o.out, "CassandraBackup %s created\n", backup.Name)
+			})
+		},
+	}
+	create.Flags().StringVar(&spec.CassandraCluster, "cluster", "", "Name of the CassandraCluster to backup")
+	create.Flags().StringVar(&spec.Datacenter, "datacenter", "", "DC to backup")
+	create.Flags().StringVar(&spec.StorageLocation, "storage-location", "",
+		"URI of the backup location, e.g. s3://bucket/cluster")
+	create.Flags().StringVar(&spec.SnapshotTag, "snapshot-tag", "", "Name of the snapshot")
+	create.Flags().StringVar(&spec.Schedule, "schedule", "", "Cron expression to run the backup periodically")
+	create.Flags().StringVar(&spec.Secret, "secret", "", "Secret used to access the storage location")
+	create.Flags().StringVar(&spec.Entities, "entities", "", "Keyspaces or tables to backup, all if empty")
+	create.Flags().StringVar(&spec.Duration, "duration", "", "Duration the backup should try to last")
+	create.Flags().StringVar(&spec.Bandwidth, "bandwidth", "", "Bandwidth not to exceed when uploading files")
+	create.MarkFlagRequired("cluster")
+	create.MarkFlagRequired("storage-location")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the CassandraBackups",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backups := &api.CassandraBackupList{}
+			if err := o.client.List(context.TODO(), backups, client.InNamespace(o.namespace)); err != nil {
+				return err
+			}
+			return o.print(backups.Items, func() {
+				w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tCLUSTER\tSTORAGE LOCATION\tSCHEDULE\tCONDITION\tPROGRESS\tCOMPLETED")
+				for _, backup := range backups.Items {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", backup.Name, backup.Spec.CassandraCluster,
+						backup.Spec.StorageLocation, backup.Spec.Schedule, conditionType(backup.Status),
+						backup.Status.Progress, backup.Status.TimeCompleted)
+				}
+				w.Flush()
+			})
+		},
+	}
+	cmd.AddCommand(create, list)
+	return cmd
+}
+
+func newRestoreCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Create and list restores",
+	}
+
+	var spec api.CassandraRestoreSpec
+	create := &cobra.Command{
+		Use:   "create <restore_name> --cluster <crd_name> --backup <backup_name>",
+		Short: "Create a CassandraRestore",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			restore := &api.CassandraRestore{ObjectMeta: metav1.ObjectMeta{Name: args[0], Namespace: o.namespace},
+				Spec: spec}
+			if err := o.client.Create(context.TODO(), restore); err != nil {
+				return err
+			}
+			return o.print(restore, func() {
+				fmt.Fprintf(o.out, "CassandraRestore %s created\n", restore.Name)
+			})
+		},
+	}
+	create.Flags().StringVar(&spec.CassandraCluster, "cluster", "", "Name of the CassandraCluster to restore")
+	create.Flags().StringVar(&spec.Datacenter, "datacenter", "", "DC to restore")
+	create.Flags().StringVar(&spec.CassandraBackup, "backup", "", "Name of the CassandraBackup to restore")
+	create.Flags().StringVar(&spec.Entities, "entities", "", "Keyspaces or tables to restore, all if empty")
+	create.Flags().StringVar(&spec.SchemaVersion, "schema-version", "", "Version of the schema to restore from")
+	create.MarkFlagRequired("cluster")
+	create.MarkFlagRequired("backup")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the CassandraRestores",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			restores := &api.CassandraRestoreList{}
+			if err := o.client.List(context.TODO(), restores, client.InNamespace(o.namespace)); err != nil {
+				return err
+			}
+			return o.print(restores.Items, func() {
+				w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tCLUSTER\tBACKUP\tCONDITION\tPROGRESS\tCOMPLETED")
+				for _, restore := range restores.Items {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", restore.Name, restore.Spec.CassandraCluster,
+						restore.Spec.CassandraBackup, conditionType(restore.Status), restore.Status.Progress,
+						restore.Status.TimeCompleted)
+				}
+				w.Flush()
+			})
+		},
+	}
+	cmd.AddCommand(create, list)
+	return cmd
+}
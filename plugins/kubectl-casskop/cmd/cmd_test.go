@@ -0,0 +1,201 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const namespace = "ns"
+
+func helperPod(name string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Status: v1.PodStatus{Phase: v1.PodRunning}}
+}
+
+func helperCassandraCluster() *api.CassandraCluster {
+	return &api.CassandraCluster{
+		TypeMeta:   metav1.TypeMeta{Kind: "CassandraCluster", APIVersion: api.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-demo", Namespace: namespace},
+		Spec: api.CassandraClusterSpec{NodesPerRacks: 1, Topology: api.Topology{DC: api.DCSlice{
+			{Name: "dc1", Rack: api.RackSlice{{Name: "rack1"}, {Name: "rack2"}}},
+			{Name: "dc2", Rack: api.RackSlice{{Name: "rack1"}}},
+		}}},
+		Status: api.CassandraClusterStatus{Phase: api.ClusterPhaseRunning.Name,
+			CassandraRackStatus: map[string]*api.CassandraRackStatus{
+				"dc1-rack1": {Phase: api.ClusterPhaseRunning.Name,
+					PodLastOperation: api.PodLastOperation{Name: api.OperationCleanup, Status: api.StatusDone}}},
+			OperationHistory: []api.PodOperationRecord{{Name: api.OperationCleanup,
+				Pod: "cassandra-demo-dc1-rack1-0", Status: api.StatusError, Error: "no space left"}}},
+	}
+}
+
+// helperRun runs the plugin with a fake client holding objs and returns its output
+func helperRun(t *testing.T, objs []runtime.Object, args ...string) (*options, string, error) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, clientgoscheme.AddToScheme(scheme))
+	assert.Nil(t, api.AddToScheme(scheme))
+	out := &bytes.Buffer{}
+	o := &options{client: fake.NewFakeClientWithScheme(scheme, objs...), out: out}
+	cmd := newRootCommand(o)
+	cmd.SetArgs(append(args, "--namespace", namespace))
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	err := cmd.Execute()
+	return o, out.String(), err
+}
+
+func helperPodLabels(t *testing.T, o *options, name string) map[string]string {
+	pod := &v1.Pod{}
+	assert.Nil(t, o.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, pod))
+	return pod.Labels
+}
+
+func TestCleanup(t *testing.T) {
+	assert := assert.New(t)
+	objs := []runtime.Object{
+		helperPod("cassandra-demo-dc1-rack1-0", nil),
+		helperPod("cassandra-demo-dc1-rack1-1", map[string]string{"operation-name": api.OperationRebuild,
+			"operation-status": api.StatusOngoing}),
+		helperPod("cassandra-demo-dc2-rack1-0", nil),
+	}
+	o, _, err := helperRun(t, objs, "cleanup", "--prefix", "cassandra-demo-dc1")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"operation-name": api.OperationCleanup, "operation-status": api.StatusToDo},
+		helperPodLabels(t, o, "cassandra-demo-dc1-rack1-0"))
+	//A pod running an operation is left out
+	assert.Equal(api.OperationRebuild, helperPodLabels(t, o, "cassandra-demo-dc1-rack1-1")["operation-name"])
+	assert.Equal("", helperPodLabels(t, o, "cassandra-demo-dc2-rack1-0")["operation-name"])
+
+	_, _, err = helperRun(t, objs, "cleanup")
+	assert.NotNil(err)
+	_, _, err = helperRun(t, objs, "cleanup", "--pod", "unknown")
+	assert.EqualError(err, noPodsFound)
+}
+
+func TestRebuildAndPause(t *testing.T) {
+	assert := assert.New(t)
+	objs := []runtime.Object{helperPod("cassandra-demo-dc2-rack1-0", nil)}
+	o, _, err := helperRun(t, objs, "rebuild", "--pod", "cassandra-demo-dc2-rack1-0", "dc1")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"operation-name": api.OperationRebuild, "operation-status": api.StatusToDo,
+		"operation-argument": "dc1"}, helperPodLabels(t, o, "cassandra-demo-dc2-rack1-0"))
+
+	pod := helperPod("cassandra-demo-dc2-rack1-0", helperPodLabels(t, o, "cassandra-demo-dc2-rack1-0"))
+	o, _, err = helperRun(t, []runtime.Object{pod}, "pause", api.OperationRebuild, "--prefix", "cassandra-demo")
+	assert.Nil(err)
+	assert.Equal(statusPaused, helperPodLabels(t, o, "cassandra-demo-dc2-rack1-0")["operation-status"])
+}
+
+func TestRemove(t *testing.T) {
+	assert := assert.New(t)
+	cc := helperCassandraCluster()
+	cc.Status.CassandraRackStatus["dc1-rack1"].PodLastOperation.Pods = []string{"cassandra-demo-dc1-rack1-0"}
+	labels := map[string]string{"app": "cassandracluster", "cassandracluster": "cassandra-demo"}
+	objs := []runtime.Object{cc, helperPod("cassandra-demo-dc1-rack1-0", labels),
+		helperPod("cassandra-demo-dc1-rack2-0", labels)}
+	o, _, err := helperRun(t, objs, "remove", "--previous-ip", "10.0.0.1", "--crd", "cassandra-demo")
+	assert.Nil(err)
+	//The pod running an operation is not chosen
+	assert.Equal("_10.0.0.1", helperPodLabels(t, o, "cassandra-demo-dc1-rack2-0")["operation-argument"])
+	assert.Equal(api.OperationRemove, helperPodLabels(t, o, "cassandra-demo-dc1-rack2-0")["operation-name"])
+}
+
+func TestReplace(t *testing.T) {
+	assert := assert.New(t)
+	pod := helperPod("cassandra-demo-dc1-rack1-0", nil)
+	pod.Status.Phase = v1.PodPending
+	o, _, err := helperRun(t, []runtime.Object{pod}, "replace", "--pod", "cassandra-demo-dc1-rack1-0")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"operation-name": api.OperationReplaceNode, "operation-status": api.StatusToDo},
+		helperPodLabels(t, o, "cassandra-demo-dc1-rack1-0"))
+
+	o, _, err = helperRun(t, []runtime.Object{pod}, "replace", "--pod", "cassandra-demo-dc1-rack1-0",
+		"--previous-ip", "10.0.0.1")
+	assert.Nil(err)
+	assert.Equal("10.0.0.1", helperPodLabels(t, o, "cassandra-demo-dc1-rack1-0")["operation-argument"])
+}
+
+func TestRestart(t *testing.T) {
+	assert := assert.New(t)
+	o, _, err := helperRun(t, []runtime.Object{helperCassandraCluster()}, "restart", "--crd", "cassandra-demo",
+		"--rack", "dc1.rack2")
+	assert.Nil(err)
+	cc := &api.CassandraCluster{}
+	assert.Nil(o.client.Get(context.TODO(), types.NamespacedName{Name: "cassandra-demo", Namespace: namespace}, cc))
+	assert.False(cc.Spec.Topology.DC[0].Rack[0].RollingRestart)
+	assert.True(cc.Spec.Topology.DC[0].Rack[1].RollingRestart)
+
+	_, _, err = helperRun(t, []runtime.Object{helperCassandraCluster()}, "restart", "--crd", "cassandra-demo",
+		"--dc", "dc3")
+	assert.NotNil(err)
+}
+
+func TestStatus(t *testing.T) {
+	assert := assert.New(t)
+	_, out, err := helperRun(t, []runtime.Object{helperCassandraCluster()}, "status", "cassandra-demo", "-o", "json")
+	assert.Nil(err)
+	var status clusterStatus
+	assert.Nil(json.Unmarshal([]byte(out), &status))
+	assert.Equal(3, len(status.Racks))
+	assert.Equal(rackStatus{DC: "dc1", Rack: "rack1", Nodes: 1, Phase: api.ClusterPhaseRunning.Name,
+		LastOperation: api.PodLastOperation{Name: api.OperationCleanup, Status: api.StatusDone}}, status.Racks[0])
+	assert.Equal("dc2", status.Racks[2].DC)
+
+	_, out, err = helperRun(t, []runtime.Object{helperCassandraCluster()}, "history", "cassandra-demo")
+	assert.Nil(err)
+	assert.Contains(out, "no space left")
+
+	_, _, err = helperRun(t, []runtime.Object{helperCassandraCluster()}, "status", "cassandra-demo", "-o", "yaml")
+	assert.NotNil(err)
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	assert := assert.New(t)
+	o, _, err := helperRun(t, nil, "backup", "create", "nightly", "--cluster", "cassandra-demo",
+		"--storage-location", "s3://bucket", "--schedule", "@daily")
+	assert.Nil(err)
+	backup := &api.CassandraBackup{}
+	assert.Nil(o.client.Get(context.TODO(), types.NamespacedName{Name: "nightly", Namespace: namespace}, backup))
+	assert.Equal(api.CassandraBackupSpec{CassandraCluster: "cassandra-demo", StorageLocation: "s3://bucket",
+		Schedule: "@daily"}, backup.Spec)
+
+	_, out, err := helperRun(t, []runtime.Object{backup}, "backup", "list")
+	assert.Nil(err)
+	assert.Contains(out, "nightly")
+
+	o, _, err = helperRun(t, nil, "restore", "create", "restore-nightly", "--cluster", "cassandra-demo",
+		"--backup", "nightly")
+	assert.Nil(err)
+	restore := &api.CassandraRestore{}
+	assert.Nil(o.client.Get(context.TODO(), types.NamespacedName{Name: "restore-nightly", Namespace: namespace},
+		restore))
+	assert.Equal("nightly", restore.Spec.CassandraBackup)
+
+	_, _, err = helperRun(t, nil, "restore", "create", "restore-nightly", "--cluster", "cassandra-demo")
+	assert.NotNil(err)
+}
@@ -0,0 +1,244 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/Orange-OpenSource/casskop/pkg/k8s"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	statusPaused = "Paused"
+	noPodsFound  = "no pods found for operation"
+	// pods with an ongoing operation are not selected unless an operation status is given
+	noOngoingOperation = "operation-status notin (Ongoing, Finalizing)"
+)
+
+// podSelection selects the pods an operation runs on
+type podSelection struct {
+	pod    string
+	prefix string
+}
+
+func (s *podSelection) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.pod, "pod", "", "Name of the pod")
+	cmd.Flags().StringVar(&s.prefix, "prefix", "", "Prefix of the name of the pods")
+}
+
+func (s *podSelection) validate() error {
+	if (s.pod == "") == (s.prefix == "") {
+		return fmt.Errorf("one of --pod or --prefix is required")
+	}
+	return nil
+}
+
+// listPods returns the running pods of the selection. When operationStatus is empty, the pods running an operation
+// are left out, otherwise only the pods with this operation and status are returned
+func listPods(o *options, s podSelection, operationName, operationStatus string) ([]v1.Pod, error) {
+	selector, err := labels.Parse(noOngoingOperation)
+	if operationStatus != "" {
+		selector, err = labels.Parse(fmt.Sprintf("operation-name=%s,operation-status=%s", operationName,
+			operationStatus))
+	}
+	if err != nil {
+		return nil, err
+	}
+	podList := &v1.PodList{}
+	if err = o.client.List(context.TODO(), podList, client.InNamespace(o.namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	var pods []v1.Pod
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		if (s.pod != "" && pod.Name == s.pod) || (s.prefix != "" && strings.HasPrefix(pod.Name, s.prefix)) {
+			pods = append(pods, pod)
+		}
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf(noPodsFound)
+	}
+	return pods, nil
+}
+
+// setPodLabels sets the labels triggering an operation on a pod
+func setPodLabels(o *options, pod *v1.Pod, operationName, operationStatus, argument string) error {
+	fmt.Fprintf(o.out, "Set status of operation %s on pod %s to %s\n", operationName, pod.Name, operationStatus)
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels["operation-name"] = operationName
+	pod.Labels["operation-status"] = operationStatus
+	if argument != "" {
+		pod.Labels["operation-argument"] = argument
+	}
+	return o.client.Patch(context.TODO(), pod, patch)
+}
+
+func newSimpleOperationCommand(o *options, operationName string) *cobra.Command {
+	var selection podSelection
+	cmd := &cobra.Command{
+		Use:   operationName + " {--pod <pod_name> | --prefix <prefix_pod_name>}",
+		Short: fmt.Sprintf("Trigger a %s on pods", operationName),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := selection.validate(); err != nil {
+				return err
+			}
+			pods, err := listPods(o, selection, operationName, "")
+			if err != nil {
+				return err
+			}
+			for i := range pods {
+				if err = setPodLabels(o, &pods[i], operationName, api.StatusToDo, ""); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	selection.addFlags(cmd)
+	return cmd
+}
+
+func newRebuildCommand(o *options) *cobra.Command {
+	var selection podSelection
+	cmd := &cobra.Command{
+		Use:   api.OperationRebuild + " {--pod <pod_name> | --prefix <prefix_pod_name>} <from-dc>",
+		Short: "Trigger a rebuild of pods from another dc",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := selection.validate(); err != nil {
+				return err
+			}
+			pods, err := listPods(o, selection, api.OperationRebuild, "")
+			if err != nil {
+				return err
+			}
+			for i := range pods {
+				if err = setPodLabels(o, &pods[i], api.OperationRebuild, api.StatusToDo, args[0]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	selection.addFlags(cmd)
+	return cmd
+}
+
+func newPauseCommand(o *options, pause bool) *cobra.Command {
+	var selection podSelection
+	use, oldStatus, newStatus := "pause", api.StatusToDo, statusPaused
+	if !pause {
+		use, oldStatus, newStatus = "unpause", statusPaused, api.StatusToDo
+	}
+	cmd := &cobra.Command{
+		Use:   use + " <operation> {--pod <pod_name> | --prefix <prefix_pod_name>}",
+		Short: fmt.Sprintf("Set operations in status %s to %s", oldStatus, newStatus),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := selection.validate(); err != nil {
+				return err
+			}
+			pods, err := listPods(o, selection, args[0], oldStatus)
+			if err != nil {
+				return err
+			}
+			for i := range pods {
+				if err = setPodLabels(o, &pods[i], args[0], newStatus, ""); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	selection.addFlags(cmd)
+	return cmd
+}
+
+// availablePod returns a running pod of the cluster which is not running an operation
+func availablePod(o *options, clusterName string) (*v1.Pod, error) {
+	cc := &api.CassandraCluster{}
+	if err := o.client.Get(context.TODO(), types.NamespacedName{Name: clusterName, Namespace: o.namespace},
+		cc); err != nil {
+		return nil, err
+	}
+	busyPods := map[string]bool{}
+	for _, rackStatus := range cc.Status.CassandraRackStatus {
+		for _, pod := range rackStatus.PodLastOperation.Pods {
+			busyPods[pod] = true
+		}
+	}
+	podList := &v1.PodList{}
+	if err := o.client.List(context.TODO(), podList, client.InNamespace(o.namespace),
+		client.MatchingLabels(k8s.LabelsForCassandra(cc))); err != nil {
+		return nil, err
+	}
+	for i, pod := range podList.Items {
+		if pod.Status.Phase == v1.PodRunning && !busyPods[pod.Name] {
+			return &podList.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no pod available in cluster %s", clusterName)
+}
+
+func newRemoveCommand(o *options) *cobra.Command {
+	var pod, previousIP, fromPod, clusterName string
+	cmd := &cobra.Command{
+		Use: api.OperationRemove + " --pod <pod_name> [--previous-ip <previous_ip_pod>] " +
+			"{--from-pod <pod_name> | --crd <crd_name>}",
+		Short: "Trigger the removal of a node from another pod",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pod == "" && previousIP == "" {
+				return fmt.Errorf("at least one option must be used between --pod and --previous-ip")
+			}
+			if (fromPod == "") == (clusterName == "") {
+				return fmt.Errorf("one of --from-pod or --crd is required")
+			}
+			var from *v1.Pod
+			var err error
+			if fromPod != "" {
+				from = &v1.Pod{}
+				err = o.client.Get(context.TODO(), types.NamespacedName{Name: fromPod, Namespace: o.namespace}, from)
+			} else {
+				from, err = availablePod(o, clusterName)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(o.out, "Trigger %s of pod %s from pod %s\n", api.OperationRemove, pod, from.Name)
+			return setPodLabels(o, from, api.OperationRemove, api.StatusToDo, pod+"_"+previousIP)
+		},
+	}
+	cmd.Flags().StringVar(&pod, "pod", "", "Name of the pod of the node to remove")
+	cmd.Flags().StringVar(&previousIP, "previous-ip", "", "IP of the node to remove")
+	cmd.Flags().StringVar(&fromPod, "from-pod", "", "Name of the pod running the removal")
+	cmd.Flags().StringVar(&clusterName, "crd", "", "Name of the CassandraCluster to find a pod running the removal")
+	return cmd
+}
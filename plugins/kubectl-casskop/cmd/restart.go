@@ -0,0 +1,118 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var reRackName = regexp.MustCompile(`^(\w+)\W(\w+)$`)
+
+// rollingRestart flags the racks matching for a rolling restart and returns their number
+func rollingRestart(o *options, cc *api.CassandraCluster, matches func(dcName, rackName string) bool) int {
+	restarted := 0
+	for dc := range cc.Spec.Topology.DC {
+		for rack := range cc.Spec.Topology.DC[dc].Rack {
+			dcName, rackName := cc.Spec.Topology.DC[dc].Name, cc.Spec.Topology.DC[dc].Rack[rack].Name
+			if !matches(dcName, rackName) {
+				continue
+			}
+			fmt.Fprintf(o.out, "Trigger restart of %s.%s\n", dcName, rackName)
+			cc.Spec.Topology.DC[dc].Rack[rack].RollingRestart = true
+			restarted++
+		}
+	}
+	return restarted
+}
+
+func newRestartCommand(o *options) *cobra.Command {
+	var clusterName string
+	var racks, dcs []string
+	var full bool
+	cmd := &cobra.Command{
+		Use:   "restart --crd <crd_name> {--rack <dc.rack>... | --dc <dc>... | --full}",
+		Short: "Trigger a rolling restart of racks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selected := 0
+			for _, set := range []bool{len(racks) > 0, len(dcs) > 0, full} {
+				if set {
+					selected++
+				}
+			}
+			if selected != 1 {
+				return fmt.Errorf("one of --rack, --dc or --full is required")
+			}
+			cc := &api.CassandraCluster{}
+			if err := o.client.Get(context.TODO(), types.NamespacedName{Name: clusterName,
+				Namespace: o.namespace}, cc); err != nil {
+				return err
+			}
+			for _, rack := range racks {
+				names := reRackName.FindStringSubmatch(rack)
+				if names == nil {
+					return fmt.Errorf("can't extract dc name and rack name from %s", rack)
+				}
+				if rollingRestart(o, cc, func(d, r string) bool { return d == names[1] && r == names[2] }) == 0 {
+					return fmt.Errorf("can't match rack %s", rack)
+				}
+			}
+			for _, dc := range dcs {
+				if rollingRestart(o, cc, func(d, r string) bool { return d == dc }) == 0 {
+					return fmt.Errorf("can't match dc %s", dc)
+				}
+			}
+			if full {
+				rollingRestart(o, cc, func(d, r string) bool { return true })
+			}
+			return o.client.Update(context.TODO(), cc)
+		},
+	}
+	cmd.Flags().StringVar(&clusterName, "crd", "", "Name of the CassandraCluster")
+	cmd.Flags().StringSliceVar(&racks, "rack", nil, "Racks to restart, as dc.rack")
+	cmd.Flags().StringSliceVar(&dcs, "dc", nil, "DCs to restart")
+	cmd.Flags().BoolVar(&full, "full", false, "Restart all the racks")
+	cmd.MarkFlagRequired("crd")
+	return cmd
+}
+
+func newReplaceCommand(o *options) *cobra.Command {
+	var podName, previousIP string
+	cmd := &cobra.Command{
+		Use:   "replace --pod <pod_name> [--previous-ip <previous_ip_pod>]",
+		Short: "Trigger the replacement of the node of a pod, deleting its data",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pod := &v1.Pod{}
+			if err := o.client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: o.namespace},
+				pod); err != nil {
+				return err
+			}
+			return setPodLabels(o, pod, api.OperationReplaceNode, api.StatusToDo, previousIP)
+		},
+	}
+	cmd.Flags().StringVar(&podName, "pod", "", "Name of the pod to replace")
+	cmd.Flags().StringVar(&previousIP, "previous-ip", "",
+		"IP of the node to replace, when it is unknown to the CassandraCluster status")
+	cmd.MarkFlagRequired("pod")
+	return cmd
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmd implements the commands of the kubectl-casskop plugin
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// options are shared by all the commands
+type options struct {
+	kubeconfig string
+	context    string
+	namespace  string
+	output     string
+
+	client client.Client
+	out    io.Writer
+}
+
+// complete creates the client from the kubeconfig unless one is already set
+func (o *options) complete() error {
+	if o.output != outputText && o.output != outputJSON {
+		return fmt.Errorf("unsupported output %s, use %s or %s", o.output, outputText, outputJSON)
+	}
+	if o.client != nil {
+		return nil
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+		&clientcmd.ConfigOverrides{CurrentContext: o.context})
+	if o.namespace == "" {
+		namespace, _, err := config.Namespace()
+		if err != nil {
+			return err
+		}
+		o.namespace = namespace
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return err
+	}
+	scheme := runtime.NewScheme()
+	if err = clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err = api.AddToScheme(scheme); err != nil {
+		return err
+	}
+	o.client, err = client.New(restConfig, client.Options{Scheme: scheme})
+	return err
+}
+
+// print writes value as JSON with the json output, or calls text otherwise
+func (o *options) print(value interface{}, text func()) error {
+	if o.output == outputJSON {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(o.out, string(data))
+		return err
+	}
+	text()
+	return nil
+}
+
+func newRootCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "kubectl-casskop",
+		Short:        "Kubernetes plugin used to trigger operations on CassKop clusters",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return o.complete()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	cmd.PersistentFlags().StringVar(&o.context, "context", "", "Name of the kubeconfig context to use")
+	cmd.PersistentFlags().StringVarP(&o.namespace, "namespace", "n", "", "Namespace of the cluster")
+	cmd.PersistentFlags().StringVarP(&o.output, "output", "o", outputText, "Output format: text or json")
+
+	cmd.AddCommand(
+		newSimpleOperationCommand(o, api.OperationCleanup),
+		newSimpleOperationCommand(o, api.OperationUpgradeSSTables),
+		newSimpleOperationCommand(o, api.OperationRepair),
+		newRebuildCommand(o),
+		newRemoveCommand(o),
+		newReplaceCommand(o),
+		newRestartCommand(o),
+		newPauseCommand(o, true),
+		newPauseCommand(o, false),
+		newStatusCommand(o),
+		newHistoryCommand(o),
+		newBackupCommand(o),
+		newRestoreCommand(o),
+	)
+	return cmd
+}
+
+// NewRootCommand returns the kubectl-casskop command writing its output to out
+func NewRootCommand(out io.Writer) *cobra.Command {
+	return newRootCommand(&options{out: out})
+}
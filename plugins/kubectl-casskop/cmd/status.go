@@ -0,0 +1,136 @@
+// Copyright 2019 Orange
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// 	You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// 	See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	api "github.com/Orange-OpenSource/casskop/api/v2"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// rackStatus is the status of a rack printed by the status command
+type rackStatus struct {
+	DC            string                  `json:"dc"`
+	Rack          string                  `json:"rack"`
+	Nodes         int32                   `json:"nodes"`
+	Phase         string                  `json:"phase,omitempty"`
+	LastAction    api.CassandraLastAction `json:"lastAction"`
+	LastOperation api.PodLastOperation    `json:"lastOperation"`
+}
+
+// clusterStatus is the status of a cluster printed by the status command
+type clusterStatus struct {
+	Name                    string       `json:"name"`
+	Phase                   string       `json:"phase,omitempty"`
+	LastClusterAction       string       `json:"lastClusterAction,omitempty"`
+	LastClusterActionStatus string       `json:"lastClusterActionStatus,omitempty"`
+	Racks                   []rackStatus `json:"racks"`
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func getCassandraCluster(o *options, name string) (*api.CassandraCluster, error) {
+	cc := &api.CassandraCluster{}
+	err := o.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: o.namespace}, cc)
+	return cc, err
+}
+
+// newClusterStatus returns the status of the racks of the cluster, in the order of the topology
+func newClusterStatus(cc *api.CassandraCluster) clusterStatus {
+	status := clusterStatus{Name: cc.Name, Phase: cc.Status.Phase, LastClusterAction: cc.Status.LastClusterAction,
+		LastClusterActionStatus: cc.Status.LastClusterActionStatus, Racks: []rackStatus{}}
+	for dc := 0; dc < cc.GetDCSize(); dc++ {
+		dcName := cc.GetDCName(dc)
+		_, nodes := cc.GetDCNodesPerRacksFromName(dcName)
+		for rack := 0; rack < cc.GetRackSize(dc); rack++ {
+			rackName := cc.GetRackName(dc, rack)
+			rack := rackStatus{DC: dcName, Rack: rackName, Nodes: nodes}
+			if dcRackStatus, ok := cc.Status.CassandraRackStatus[cc.GetDCRackName(dcName, rackName)]; ok {
+				rack.Phase = dcRackStatus.Phase
+				rack.LastAction = dcRackStatus.CassandraLastAction
+				rack.LastOperation = dcRackStatus.PodLastOperation
+			}
+			status.Racks = append(status.Racks, rack)
+		}
+	}
+	return status
+}
+
+func newStatusCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status <crd_name>",
+		Short: "Show the topology of a cluster with the actions and operations of its racks",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cc, err := getCassandraCluster(o, args[0])
+			if err != nil {
+				return err
+			}
+			status := newClusterStatus(cc)
+			return o.print(status, func() {
+				fmt.Fprintf(o.out, "Cluster %s is %s, last action %s is %s\n\n", status.Name, status.Phase,
+					status.LastClusterAction, status.LastClusterActionStatus)
+				w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "DC\tRACK\tNODES\tPHASE\tACTION\tACTION STATUS\tOPERATION\tOPERATION STATUS\tPODS KO")
+				for _, rack := range status.Racks {
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", rack.DC, rack.Rack, rack.Nodes,
+						rack.Phase, rack.LastAction.Name, rack.LastAction.Status, rack.LastOperation.Name,
+						rack.LastOperation.Status, strings.Join(rack.LastOperation.PodsKO, ","))
+				}
+				w.Flush()
+			})
+		},
+	}
+}
+
+func newHistoryCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <crd_name>",
+		Short: "Show the last pod operations of a cluster",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cc, err := getCassandraCluster(o, args[0])
+			if err != nil {
+				return err
+			}
+			history := cc.Status.OperationHistory
+			if history == nil {
+				history = []api.PodOperationRecord{}
+			}
+			return o.print(history, func() {
+				w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "START\tEND\tOPERATION\tPOD\tSTATUS\tOPERATOR VERSION\tERROR")
+				for _, record := range history {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", formatTime(record.StartTime),
+						formatTime(record.EndTime), record.Name, record.Pod, record.Status, record.OperatorVersion,
+						record.Error)
+				}
+				w.Flush()
+			})
+		},
+	}
+}